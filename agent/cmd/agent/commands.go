@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Exit codes shared by all subcommands so they can be used from scripts
+const (
+	exitOK          = 0
+	exitFailure     = 1 // the command ran but failed (query error, send failure, ...)
+	exitUsage       = 2 // invalid arguments
+	exitUnavailable = 3 // osquery or configuration not available
+	exitTimeout     = 4 // the operation timed out
+)
+
+// command is a scanx subcommand such as "scanx query"
+type command struct {
+	summary string
+	run     func(args []string) int
+}
+
+// commands maps subcommand names to their handlers
+var commands = map[string]command{
+	"query":   {summary: "Run ad-hoc SQL or a named agent query and print the results", run: runQueryCommand},
+	"collect": {summary: "Run one collection and save the full report to a file", run: runCollectCommand},
+	"send":    {summary: "Send a report saved by `collect --out` to the configured sinks", run: runSendCommand},
+	"state":   {summary: "Show or rotate the key encrypting local agent state", run: runStateCommand},
+	"config":  {summary: "Validate, show or change the agent configuration", run: runConfigCommand},
+	"enroll":  {summary: "Set up this device for its user and start the agent", run: runEnrollCommand},
+	"sbom":    {summary: "Export installed software as a CycloneDX or SPDX SBOM", run: runSBOMCommand},
+}
+
+// dispatchCommand runs a subcommand if args start with one; ok is false for legacy flag usage
+func dispatchCommand(args []string) (exitCode int, ok bool) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return 0, false
+	}
+
+	if args[0] == "help" {
+		printCommandHelp()
+		return exitOK, true
+	}
+
+	cmd, found := commands[args[0]]
+	if !found {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", args[0])
+		printCommandHelp()
+		return exitUsage, true
+	}
+	return cmd.run(args[1:]), true
+}
+
+// printCommandHelp lists the available subcommands
+func printCommandHelp() {
+	fmt.Fprintln(os.Stderr, "Usage: scanx <command> [options]")
+	fmt.Fprintln(os.Stderr, "       scanx [-daemon|-test|-email <email>|...]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+// parseInterleaved parses flags that may appear before or after positional
+// arguments and returns the positional arguments in order
+func parseInterleaved(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
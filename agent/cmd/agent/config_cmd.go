@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"scanx/internal/config"
+	"scanx/internal/instance"
+	"scanx/internal/scheduler"
+	"scanx/internal/utils"
+)
+
+// maskedValue replaces secrets in `config show` output
+const maskedValue = "********"
+
+// overrideFlags collects repeatable -set field=value flags
+type overrideFlags map[string]string
+
+func (o overrideFlags) String() string {
+	pairs := make([]string, 0, len(o))
+	for field, value := range o {
+		pairs = append(pairs, field+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (o overrideFlags) Set(value string) error {
+	field, fieldValue, ok := strings.Cut(value, "=")
+	if !ok || field == "" {
+		return fmt.Errorf("expected field=value, got '%s'", value)
+	}
+	o[field] = fieldValue
+	return nil
+}
+
+// runConfigCommand implements `scanx config <action>`
+func runConfigCommand(args []string) int {
+	if len(args) == 0 {
+		printConfigUsage()
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "validate":
+		return runConfigValidate(args[1:])
+	case "show":
+		return runConfigShow(args[1:])
+	case "set", "unset":
+		return runConfigSet(args[0], args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown config action: %s\n\n", args[0])
+		printConfigUsage()
+		return exitUsage
+	}
+}
+
+// printConfigUsage lists the config actions
+func printConfigUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: scanx config validate [--dir <config dir>]")
+	fmt.Fprintln(os.Stderr, "       scanx config show [--effective] [--dir <config dir>] [--set field=value] [--format text|json]")
+	fmt.Fprintln(os.Stderr, "       scanx config set [--dir <config dir>] [--no-reload] <field> <value>")
+	fmt.Fprintln(os.Stderr, "       scanx config unset [--dir <config dir>] [--no-reload] <field>")
+	fmt.Fprintln(os.Stderr, "\nPrecedence (lowest first): defaults, agent.conf, agent.conf.d/*.conf, SCANX_<FIELD> variables, --set flags")
+}
+
+// runConfigValidate checks agent.conf, its drop-ins and SCANX_* variables and prints
+// every problem with its source and field path. It exits non-zero only for errors,
+// so warnings do not break CI pipelines.
+func runConfigValidate(args []string) int {
+	flags := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	dir := flags.String("dir", "", "Configuration directory containing agent.conf (default: search standard locations)")
+	flags.Usage = func() {
+		printConfigUsage()
+		flags.PrintDefaults()
+	}
+	if _, err := parseInterleaved(flags, args); err != nil {
+		return exitUsage
+	}
+
+	layered, issues, err := config.Resolve(config.LoadOptions{Dir: *dir})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, fs.ErrNotExist) {
+			return exitUnavailable
+		}
+		return exitFailure
+	}
+
+	// The cron parser lives with the scheduler, so the schedule is checked here
+	if expr := layered.Agent.Schedule; expr != "" {
+		source := layered.Sources["schedule"]
+		if schedule, err := scheduler.ParseCron(expr); err != nil {
+			issues = append(issues, config.Issue{Path: "schedule", Message: err.Error(), Severity: config.SeverityError, Source: source})
+		} else if schedule.Next(time.Now()).IsZero() {
+			issues = append(issues, config.Issue{Path: "schedule", Message: "cron expression never fires", Severity: config.SeverityError, Source: source})
+		}
+	}
+
+	for _, issue := range issues {
+		fmt.Println(issue.Describe())
+	}
+
+	configPath := filepath.Join(layered.Dir, "agent.conf")
+	if config.HasErrors(issues) {
+		fmt.Printf("%s: invalid (%d problem(s))\n", configPath, len(issues))
+		return exitFailure
+	}
+	fmt.Printf("%s: OK\n", configPath)
+	return exitOK
+}
+
+// runConfigShow prints agent.conf, or with --effective the merged configuration
+// and the source of each value. Secrets are masked.
+func runConfigShow(args []string) int {
+	flags := flag.NewFlagSet("config show", flag.ContinueOnError)
+	effective := flags.Bool("effective", false, "Show the merged configuration from every layer with the source of each value")
+	dir := flags.String("dir", "", "Configuration directory containing agent.conf (default: search standard locations)")
+	format := flags.String("format", "text", "Output format for --effective: text or json")
+	overrides := overrideFlags{}
+	flags.Var(overrides, "set", "Override a field as the agent's -set flag would (repeatable)")
+	flags.Usage = func() {
+		printConfigUsage()
+		flags.PrintDefaults()
+	}
+	if _, err := parseInterleaved(flags, args); err != nil {
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unsupported format '%s' (use text or json)\n", *format)
+		return exitUsage
+	}
+
+	layered, issues, err := config.Resolve(config.LoadOptions{Dir: *dir, Overrides: overrides})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, fs.ErrNotExist) {
+			return exitUnavailable
+		}
+		return exitFailure
+	}
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue.Describe())
+	}
+
+	if !*effective {
+		data, err := os.ReadFile(filepath.Join(layered.Dir, "agent.conf"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitFailure
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(data, &values); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitFailure
+		}
+		for field, value := range values {
+			values[field] = maskSecrets(field, value)
+		}
+		out, _ := json.MarshalIndent(values, "", "    ")
+		fmt.Println(string(out))
+		return exitOK
+	}
+
+	type entry struct {
+		Field  string          `json:"field"`
+		Value  json.RawMessage `json:"value"`
+		Source string          `json:"source"`
+	}
+	var entries []entry
+	for _, field := range config.FieldNames() {
+		value, ok := layered.Values[field]
+		if !ok {
+			continue
+		}
+		entries = append(entries, entry{Field: field, Value: maskSecrets(field, value), Source: layered.Sources[field]})
+	}
+
+	if *format == "json" {
+		out, _ := json.MarshalIndent(entries, "", "  ")
+		fmt.Println(string(out))
+		return exitOK
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tVALUE\tSOURCE")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Field, e.Value, e.Source)
+	}
+	tw.Flush()
+	return exitOK
+}
+
+// runConfigSet changes or removes one agent.conf field in the file that supplies
+// it, then asks a running daemon to reload
+func runConfigSet(action string, args []string) int {
+	flags := flag.NewFlagSet("config "+action, flag.ContinueOnError)
+	dir := flags.String("dir", "", "Configuration directory containing agent.conf (default: the running daemon's, else search standard locations)")
+	noReload := flags.Bool("no-reload", false, "Do not ask a running daemon to reload")
+	flags.Usage = func() {
+		printConfigUsage()
+		flags.PrintDefaults()
+	}
+	positional, err := parseInterleaved(flags, args)
+	if err != nil {
+		return exitUsage
+	}
+
+	wantArgs := 2
+	if action == "unset" {
+		wantArgs = 1
+	}
+	if len(positional) != wantArgs {
+		flags.Usage()
+		return exitUsage
+	}
+	field := positional[0]
+
+	// Without --dir, edit the files the running daemon loaded rather than
+	// whatever the search finds for this user
+	if *dir == "" {
+		if daemonDir := runningDaemonConfigDir(); daemonDir != "" {
+			*dir = daemonDir
+			fmt.Printf("Using the running daemon's config directory %s\n", daemonDir)
+		}
+	}
+
+	if action == "set" {
+		path, err := config.SetField(*dir, field, positional[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return configEditExitCode(err)
+		}
+		fmt.Printf("Set %s in %s\n", field, path)
+	} else {
+		paths, err := config.UnsetField(*dir, field)
+		for _, path := range paths {
+			fmt.Printf("Removed %s from %s\n", field, path)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return configEditExitCode(err)
+		}
+		if len(paths) == 0 {
+			fmt.Printf("%s is not set in any config file\n", field)
+		}
+	}
+
+	// Environment variables and flags still win over the files
+	if layered, _, err := config.Resolve(config.LoadOptions{Dir: *dir}); err == nil {
+		if source := layered.Sources[field]; strings.HasPrefix(source, "env ") {
+			fmt.Printf("Warning: %s is overridden by %s\n", field, strings.TrimPrefix(source, "env "))
+		}
+	}
+
+	if *noReload {
+		return exitOK
+	}
+	stateDir, err := utils.EnsureStateDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not ask the daemon to reload: %v\n", err)
+		return exitOK
+	}
+	switch err := instance.RequestReload(stateDir); {
+	case errors.Is(err, instance.ErrNotRunning):
+		fmt.Println("No running daemon found; the change applies when the agent next starts")
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Warning: could not ask the daemon to reload: %v\n", err)
+	default:
+		fmt.Println("Asked the running daemon to reload its configuration")
+	}
+	return exitOK
+}
+
+// runningDaemonConfigDir returns the config directory recorded by a running
+// daemon, or "" if there is none
+func runningDaemonConfigDir() string {
+	stateDir, err := utils.EnsureStateDir()
+	if err != nil || !instance.IsHeld(stateDir, instance.DaemonLockFile) {
+		return ""
+	}
+	holder, err := instance.ReadHolder(stateDir, instance.DaemonLockFile)
+	if err != nil {
+		return ""
+	}
+	return holder.ConfigDir
+}
+
+// configEditExitCode maps config edit errors to exit codes
+func configEditExitCode(err error) int {
+	if errors.Is(err, fs.ErrNotExist) {
+		return exitUnavailable
+	}
+	return exitFailure
+}
+
+// maskSecrets hides the redaction salt and sink tokens and secrets
+func maskSecrets(field string, value json.RawMessage) json.RawMessage {
+	switch field {
+	case "redaction_salt":
+		masked, _ := json.Marshal(maskedValue)
+		return masked
+	case "sinks":
+		var sinks []config.SinkConfig
+		if err := json.Unmarshal(value, &sinks); err != nil {
+			return value
+		}
+		for i := range sinks {
+			if sinks[i].Token != "" {
+				sinks[i].Token = maskedValue
+			}
+			if sinks[i].Secret != "" {
+				sinks[i].Secret = maskedValue
+			}
+		}
+		masked, err := json.Marshal(sinks)
+		if err != nil {
+			return value
+		}
+		return masked
+	}
+
+	// Compact so every value fits on one line
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		return value
+	}
+	return compact.Bytes()
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"scanx/internal/collector"
+	"scanx/internal/config"
+	"scanx/internal/instance"
+	"scanx/internal/sender"
+	"scanx/internal/utils"
+)
+
+// enrollAttempts is how many email addresses the interactive wizard accepts before giving up
+const enrollAttempts = 3
+
+// enrollCheckTimeout bounds the backend pre-check
+const enrollCheckTimeout = 30 * time.Second
+
+// runEnrollCommand implements `scanx enroll`: it picks the user's email, checks it
+// with the backend, shows the device that will be registered and only then
+// writes the config and starts the service
+func runEnrollCommand(args []string) int {
+	flags := flag.NewFlagSet("enroll", flag.ContinueOnError)
+	email := flags.String("email", "", "Employee email (default: logged-in user at --domain)")
+	domain := flags.String("domain", "", "Company email domain used to guess the email (default: enrollment_domain)")
+	backendURL := flags.String("backend-url", "", "Backend URL to enroll with and save (default: backend_url)")
+	configDir := flags.String("config", "", "Custom configuration directory path")
+	nonInteractive := flags.Bool("non-interactive", false, "Never prompt; for MDM pushes (implied when stdin is not a terminal)")
+	skipCheck := flags.Bool("skip-check", false, "Do not pre-validate the email with the backend")
+	noStart := flags.Bool("no-start", false, "Write the configuration but do not start or reload the agent service")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: scanx enroll [--email <email> | --domain <domain>] [--non-interactive] [options]")
+		flags.PrintDefaults()
+	}
+	positional, err := parseInterleaved(flags, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) > 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	interactive := !*nonInteractive && stdinIsTerminal()
+	in := bufio.NewReader(os.Stdin)
+
+	cfg, code := loadEnrollConfig(*configDir)
+	if cfg == nil {
+		return code
+	}
+	if *backendURL != "" {
+		cfg.Agent.BackendURL = *backendURL
+	}
+	if *domain == "" {
+		*domain = cfg.Agent.EnrollmentDomain
+	}
+	for _, issue := range (&config.AgentConfig{BackendURL: *backendURL, EnrollmentDomain: *domain}).Validate() {
+		fmt.Fprintf(os.Stderr, "Error: %s: %s\n", issue.Path, issue.Message)
+		return exitUsage
+	}
+
+	// The serial number is what the backend keys devices on, so it comes from osquery like in reports
+	fmt.Println("Collecting device identity...")
+	deviceCollector, err := collector.NewCollector(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to read device identity: %v\n", err)
+		return exitUnavailable
+	}
+	device := deviceCollector.GetSystemInfo()
+
+	guess := *email
+	if guess == "" && *domain != "" {
+		guess = guessEmail(*domain)
+	}
+	if !interactive && guess == "" {
+		fmt.Fprintln(os.Stderr, "Error: --email or --domain is required in non-interactive mode")
+		return exitUsage
+	}
+
+	backend := sender.NewBackendSender(sender.GetBackendURLFromConfig(cfg))
+	chosen := ""
+	for attempt := 1; chosen == "" && attempt <= enrollAttempts; attempt++ {
+		candidate := guess
+		if interactive {
+			candidate = prompt(in, "Work email", guess)
+		}
+
+		if !config.ValidEmail(candidate) {
+			fmt.Fprintf(os.Stderr, "✗ '%s' is not a valid email address\n", candidate)
+			if !interactive {
+				return exitUsage
+			}
+			continue
+		}
+
+		if *skipCheck {
+			chosen = candidate
+			break
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), enrollCheckTimeout)
+		check, err := backend.CheckEnrollment(ctx, candidate, device.SerialNo)
+		cancel()
+		switch {
+		case errors.Is(err, sender.ErrCheckUnsupported):
+			fmt.Println("⚠️  The backend cannot pre-validate emails; continuing without the check")
+			chosen = candidate
+		case errors.Is(err, sender.ErrCheckRateLimited):
+			// Retrying another address would only be refused again
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			return exitUnavailable
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", candidate, err)
+			if !interactive {
+				return exitFailure
+			}
+			// A rejected guess should not be offered again
+			guess = ""
+		default:
+			if check.Name != "" {
+				fmt.Printf("✓ %s belongs to %s\n", candidate, check.Name)
+			} else {
+				fmt.Printf("✓ %s can be enrolled\n", candidate)
+			}
+			if check.Registered {
+				fmt.Println("  This device is already registered; its record will be updated")
+			}
+			chosen = candidate
+		}
+	}
+	if chosen == "" {
+		fmt.Fprintln(os.Stderr, "Enrollment aborted: no usable email address")
+		return exitFailure
+	}
+
+	fmt.Println()
+	fmt.Println("Device to register:")
+	fmt.Printf("  User email:    %s\n", chosen)
+	fmt.Printf("  Computer name: %s\n", device.ComputerName)
+	fmt.Printf("  Serial number: %s\n", device.SerialNo)
+	fmt.Printf("  Device ID:     %s\n", device.DeviceID)
+	fmt.Printf("  OS:            %s %s\n", device.OSType, device.OSVersion)
+	fmt.Printf("  Backend:       %s\n", sender.GetBackendURLFromConfig(cfg))
+	fmt.Println()
+
+	if interactive && !confirm(in, "Save this configuration and start the agent?") {
+		fmt.Println("Enrollment cancelled; nothing was changed")
+		return exitFailure
+	}
+
+	settings := [][2]string{{"user_email", chosen}}
+	if *backendURL != "" {
+		settings = append(settings, [2]string{"backend_url", *backendURL})
+	}
+	if *domain != "" && *domain != cfg.Agent.EnrollmentDomain {
+		settings = append(settings, [2]string{"enrollment_domain", *domain})
+	}
+	for _, setting := range settings {
+		path, err := config.SetField(cfg.Dir, setting[0], setting[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to save %s: %v\n", setting[0], err)
+			return exitFailure
+		}
+		fmt.Printf("Saved %s to %s\n", setting[0], path)
+	}
+
+	if *noStart {
+		return exitOK
+	}
+	return startEnrolledAgent()
+}
+
+// loadEnrollConfig resolves the layered configuration. Problems with user_email
+// are expected before enrollment and are ignored; anything else must be fixed first.
+func loadEnrollConfig(configDir string) (*config.Config, int) {
+	layered, issues, err := config.Resolve(config.LoadOptions{Dir: configDir})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, exitUnavailable
+		}
+		return nil, exitFailure
+	}
+
+	invalid := false
+	for _, issue := range issues {
+		if issue.Severity == config.SeverityError && issue.Path != "user_email" {
+			fmt.Fprintln(os.Stderr, issue.Describe())
+			invalid = true
+		}
+	}
+	if invalid {
+		fmt.Fprintln(os.Stderr, "Error: fix the configuration above before enrolling")
+		return nil, exitFailure
+	}
+
+	return &config.Config{Agent: layered.Agent, Queries: *config.GetQueriesConfig(), Dir: layered.Dir}, exitOK
+}
+
+// startEnrolledAgent reloads a running daemon or starts the agent service
+func startEnrolledAgent() int {
+	stateDir, err := utils.EnsureStateDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+
+	if instance.IsHeld(stateDir, instance.DaemonLockFile) {
+		if err := instance.RequestReload(stateDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: could not ask the running agent to reload: %v\n", err)
+			return exitFailure
+		}
+		fmt.Println("✅ Enrolled; the running agent is reloading its configuration")
+		return exitOK
+	}
+
+	if err := startAgentService(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: configuration saved but the agent service did not start: %v\n", err)
+		return exitFailure
+	}
+	fmt.Println("✅ Enrolled; the agent service is running")
+	return exitOK
+}
+
+// guessEmail builds an address from the logged-in user's name and the company domain
+func guessEmail(domain string) string {
+	username, err := (&collector.OSQueryRunner{}).LoggedInUser()
+	if err != nil || username == "" || username == "root" {
+		// Under sudo the session lookup may only find root
+		username = os.Getenv("SUDO_USER")
+	}
+
+	// Windows names come as DOMAIN\user
+	if i := strings.LastIndex(username, `\`); i >= 0 {
+		username = username[i+1:]
+	}
+	if username == "" || username == "root" {
+		return ""
+	}
+	return strings.ToLower(username) + "@" + strings.ToLower(domain)
+}
+
+// stdinIsTerminal reports whether the wizard can prompt
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// prompt asks for a value, returning def when the answer is empty
+func prompt(in *bufio.Reader, question, def string) string {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	answer, _ := in.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def
+	}
+	return answer
+}
+
+// confirm asks a yes/no question that defaults to yes
+func confirm(in *bufio.Reader, question string) bool {
+	answer := strings.ToLower(prompt(in, question+" [Y/n]", ""))
+	return answer == "" || answer == "y" || answer == "yes"
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -8,15 +10,22 @@ import (
 	"os/signal"
 	"runtime"
 	"syscall"
+	"time"
 
 	"scanx/internal/collector"
 	"scanx/internal/config"
+	"scanx/internal/instance"
 	"scanx/internal/scheduler"
 	"scanx/internal/sender"
 	"scanx/internal/utils"
 )
 
 func main() {
+	// Subcommands (e.g. `scanx query`) have their own flags; otherwise fall back to the legacy flags
+	if code, ok := dispatchCommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
 	// Parse command line flags
 	var (
 		email      = flag.String("email", "", "Employee email for device identification")
@@ -25,7 +34,9 @@ func main() {
 		test       = flag.Bool("test", false, "Test mode: run single data collection and exit")
 		service    = flag.String("service", "", "Service management: install, uninstall, start, stop, status")
 		configPath = flag.String("config", "", "Custom configuration directory path")
+		overrides  = overrideFlags{}
 	)
+	flag.Var(overrides, "set", "Override an agent.conf field, e.g. -set log_level=debug (repeatable)")
 	flag.Parse()
 
 	// Service management mode (not implemented - use installation scripts instead)
@@ -47,23 +58,16 @@ func main() {
 
 	// If email provided, update configuration and exit
 	if *email != "" {
-		if err := config.UpdateUserEmail(*email); err != nil {
+		if err := config.UpdateUserEmail(*configPath, *email); err != nil {
 			log.Fatalf("Failed to update user email: %v", err)
 		}
 		fmt.Printf("Successfully updated user email to: %s\n", *email)
 		return
 	}
 
-	// Load configuration first (needed for log level)
-	var cfg *config.Config
-	var err error
-
-	if *configPath != "" {
-		cfg, err = config.LoadConfigFromPath(*configPath)
-	} else {
-		cfg, err = config.LoadConfig()
-	}
-
+	// Load layered configuration first (needed for log level)
+	loadOpts := config.LoadOptions{Dir: *configPath, Overrides: overrides}
+	cfg, err := config.Load(loadOpts)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
@@ -80,6 +84,40 @@ func main() {
 	utils.Info("  Version: %s", cfg.Agent.Version)
 	utils.Info("  Interval: %s", cfg.Agent.Interval)
 	utils.Info("  Log Level: %s", cfg.Agent.LogLevel)
+	utils.Info("  Config Dir: %s", cfg.Dir)
+
+	// Only the daemon strictly needs the state directory; one-shot runs go ahead
+	// without the collection lock when it cannot be created
+	stateDir, err := utils.EnsureStateDir()
+	if err != nil {
+		if *daemon {
+			log.Fatalf("Failed to prepare state directory: %v", err)
+		}
+		utils.Warning("State directory unavailable, not coordinating with a running daemon: %v", err)
+	}
+
+	// Only one daemon may run; it holds the lock until exit
+	if *daemon {
+		daemonLock, err := instance.TryAcquire(stateDir, instance.DaemonLockFile, "daemon")
+		if err != nil {
+			if errors.Is(err, instance.ErrLocked) {
+				utils.Error("Another scanx daemon is already running: %v", err)
+				log.Fatalf("Another scanx daemon is already running (%v). Stop the service before starting a second daemon.", err)
+			}
+			log.Fatalf("Failed to acquire daemon lock: %v", err)
+		}
+		defer daemonLock.Release()
+
+		// config set/unset edit the directory the daemon actually loaded
+		if cfg.Dir != "" {
+			if err := daemonLock.SetConfigDir(cfg.Dir); err != nil {
+				utils.Warning("Could not record config directory in daemon lock: %v", err)
+			}
+		}
+
+		// Rotate an aged state key before the first collection starts
+		rotateStateKeyIfDue(cfg, stateDir)
+	}
 
 	// Initialize collector
 	collector, err := collector.NewCollector(cfg)
@@ -97,10 +135,27 @@ func main() {
 	utils.Info("OSQuery Path: %s", collector.GetOSQueryPath())
 	utils.Info("System Info: %+v", collector.GetSystemInfo())
 
+	// One-shot runs abort (and kill osquery children) on Ctrl+C or SIGTERM
+	runCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	// One-shot runs take the collection lock so they never overlap a daemon cycle
+	if !*daemon && stateDir != "" {
+		mode := "oneshot"
+		if *test {
+			mode = "test"
+		}
+		collectLock, err := acquireCollectionLock(runCtx, cfg, stateDir, mode)
+		if err != nil {
+			log.Fatalf("Failed to acquire collection lock: %v", err)
+		}
+		defer collectLock.Release()
+	}
+
 	// Test mode: run single collection and backend transmission test
 	if *test {
 		utils.Info("Running single data collection and transmission test...")
-		data, err := collector.CollectData()
+		data, err := collector.CollectData(runCtx)
 		if err != nil {
 			utils.Error("Failed to collect data: %v", err)
 			log.Fatalf("Failed to collect data: %v", err)
@@ -112,30 +167,31 @@ func main() {
 		utils.Info("  OS Type: %s", data.OSType)
 		utils.Info("  OS Version: %s", data.OSVersion)
 		utils.Info("  Serial No: %s", data.SerialNo)
+		utils.Info("  Device ID: %s", data.DeviceID)
 		utils.Info("  Timestamp: %s", data.Timestamp)
 		utils.Info("  Queries executed: %d", len(data.Data))
 
 		for queryName, results := range data.Data {
-			utils.Info("    %s: %d records", queryName, len(results))
+			utils.Info("    %s: %d records (%s)", queryName, len(results), data.QueryMeta[queryName].Status)
 		}
 
-		// Test backend transmission
+		// Test transmission to every configured sink
 		utils.Info("📡 Testing backend transmission...")
-		backendURL := sender.GetBackendURLFromConfig(cfg)
-		backendSender := sender.NewBackendSender(backendURL)
+		sinks := sender.NewDispatcherFromConfig(cfg)
 
 		// Test connection first
-		if err := backendSender.TestConnection(); err != nil {
+		if err := sinks.TestConnection(); err != nil {
 			utils.Error("❌ Backend connection test failed: %v", err)
 		} else {
 			utils.Info("✅ Backend connection test successful")
 		}
 
 		// Send data
-		if err := backendSender.SendAgentData(data); err != nil {
-			utils.Error("❌ Failed to send data to backend: %v", err)
+		if err := sinks.Send(runCtx, data); err != nil {
+			utils.Error("❌ Failed to send data: %v", err)
 		} else {
-			utils.Info("✅ Successfully sent data to backend!")
+			collector.AcknowledgeHardwareChange()
+			utils.Info("✅ Successfully sent data to all sinks!")
 		}
 
 		utils.Info("🎯 Test completed successfully!")
@@ -144,11 +200,12 @@ func main() {
 
 	// Daemon mode: periodic data collection
 	if *daemon {
-		runDaemon(cfg, collector)
+		stopSignals()
+		runDaemon(loadOpts, cfg, collector, stateDir)
 	} else {
 		// Default: single run for backward compatibility
 		utils.Info("Collecting system data...")
-		data, err := collector.CollectData()
+		data, err := collector.CollectData(runCtx)
 		if err != nil {
 			utils.Error("Failed to collect data: %v", err)
 			log.Fatalf("Failed to collect data: %v", err)
@@ -160,39 +217,90 @@ func main() {
 		utils.Info("  OS Type: %s", data.OSType)
 		utils.Info("  OS Version: %s", data.OSVersion)
 		utils.Info("  Serial No: %s", data.SerialNo)
+		utils.Info("  Device ID: %s", data.DeviceID)
 		utils.Info("  Timestamp: %s", data.Timestamp)
 		utils.Info("  Queries executed: %d", len(data.Data))
 
 		for queryName, results := range data.Data {
-			utils.Info("    %s: %d records", queryName, len(results))
+			utils.Info("    %s: %d records (%s)", queryName, len(results), data.QueryMeta[queryName].Status)
 		}
 
 		utils.Info("Agent run completed successfully!")
 	}
 }
 
-// runDaemon runs the agent in daemon mode with periodic data collection
-func runDaemon(cfg *config.Config, collector *collector.Collector) {
+// acquireCollectionLock waits for any in-flight daemon collection before a one-shot run
+func acquireCollectionLock(ctx context.Context, cfg *config.Config, stateDir string, mode string) (*instance.Lock, error) {
+	if instance.IsHeld(stateDir, instance.DaemonLockFile) {
+		if holder, err := instance.ReadHolder(stateDir, instance.DaemonLockFile); err == nil {
+			utils.Info("scanx daemon is running (pid %d); coordinating via collection lock", holder.PID)
+		}
+	}
+
+	lock, err := instance.TryAcquire(stateDir, instance.CollectionLockFile, mode)
+	if err == nil || !errors.Is(err, instance.ErrLocked) {
+		return lock, err
+	}
+
+	utils.Info("A collection is already in progress (%v); waiting for it to finish...", err)
+	return instance.Acquire(ctx, stateDir, instance.CollectionLockFile, mode, cfg.GetCollectionTimeout()+time.Minute)
+}
+
+// runDaemon runs the agent in daemon mode with periodic data collection. A reload
+// request (SIGHUP or `scanx config set`) re-reads the layered configuration and
+// restarts the scheduler; an invalid configuration keeps the current one running.
+func runDaemon(loadOpts config.LoadOptions, cfg *config.Config, collectorInstance *collector.Collector, stateDir string) {
 	// Create scheduler with configured interval
 	interval := cfg.GetInterval()
-	sch := scheduler.NewScheduler(cfg, collector, interval)
+	sch := scheduler.NewScheduler(cfg, collectorInstance, interval)
 
 	// Setup signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	reloads := instance.WatchReload(reloadCtx, stateDir)
+
 	// Start scheduler in goroutine
 	go sch.Start()
 
 	utils.Info("Agent running in daemon mode with %v interval", interval)
 	utils.Info("Press Ctrl+C to stop...")
 
-	// Wait for shutdown signal
-	<-sigChan
-
-	utils.Info("Shutdown signal received...")
-	sch.Stop()
-	utils.Info("Agent stopped gracefully")
+	for {
+		select {
+		case <-reloads:
+			utils.Info("Reload requested, re-reading configuration...")
+			newCfg, err := config.Load(loadOpts)
+			if err != nil {
+				utils.Error("Keeping current configuration, reload failed: %v", err)
+				continue
+			}
+			newCollector, err := collector.NewCollector(newCfg)
+			if err == nil {
+				err = newCollector.ValidateConfiguration()
+			}
+			if err != nil {
+				utils.Error("Keeping current configuration, collector setup failed: %v", err)
+				continue
+			}
+
+			// An in-flight cycle gets the shutdown grace period to finish with the old configuration
+			sch.Stop()
+			utils.SetLogLevel(newCfg.GetLogLevel())
+			interval = newCfg.GetInterval()
+			sch = scheduler.NewScheduler(newCfg, newCollector, interval)
+			go sch.Start()
+			utils.Info("Configuration reloaded, collecting with %v interval", interval)
+		case <-sigChan:
+			// Wait for shutdown signal
+			utils.Info("Shutdown signal received...")
+			sch.Stop()
+			utils.Info("Agent stopped gracefully")
+			return
+		}
+	}
 }
 
 // installAgent handles the installation process
@@ -224,7 +332,7 @@ func installAgent(email string) error {
 
 	// Update email if provided
 	if email != "" {
-		if err := config.UpdateUserEmail(email); err != nil {
+		if err := config.UpdateUserEmail("config", email); err != nil {
 			return fmt.Errorf("failed to set user email: %w", err)
 		}
 		fmt.Printf("Set user email to: %s\n", email)
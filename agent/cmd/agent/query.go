@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"scanx/internal/collector"
+	"scanx/internal/config"
+)
+
+// runQueryCommand implements `scanx query "<sql>"` and `scanx query --name <query>`
+func runQueryCommand(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	var (
+		name      = fs.String("name", "", "Run a named query from the agent's query set (e.g. apps_info)")
+		format    = fs.String("format", "table", "Output format: table, json, ndjson or csv")
+		asUser    = fs.String("as-user", "", "Run the query as this user (requires root)")
+		timeout   = fs.Duration("timeout", config.DefaultQueryTimeout, "Query timeout")
+		configDir = fs.String("config", "", "Custom configuration directory path")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: scanx query [options] \"<sql>\"")
+		fmt.Fprintln(os.Stderr, "       scanx query [options] --name <query>")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterleaved(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+
+	sql := strings.Join(positional, " ")
+	if (sql == "") == (*name == "") {
+		fmt.Fprintln(os.Stderr, "Error: provide either SQL or --name, but not both")
+		fs.Usage()
+		return exitUsage
+	}
+
+	switch *format {
+	case "table", "json", "ndjson", "csv":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown format '%s'\n", *format)
+		return exitUsage
+	}
+
+	queryName := "adhoc"
+	userContext := false
+	if *name != "" {
+		queryConfig, err := lookupNamedQuery(*configDir, *name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitUsage
+		}
+		queryName, sql, userContext = *name, queryConfig.Query, queryConfig.UserContext
+	}
+
+	runner, err := collector.NewOSQueryRunner()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var rows []map[string]interface{}
+	switch {
+	case *asUser != "":
+		rows, err = runner.ExecuteQueryAsUser(ctx, queryName, sql, *asUser)
+	case userContext:
+		rows, err = runner.ExecuteQueryForUsers(ctx, queryName, sql)
+	default:
+		rows, err = runner.ExecuteQuery(ctx, queryName, sql)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return exitTimeout
+		}
+		return exitFailure
+	}
+
+	if err := writeRows(os.Stdout, *format, rows); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write output: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// lookupNamedQuery finds a query for this platform, applying agent.conf overrides when available
+func lookupNamedQuery(configDir string, name string) (config.QueryConfig, error) {
+	var loaded *config.Config
+	var err error
+	if configDir != "" {
+		loaded, err = config.LoadConfigFromPath(configDir)
+	} else {
+		loaded, err = config.LoadConfig()
+	}
+
+	// Ad-hoc queries do not need agent.conf; fall back to the embedded queries
+	cfg := &config.Config{Queries: *config.GetQueriesConfig()}
+	if err == nil {
+		cfg = loaded
+	}
+
+	queries, err := cfg.GetPlatformQueries()
+	if err != nil {
+		return config.QueryConfig{}, err
+	}
+
+	queryConfig, ok := queries[name]
+	if !ok {
+		names := make([]string, 0, len(queries))
+		for queryName := range queries {
+			names = append(names, queryName)
+		}
+		sort.Strings(names)
+		return config.QueryConfig{}, fmt.Errorf("unknown query '%s' (available: %s)", name, strings.Join(names, ", "))
+	}
+	return queryConfig, nil
+}
+
+// writeRows renders query results in the requested format
+func writeRows(w io.Writer, format string, rows []map[string]interface{}) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+
+	case "ndjson":
+		encoder := json.NewEncoder(w)
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "csv":
+		columns := rowColumns(rows)
+		writer := csv.NewWriter(w)
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := writer.Write(rowValues(row, columns)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+
+	default:
+		columns := rowColumns(rows)
+		if len(columns) == 0 {
+			_, err := fmt.Fprintln(w, "(no rows)")
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(columns, "\t"))
+		underline := make([]string, len(columns))
+		for i, column := range columns {
+			underline[i] = strings.Repeat("-", len(column))
+		}
+		fmt.Fprintln(tw, strings.Join(underline, "\t"))
+		for _, row := range rows {
+			values := rowValues(row, columns)
+			for i, value := range values {
+				// Keep the table aligned when values contain tabs or newlines
+				values[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(value)
+			}
+			fmt.Fprintln(tw, strings.Join(values, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "(%d rows)\n", len(rows))
+		return err
+	}
+}
+
+// rowColumns returns the sorted union of column names across rows
+func rowColumns(rows []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range rows {
+		for column := range row {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// rowValues returns a row's values as strings in column order
+func rowValues(row map[string]interface{}, columns []string) []string {
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		if value, ok := row[column]; ok && value != nil {
+			values[i] = fmt.Sprint(value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"scanx/internal/collector"
+	"scanx/internal/config"
+	"scanx/internal/report"
+	"scanx/internal/sender"
+	"scanx/internal/signing"
+	"scanx/internal/utils"
+	"scanx/pkg/payloadsig"
+)
+
+// runCollectCommand implements `scanx collect --out <file>`, which runs one
+// collection and saves the full report for offline transfer
+func runCollectCommand(args []string) int {
+	fs := flag.NewFlagSet("collect", flag.ContinueOnError)
+	var (
+		out       = fs.String("out", "", "Write the full report to this file (required)")
+		compress  = fs.Bool("gzip", false, "Gzip-compress the report (implied by a .gz suffix)")
+		sign      = fs.Bool("sign", false, "Write a detached ed25519 signature to <file>.sig")
+		configDir = fs.String("config", "", "Custom configuration directory path")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: scanx collect --out <file> [--gzip] [--sign]")
+		fs.PrintDefaults()
+	}
+
+	if _, err := parseInterleaved(fs, args); err != nil {
+		return exitUsage
+	}
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "Error: --out is required")
+		fs.Usage()
+		return exitUsage
+	}
+
+	cfg, stateDir, code := initCommand(*configDir)
+	if code != exitOK {
+		return code
+	}
+	defer utils.CloseLogger()
+
+	var signingKey []byte
+	if *sign {
+		if stateDir == "" {
+			utils.Error("--sign needs the state directory, which holds the device signing key")
+			return exitFailure
+		}
+		key, err := signing.LoadOrCreateKey(stateDir)
+		if err != nil {
+			utils.Error("Failed to load signing key: %v", err)
+			return exitFailure
+		}
+		signingKey = key
+	}
+
+	agentCollector, err := collector.NewCollector(cfg)
+	if err != nil {
+		utils.Error("Failed to initialize collector: %v", err)
+		return exitUnavailable
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if stateDir != "" {
+		collectLock, err := acquireCollectionLock(ctx, cfg, stateDir, "collect")
+		if err != nil {
+			utils.Error("Failed to acquire collection lock: %v", err)
+			return exitFailure
+		}
+		defer collectLock.Release()
+	}
+
+	utils.Info("Collecting system data...")
+	data, err := agentCollector.CollectData(ctx)
+	if err != nil {
+		utils.Error("Failed to collect data: %v", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return exitTimeout
+		}
+		return exitFailure
+	}
+
+	opts := report.WriteOptions{
+		Gzip:       *compress || strings.HasSuffix(*out, ".gz"),
+		SigningKey: signingKey,
+	}
+	if err := report.Write(*out, data, opts); err != nil {
+		utils.Error("Failed to save report: %v", err)
+		return exitFailure
+	}
+
+	utils.Info("Saved report for device %s (%d queries) to %s", data.DeviceID, len(data.Data), *out)
+	if *sign {
+		utils.Info("Signature written to %s", report.SignaturePath(*out))
+	}
+	return exitOK
+}
+
+// runSendCommand implements `scanx send --file <file>`, which submits a report
+// saved earlier by `scanx collect --out`
+func runSendCommand(args []string) int {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	var (
+		file             = fs.String("file", "", "Report file written by `scanx collect --out` (required)")
+		requireSignature = fs.Bool("require-signature", false, "Refuse reports without a valid <file>.sig")
+		trustedKeys      = fs.String("trusted-key", "", "Comma-separated key fingerprints of other devices whose signed reports are accepted")
+		configDir        = fs.String("config", "", "Custom configuration directory path")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: scanx send --file <file> [--require-signature] [--trusted-key <fingerprint>,...]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterleaved(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if *file == "" && len(positional) == 1 {
+		*file = positional[0]
+	}
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "Error: --file is required")
+		fs.Usage()
+		return exitUsage
+	}
+
+	cfg, stateDir, code := initCommand(*configDir)
+	if code != exitOK {
+		return code
+	}
+	defer utils.CloseLogger()
+
+	// Signatures only count when made by this device or a key pinned in config or on the command line
+	var trusted []string
+	if stateDir != "" {
+		deviceKey, err := signing.LoadOrCreateKey(stateDir)
+		if err != nil {
+			utils.Error("Failed to load signing key: %v", err)
+			return exitFailure
+		}
+		trusted = append(trusted, payloadsig.Fingerprint(deviceKey.Public().(ed25519.PublicKey)))
+	} else {
+		utils.Warning("Without the state directory only pinned keys are trusted for report signatures")
+	}
+	trusted = append(trusted, cfg.Agent.TrustedReportKeys...)
+	for _, fingerprint := range strings.Split(*trustedKeys, ",") {
+		if fingerprint = strings.TrimSpace(fingerprint); fingerprint != "" {
+			trusted = append(trusted, fingerprint)
+		}
+	}
+
+	data, sig, err := report.Read(*file, trusted)
+	if err != nil {
+		utils.Error("Failed to load report %s: %v", *file, err)
+		return exitFailure
+	}
+
+	dispatcher := sender.NewDispatcherFromConfig(cfg)
+	if sig == nil {
+		if *requireSignature {
+			utils.Error("Report %s is not signed (expected %s)", *file, report.SignaturePath(*file))
+			return exitFailure
+		}
+		utils.Warning("Report %s is not signed", *file)
+	} else {
+		utils.Info("Report signature verified (key fingerprint %s)", sig.KeyID)
+		if code := forwardReportSignature(dispatcher, data, sig, trusted); code != exitOK {
+			return code
+		}
+	}
+
+	utils.Info("Sending report for device %s collected at %s", data.DeviceID, data.Timestamp)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := dispatcher.Send(ctx, data); err != nil {
+		utils.Error("Failed to send report: %v", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// forwardReportSignature passes a verified report signature on to the sinks that
+// accept it. The signature covers the canonical report JSON, so it is only
+// forwarded if the re-encoded report still matches it byte for byte.
+func forwardReportSignature(dispatcher *sender.Dispatcher, data *collector.CollectedData, sig *report.Signature, trusted []string) int {
+	body, err := json.Marshal(data)
+	if err != nil {
+		utils.Error("Failed to encode report: %v", err)
+		return exitFailure
+	}
+	canonical, err := payloadsig.Canonicalize(body)
+	if err != nil {
+		utils.Error("Failed to encode report: %v", err)
+		return exitFailure
+	}
+	if err := report.Verify(canonical, sig, trusted); err != nil {
+		utils.Warning("Not forwarding the report signature, it does not cover the report as sent: %v", err)
+		return exitOK
+	}
+
+	encoded, err := sig.Encode()
+	if err != nil {
+		utils.Error("%v", err)
+		return exitFailure
+	}
+	if dispatcher.ForwardReportSignature(encoded) == 0 {
+		utils.Warning("None of the configured sinks can forward the report signature")
+	}
+	return exitOK
+}
+
+// initCommand loads configuration, starts logging and prepares the state
+// directory for subcommands that need the full agent environment. The state
+// directory is empty when it cannot be created; callers skip what needs it.
+func initCommand(configDir string) (*config.Config, string, int) {
+	var cfg *config.Config
+	var err error
+	if configDir != "" {
+		cfg, err = config.LoadConfigFromPath(configDir)
+	} else {
+		cfg, err = config.LoadConfig()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load configuration: %v\n", err)
+		return nil, "", exitUnavailable
+	}
+
+	if err := utils.InitLoggerWithLevel(cfg.GetLogLevel()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to initialize system logger: %v\n", err)
+	}
+
+	stateDir, err := utils.EnsureStateDir()
+	if err != nil {
+		utils.Warning("State directory unavailable: %v", err)
+	}
+	return cfg, stateDir, exitOK
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"scanx/internal/collector"
+	"scanx/internal/report"
+	"scanx/internal/sbom"
+	"scanx/internal/software"
+	"scanx/internal/utils"
+)
+
+// runSBOMCommand implements `scanx sbom`, which exports the device's installed
+// software as a CycloneDX or SPDX document, live or from a saved report
+func runSBOMCommand(args []string) int {
+	fs := flag.NewFlagSet("sbom", flag.ContinueOnError)
+	var (
+		format    = fs.String("format", sbom.FormatCycloneDX, "SBOM format: "+strings.Join(sbom.Formats, " or "))
+		out       = fs.String("out", "", "Write the SBOM to this file instead of stdout")
+		from      = fs.String("from", "", "Build the SBOM from a report saved by `scanx collect --out` instead of querying this device")
+		configDir = fs.String("config", "", "Custom configuration directory path")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: scanx sbom [--format cyclonedx|spdx] [--out <file>] [--from <report>]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterleaved(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if *format != sbom.FormatCycloneDX && *format != sbom.FormatSPDX {
+		fmt.Fprintf(os.Stderr, "Error: unknown format '%s'\n", *format)
+		return exitUsage
+	}
+
+	// Keep stdout for the document alone; logs and progress go to stderr
+	utils.SetConsoleOutput(os.Stderr)
+	var w io.Writer = os.Stdout
+
+	var device sbom.Device
+	var items []software.Item
+	if *from != "" {
+		// Only the inventory is used, so the report's signature is not checked
+		data, _, err := report.Read(*from, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitFailure
+		}
+		device = sbom.Device{
+			Name:         data.ComputerName,
+			DeviceID:     data.DeviceID,
+			SerialNo:     data.SerialNo,
+			OSType:       data.OSType,
+			OSVersion:    data.OSVersion,
+			AgentVersion: data.Version,
+		}
+		items = data.Software
+		if items == nil {
+			// Reports from older agents carry only the raw rows; the distribution is unknown
+			items = software.Normalize(data.Data, software.Distro{})
+		}
+	} else {
+		var code int
+		device, items, code = collectSBOMSoftware(*configDir)
+		if code != exitOK {
+			return code
+		}
+	}
+
+	var file *os.File
+	if *out != "" {
+		file, err = os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to create %s: %v\n", *out, err)
+			return exitFailure
+		}
+		w = file
+	}
+
+	err = sbom.Write(w, *format, device, items, time.Now())
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %s SBOM with %d components to %s\n", *format, len(items), *out)
+	}
+	return exitOK
+}
+
+// collectSBOMSoftware runs the software inventory queries on this device
+func collectSBOMSoftware(configDir string) (sbom.Device, []software.Item, int) {
+	cfg, _, code := initCommand(configDir)
+	if code != exitOK {
+		return sbom.Device{}, nil, code
+	}
+	defer utils.CloseLogger()
+
+	agentCollector, err := collector.NewCollector(cfg)
+	if err != nil {
+		utils.Error("Failed to initialize collector: %v", err)
+		return sbom.Device{}, nil, exitUnavailable
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	items, err := agentCollector.CollectSoftware(ctx)
+	if err != nil {
+		utils.Error("Failed to collect software inventory: %v", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return sbom.Device{}, nil, exitTimeout
+		}
+		return sbom.Device{}, nil, exitFailure
+	}
+
+	sysInfo := agentCollector.GetSystemInfo()
+	device := sbom.Device{
+		Name:         sysInfo.ComputerName,
+		DeviceID:     sysInfo.DeviceID,
+		SerialNo:     sysInfo.SerialNo,
+		OSType:       sysInfo.OSType,
+		OSVersion:    sysInfo.OSVersion,
+		AgentVersion: cfg.Agent.Version,
+	}
+	return device, items, exitOK
+}
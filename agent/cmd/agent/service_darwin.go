@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// launchdPlistPath is where the installer puts the LaunchDaemon
+const launchdPlistPath = "/Library/LaunchDaemons/com.company.scanx.plist"
+
+// startAgentService loads the LaunchDaemon installed by the installer
+func startAgentService() error {
+	output, err := exec.Command("launchctl", "load", "-w", launchdPlistPath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl load %s failed: %v: %s", launchdPlistPath, err, output)
+	}
+	return nil
+}
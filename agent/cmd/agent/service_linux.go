@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// startAgentService enables and starts the systemd unit installed by the packages
+func startAgentService() error {
+	output, err := exec.Command("systemctl", "enable", "--now", "scanx").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl enable --now scanx failed: %v: %s", err, output)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// startAgentService is not supported on this platform
+func startAgentService() error {
+	return fmt.Errorf("starting the agent service is not supported on %s", runtime.GOOS)
+}
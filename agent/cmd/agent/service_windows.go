@@ -0,0 +1,15 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// startAgentService starts the Windows service registered by the installer
+func startAgentService() error {
+	output, err := exec.Command("sc.exe", "start", "scanx").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("sc start scanx failed: %v: %s", err, output)
+	}
+	return nil
+}
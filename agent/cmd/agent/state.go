@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"scanx/internal/config"
+	"scanx/internal/securestore"
+	"scanx/internal/utils"
+)
+
+// runStateCommand implements `scanx state status|rotate-key`
+func runStateCommand(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: scanx state status")
+		fmt.Fprintln(os.Stderr, "       scanx state rotate-key")
+	}
+	if len(args) != 1 {
+		usage()
+		return exitUsage
+	}
+
+	stateDir, err := utils.EnsureStateDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+	store, err := securestore.Open(stateDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to open state store: %v\n", err)
+		return exitFailure
+	}
+
+	switch args[0] {
+	case "status":
+		keyID, created, retired := store.KeyInfo()
+		fmt.Printf("State directory: %s\n", stateDir)
+		fmt.Printf("Active key:      %s\n", keyID)
+		if !created.IsZero() {
+			fmt.Printf("Key created:     %s (%s ago)\n", created.Format(time.RFC3339), time.Since(created).Round(time.Minute))
+		}
+		fmt.Printf("Retired keys:    %d\n", retired)
+		return exitOK
+
+	case "rotate-key":
+		if err := store.Rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: key rotation failed: %v\n", err)
+			return exitFailure
+		}
+		keyID, _, _ := store.KeyInfo()
+		fmt.Printf("State encryption key rotated; active key is now %s\n", keyID)
+		return exitOK
+
+	default:
+		usage()
+		return exitUsage
+	}
+}
+
+// rotateStateKeyIfDue rotates the state encryption key once it is older than configured
+func rotateStateKeyIfDue(cfg *config.Config, stateDir string) {
+	maxAge := cfg.GetStateKeyRotation()
+	if maxAge == 0 {
+		return
+	}
+
+	store, err := securestore.Open(stateDir)
+	if err != nil {
+		utils.Warning("State store unavailable: %v", err)
+		return
+	}
+
+	_, created, _ := store.KeyInfo()
+	if created.IsZero() || time.Since(created) < maxAge {
+		return
+	}
+
+	utils.Info("State encryption key is older than %v, rotating", maxAge)
+	if err := store.Rotate(); err != nil {
+		utils.Error("State key rotation failed: %v", err)
+	}
+}
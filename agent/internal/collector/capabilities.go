@@ -0,0 +1,287 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"scanx/internal/config"
+	"scanx/internal/utils"
+)
+
+// Capabilities describes what the installed osquery build supports
+type Capabilities struct {
+	Version     string
+	Tables      map[string]bool
+	Columns     map[string]map[string]bool // only for tables referenced by configured queries
+	fingerprint string
+}
+
+// UnsupportedQueryError reports a query skipped because osquery cannot run it
+type UnsupportedQueryError struct {
+	QueryName string
+	Reason    string
+}
+
+func (e *UnsupportedQueryError) Error() string {
+	return fmt.Sprintf("query '%s' unsupported: %s", e.QueryName, e.Reason)
+}
+
+// capabilityDiscoveryTimeout bounds the osquery runs that discover tables and columns
+const capabilityDiscoveryTimeout = 2 * time.Minute
+
+var (
+	sqlTokenPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*|"(?:[^"]|"")*"|` + "`[^`]*`" + `|\S`)
+	selectPattern   = regexp.MustCompile(`(?is)^\s*select\s+(.+?)\s+from\s`)
+	identPattern    = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+)
+
+// DiscoverCapabilities asks osquery for its version, table registry and the
+// columns of the given tables
+func (r *OSQueryRunner) DiscoverCapabilities(ctx context.Context, tables []string) (*Capabilities, error) {
+	caps := &Capabilities{
+		Tables:      make(map[string]bool),
+		Columns:     make(map[string]map[string]bool),
+		fingerprint: r.BinaryFingerprint(),
+	}
+
+	versionRows, err := r.ExecuteQuery(ctx, "osquery_info", "SELECT version FROM osquery_info;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read osquery version: %w", err)
+	}
+	if len(versionRows) > 0 {
+		caps.Version, _ = versionRows[0]["version"].(string)
+	}
+
+	tableRows, err := r.ExecuteQuery(ctx, "osquery_registry", "SELECT name FROM osquery_registry WHERE registry = 'table' AND active = 1;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read osquery table registry: %w", err)
+	}
+	for _, row := range tableRows {
+		if name, ok := row["name"].(string); ok {
+			caps.Tables[name] = true
+		}
+	}
+
+	for _, table := range tables {
+		if !caps.Tables[table] {
+			continue
+		}
+		columnRows, err := r.ExecuteQuery(ctx, "table_info", fmt.Sprintf("PRAGMA table_info(%s);", table))
+		if err != nil {
+			utils.Warning("Failed to read columns of table '%s': %v", table, err)
+			continue
+		}
+		columns := make(map[string]bool, len(columnRows))
+		for _, row := range columnRows {
+			if name, ok := row["name"].(string); ok {
+				columns[name] = true
+			}
+		}
+		caps.Columns[table] = columns
+	}
+
+	return caps, nil
+}
+
+// BinaryFingerprint identifies the installed osqueryi binary so upgrades can be detected
+func (r *OSQueryRunner) BinaryFingerprint() string {
+	info, err := os.Stat(r.osqueryPath)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+}
+
+// CheckQuery returns an *UnsupportedQueryError if the query cannot run on this osquery build
+func (c *Capabilities) CheckQuery(queryName string, queryConfig config.QueryConfig) error {
+	if queryConfig.MinOSQueryVersion != "" && c.Version != "" && compareVersions(c.Version, queryConfig.MinOSQueryVersion) < 0 {
+		return &UnsupportedQueryError{
+			QueryName: queryName,
+			Reason:    fmt.Sprintf("requires osquery %s, found %s", queryConfig.MinOSQueryVersion, c.Version),
+		}
+	}
+
+	tables := referencedTables(queryConfig.Query)
+	for _, table := range tables {
+		if !c.Tables[table] {
+			return &UnsupportedQueryError{QueryName: queryName, Reason: fmt.Sprintf("table '%s' not available", table)}
+		}
+	}
+
+	// Column checks are only reliable for plain single-table column lists
+	if len(tables) == 1 {
+		if known, ok := c.Columns[tables[0]]; ok && len(known) > 0 {
+			for _, column := range selectedColumns(queryConfig.Query) {
+				if !known[column] {
+					return &UnsupportedQueryError{
+						QueryName: queryName,
+						Reason:    fmt.Sprintf("column '%s' not available in table '%s'", column, tables[0]),
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// referencedTables extracts the distinct table names following FROM or JOIN,
+// including every table of a comma join ("FROM a x, b y"). String literals and
+// comments are ignored.
+func referencedTables(query string) []string {
+	tokens := sqlTokenPattern.FindAllString(stripSQLLiterals(query), -1)
+
+	seen := make(map[string]bool)
+	var tables []string
+	for i, token := range tokens {
+		if !strings.EqualFold(token, "from") && !strings.EqualFold(token, "join") {
+			continue
+		}
+		for j := i + 1; j < len(tokens); {
+			table, ok := sqlIdentifier(tokens[j])
+			if !ok {
+				break
+			}
+			if !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+
+			// Skip an optional alias, then continue only if another table follows
+			j++
+			if j < len(tokens) && strings.EqualFold(tokens[j], "as") {
+				j++
+			}
+			if j < len(tokens) && tokens[j] != "," {
+				if _, ok := sqlIdentifier(tokens[j]); ok {
+					j++
+				}
+			}
+			if j >= len(tokens) || tokens[j] != "," {
+				break
+			}
+			j++
+		}
+	}
+	return tables
+}
+
+// sqlIdentifier returns the lower-cased name of a bare or quoted identifier token
+func sqlIdentifier(token string) (string, bool) {
+	if len(token) >= 2 && (token[0] == '"' || token[0] == '`') {
+		token = strings.ReplaceAll(token[1:len(token)-1], `""`, `"`)
+	}
+	name := strings.ToLower(token)
+	if !identPattern.MatchString(name) {
+		return "", false
+	}
+	return name, true
+}
+
+// stripSQLLiterals blanks out string literals and removes comments so that
+// keywords inside them are not mistaken for SQL
+func stripSQLLiterals(query string) string {
+	var b strings.Builder
+	for i := 0; i < len(query); i++ {
+		switch {
+		case query[i] == '\'':
+			// Skip to the closing quote; '' is an escaped quote inside the literal
+			for i++; i < len(query); i++ {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b.WriteString("''")
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return b.String()
+			}
+			i += end
+			b.WriteByte('\n')
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return b.String()
+			}
+			i += end + 3
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(query[i])
+		}
+	}
+	return b.String()
+}
+
+// selectedColumns returns the select list when it consists only of bare column names
+func selectedColumns(query string) []string {
+	match := selectPattern.FindStringSubmatch(query)
+	if match == nil {
+		return nil
+	}
+
+	var columns []string
+	for _, item := range strings.Split(match[1], ",") {
+		column := strings.ToLower(strings.TrimSpace(item))
+		if !identPattern.MatchString(column) {
+			return nil
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// queryTables returns every table referenced by the given queries
+func queryTables(queries config.PlatformQueries) []string {
+	seen := make(map[string]bool)
+	var tables []string
+	for _, queryConfig := range queries {
+		for _, table := range referencedTables(queryConfig.Query) {
+			if !seen[table] {
+				seen[table] = true
+				tables = append(tables, table)
+			}
+		}
+	}
+	return tables
+}
+
+// compareVersions compares dotted numeric versions such as "5.10.2"; it returns -1, 0 or 1
+func compareVersions(a, b string) int {
+	partsA := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	partsB := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var numA, numB int
+		if i < len(partsA) {
+			numA, _ = strconv.Atoi(leadingDigits(partsA[i]))
+		}
+		if i < len(partsB) {
+			numB, _ = strconv.Atoi(leadingDigits(partsB[i]))
+		}
+		if numA != numB {
+			if numA < numB {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// leadingDigits returns the numeric prefix of a version component ("2-rc1" -> "2")
+func leadingDigits(s string) string {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return s[:end]
+}
@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"scanx/internal/config"
+)
+
+func TestReferencedTables(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "SELECT * FROM system_info;", want: []string{"system_info"}},
+		{query: "SELECT u.username FROM users u JOIN groups g ON u.gid = g.gid", want: []string{"users", "groups"}},
+		{query: "SELECT * FROM users u, user_ssh_keys k WHERE u.uid = k.uid", want: []string{"users", "user_ssh_keys"}},
+		{query: "SELECT * FROM a AS x, b, c y LEFT JOIN d ON 1", want: []string{"a", "b", "c", "d"}},
+		{query: "SELECT * FROM apps WHERE name = 'from nowhere' OR path LIKE '%join me%'", want: []string{"apps"}},
+		{query: "SELECT 'it''s from here' AS note FROM os_version", want: []string{"os_version"}},
+		{query: "SELECT * -- from commented\nFROM mounts /* join hidden */ WHERE 1", want: []string{"mounts"}},
+		{query: `SELECT * FROM "Users"`, want: []string{"users"}},
+		{query: "SELECT * FROM (SELECT name FROM processes) p", want: []string{"processes"}},
+		{query: "SELECT * FROM users GROUP BY uid, gid", want: []string{"users"}},
+		{query: "SELECT * FROM users, users u2", want: []string{"users"}},
+	}
+
+	for _, tt := range tests {
+		got := referencedTables(tt.query)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("referencedTables(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCheckQueryCommaJoin(t *testing.T) {
+	caps := &Capabilities{Tables: map[string]bool{"users": true}}
+	err := caps.CheckQuery("keys", config.QueryConfig{Query: "SELECT * FROM users u, user_ssh_keys k WHERE u.uid = k.uid"})
+	if err == nil || !strings.Contains(err.Error(), "user_ssh_keys") {
+		t.Fatalf("err = %v, want user_ssh_keys reported as unavailable", err)
+	}
+	if err := caps.CheckQuery("apps", config.QueryConfig{Query: "SELECT * FROM users WHERE shell = 'from nowhere'"}); err != nil {
+		t.Fatalf("string literal treated as a table: %v", err)
+	}
+}
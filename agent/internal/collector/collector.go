@@ -1,12 +1,20 @@
 package collector
 
 import (
+	"context"
 	"fmt"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"scanx/internal/config"
+	"scanx/internal/extensions"
+	"scanx/internal/identity"
+	"scanx/internal/software"
 	"scanx/internal/utils"
+	"scanx/internal/vulndb"
 )
 
 // SystemInfo represents system metadata
@@ -15,25 +23,67 @@ type SystemInfo struct {
 	OSVersion    string `json:"os_version"`
 	SerialNo     string `json:"serial_no"`
 	ComputerName string `json:"computer_name"`
+
+	DeviceID         string               `json:"device_id"`
+	Identifiers      identity.Identifiers `json:"device_identifiers"`
+	HardwareChanged  bool                 `json:"hardware_changed"`
+	PreviousDeviceID string               `json:"previous_device_id,omitempty"`
 }
 
 // CollectedData represents the complete data collection result
 type CollectedData struct {
-	User         string                              `json:"user"`
-	Version      string                              `json:"version"`
-	OSType       string                              `json:"os_type"`
-	OSVersion    string                              `json:"os_version"`
-	SerialNo     string                              `json:"serial_no"`
-	ComputerName string                              `json:"computer_name"`
-	Timestamp    string                              `json:"timestamp"`
-	Data         map[string][]map[string]interface{} `json:"data"`
+	User              string                              `json:"user"`
+	Version           string                              `json:"version"`
+	OSType            string                              `json:"os_type"`
+	OSVersion         string                              `json:"os_version"`
+	SerialNo          string                              `json:"serial_no"`
+	DeviceID          string                              `json:"device_id"`
+	DeviceIdentifiers identity.Identifiers                `json:"device_identifiers"`
+	HardwareChanged   bool                                `json:"hardware_changed"`
+	PreviousDeviceID  string                              `json:"previous_device_id,omitempty"`
+	ComputerName      string                              `json:"computer_name"`
+	Timestamp         string                              `json:"timestamp"`
+	Data              map[string][]map[string]interface{} `json:"data"`
+	QueryMeta         map[string]QueryMeta                `json:"query_meta"`
+	Redactions        map[string]config.RedactionRules    `json:"redactions,omitempty"`
+	QueryCost         map[string]QueryCost                `json:"query_cost,omitempty"`
+	Denylist          map[string]DenyState                `json:"denylist,omitempty"`
+	OSQueryVersion    string                              `json:"osquery_version,omitempty"`
+	Software          []software.Item                     `json:"software,omitempty"`
+	Vulnerabilities   []vulndb.Finding                    `json:"vulnerabilities,omitempty"`
+	VulnerabilityDB   *VulnerabilityScan                  `json:"vulnerability_db,omitempty"`
+	ExtensionFindings []extensions.Finding                `json:"extension_findings,omitempty"`
+}
+
+// QueryRunner executes osquery queries; implemented by OSQueryRunner
+type QueryRunner interface {
+	ExecuteQuery(ctx context.Context, queryName string, query string) ([]map[string]interface{}, error)
+	ExecuteQueryForUsers(ctx context.Context, queryName string, query string) ([]map[string]interface{}, error)
+	GetOSQueryPath() string
 }
 
 // Collector handles data collection from osquery
 type Collector struct {
-	config  *config.Config
-	runner  *OSQueryRunner
-	sysInfo SystemInfo
+	config   *config.Config
+	runner   QueryRunner
+	osquery  *OSQueryRunner
+	watchdog *Watchdog
+	capsMu   sync.Mutex
+	caps     *Capabilities
+	sysInfo  SystemInfo
+	vulnDB   vulnDBCache
+	stateDir string
+
+	saltOnce sync.Once
+	salt     string
+}
+
+// queryResult is the outcome of a single query executed by a worker
+type queryResult struct {
+	rows     []map[string]interface{}
+	err      error
+	duration time.Duration
+	executor string
 }
 
 // NewCollector creates a new data collector
@@ -45,18 +95,36 @@ func NewCollector(cfg *config.Config) (*Collector, error) {
 	}
 
 	collector := &Collector{
-		config: cfg,
-		runner: runner,
+		config:  cfg,
+		runner:  runner,
+		osquery: runner,
 		sysInfo: SystemInfo{
 			OSType: runtime.GOOS,
 		},
 	}
 
+	// Persistent state is optional: without it the device ID, denylist and salt are not kept
+	stateDir, err := utils.EnsureStateDir()
+	if err != nil {
+		utils.Warning("Agent state will not persist: %v", err)
+	}
+	collector.stateDir = stateDir
+
 	// Extract system information
 	if err := collector.extractSystemInfo(); err != nil {
 		return nil, fmt.Errorf("failed to extract system info: %w", err)
 	}
 
+	// Enforce resource limits on osquery children from here on
+	collector.watchdog = NewWatchdog(ResourceLimits{
+		CPUTime:     cfg.GetQueryCPULimit(),
+		MaxRSSBytes: cfg.GetQueryMemoryLimitBytes(),
+	}, stateDir)
+	runner.SetWatchdog(collector.watchdog)
+
+	// Learn which tables this osquery build supports
+	collector.refreshCapabilities(context.Background())
+
 	return collector, nil
 }
 
@@ -75,7 +143,9 @@ func (c *Collector) extractSystemInfo() error {
 	}
 
 	// Execute system_info query
-	results, err := c.runner.ExecuteQuery("system_info", systemInfoQuery.Query)
+	ctx, cancel := context.WithTimeout(context.Background(), systemInfoQuery.GetTimeout())
+	defer cancel()
+	results, err := c.runner.ExecuteQuery(ctx, "system_info", systemInfoQuery.Query)
 	if err != nil {
 		return fmt.Errorf("failed to execute system_info query: %w", err)
 	}
@@ -88,13 +158,13 @@ func (c *Collector) extractSystemInfo() error {
 	result := results[0]
 
 	// Debug: log available fields for OS version extraction
-	fmt.Printf("DEBUG: Available system_info fields for OS version: ")
+	fmt.Fprintf(utils.ConsoleOutput(), "DEBUG: Available system_info fields for OS version: ")
 	for key := range result {
 		if strings.Contains(strings.ToLower(key), "version") || strings.Contains(strings.ToLower(key), "build") {
-			fmt.Printf("%s=%v ", key, result[key])
+			fmt.Fprintf(utils.ConsoleOutput(), "%s=%v ", key, result[key])
 		}
 	}
-	fmt.Println()
+	fmt.Fprintln(utils.ConsoleOutput())
 
 	// Extract OS version - try multiple field names
 	if version, ok := result["version"].(string); ok && version != "" {
@@ -107,7 +177,7 @@ func (c *Collector) extractSystemInfo() error {
 		c.sysInfo.OSVersion = version
 	} else {
 		c.sysInfo.OSVersion = "unknown"
-		fmt.Printf("DEBUG: Could not extract OS version from available fields\n")
+		fmt.Fprintf(utils.ConsoleOutput(), "DEBUG: Could not extract OS version from available fields\n")
 	}
 
 	// Extract serial number - different field names per platform
@@ -119,6 +189,11 @@ func (c *Collector) extractSystemInfo() error {
 		c.sysInfo.SerialNo = "unknown"
 	}
 
+	// Derive the stable device identity from all available identifiers
+	hardwareSerial, _ := result["hardware_serial"].(string)
+	systemUUID, _ := result["uuid"].(string)
+	c.resolveDeviceIdentity(hardwareSerial, systemUUID)
+
 	// Extract computer name
 	if computerName, ok := result["computer_name"].(string); ok && computerName != "" {
 		c.sysInfo.ComputerName = computerName
@@ -131,8 +206,54 @@ func (c *Collector) extractSystemInfo() error {
 	return nil
 }
 
+// resolveDeviceIdentity loads or creates the persisted device identity. When it
+// cannot be persisted the device still reports, under an ID derived for this run.
+func (c *Collector) resolveDeviceIdentity(hardwareSerial, systemUUID string) {
+	ids := identity.CollectIdentifiers(hardwareSerial, systemUUID)
+	deviceIdentity, err := identity.LoadOrCreate(c.stateDir, ids)
+	if err != nil {
+		c.sysInfo.DeviceID = identity.DegradedID(ids)
+		c.sysInfo.Identifiers = ids
+		utils.Warning("Device identity unavailable, reporting unpersisted device ID %s: %v", c.sysInfo.DeviceID, err)
+		return
+	}
+
+	if deviceIdentity.HardwareChanged {
+		utils.Warning("Device hardware changed: new device ID %s (previous %s)", deviceIdentity.DeviceID, deviceIdentity.PreviousDeviceID)
+	}
+
+	c.sysInfo.DeviceID = deviceIdentity.DeviceID
+	c.sysInfo.Identifiers = deviceIdentity.Identifiers
+	c.sysInfo.HardwareChanged = deviceIdentity.HardwareChanged
+	c.sysInfo.PreviousDeviceID = deviceIdentity.PreviousDeviceID
+}
+
+// AcknowledgeHardwareChange stops flagging a hardware change once a report
+// carrying it has been delivered
+func (c *Collector) AcknowledgeHardwareChange() {
+	if !c.sysInfo.HardwareChanged {
+		return
+	}
+	if err := identity.ClearHardwareChanged(c.stateDir); err != nil {
+		utils.Warning("Failed to record that the hardware change was reported: %v", err)
+		return
+	}
+	c.sysInfo.HardwareChanged = false
+}
+
+// CollectOptions adjusts a single collection run
+type CollectOptions struct {
+	// SkipHeavy suppresses queries marked heavy, e.g. during quiet hours
+	SkipHeavy bool
+}
+
 // CollectData executes all platform-specific queries and returns formatted data
-func (c *Collector) CollectData() (*CollectedData, error) {
+func (c *Collector) CollectData(ctx context.Context) (*CollectedData, error) {
+	return c.CollectDataWithOptions(ctx, CollectOptions{})
+}
+
+// CollectDataWithOptions executes all platform-specific queries subject to the given options
+func (c *Collector) CollectDataWithOptions(ctx context.Context, opts CollectOptions) (*CollectedData, error) {
 	// Get platform-specific queries
 	queries, err := c.config.GetPlatformQueries()
 	if err != nil {
@@ -141,53 +262,260 @@ func (c *Collector) CollectData() (*CollectedData, error) {
 
 	// Initialize data map
 	data := make(map[string][]map[string]interface{})
+	redactions := make(map[string]config.RedactionRules)
 
-	for queryName, queryConfig := range queries {
-		results, err := c.runner.ExecuteQuery(queryName, queryConfig.Query)
+	// Execute queries concurrently; results are gathered in sorted name order
+	queryNames := make([]string, 0, len(queries))
+	for queryName := range queries {
+		queryNames = append(queryNames, queryName)
+	}
+	sort.Strings(queryNames)
+
+	// Rediscover capabilities after an osquery upgrade
+	if caps := c.capabilities(); c.osquery != nil && (caps == nil || caps.fingerprint != c.osquery.BinaryFingerprint()) {
+		c.refreshCapabilities(ctx)
+	}
+
+	if c.watchdog != nil {
+		c.watchdog.ResetCosts()
+	}
+	results := c.runQueries(ctx, queryNames, queries, opts)
+	if ctx.Err() == context.Canceled {
+		return nil, fmt.Errorf("data collection cancelled: %w", ctx.Err())
+	}
+
+	queryMeta := make(map[string]QueryMeta, len(queryNames))
+	for i, queryName := range queryNames {
+		queryConfig := queries[queryName]
+		rows, err := results[i].rows, results[i].err
+
+		meta := QueryMeta{
+			Status:     queryStatus(rows, err),
+			DurationMs: results[i].duration.Milliseconds(),
+			Executor:   results[i].executor,
+		}
 		if err != nil {
+			meta.Error = err.Error()
+			queryMeta[queryName] = meta
+			data[queryName] = []map[string]interface{}{}
+
 			// Log error but continue with other queries
-			fmt.Printf("Warning: Failed to execute query '%s': %v\n", queryName, err)
-			// Set empty result for failed queries
-			data[queryName] = []map[string]interface{}{
-				{
-					"status": "failed to execute query",
-				},
+			if meta.Status == QueryStatusUnsupported || meta.Status == QueryStatusSkipped {
+				utils.Info("Skipping query '%s': %v", queryName, err)
+			} else {
+				utils.Warning("Failed to execute query '%s': %v", queryName, err)
 			}
 			continue
 		}
 
-		if queryName == "screen_lock_info" || queryName == "antivirus_info" || queryName == "disk_encryption_info" || queryName == "password_manager_info" {
-			utils.Info("🔍 Results of query '%s': %v", queryName, results)
-			fmt.Printf("🔍 Results of query '%s': %v\n", queryName, results)
+		// Minimize data before it leaves the machine and record what was redacted
+		if !queryConfig.Redaction.IsEmpty() {
+			var applied config.RedactionRules
+			rows, applied = c.redact(rows, queryConfig.Redaction)
+			redactions[queryName] = applied
 		}
 
-		if len(results) == 0 {
-			data[queryName] = []map[string]interface{}{
-				{
-					"status": fmt.Sprintf("no_data_found for %s", queryName),
-				},
-			}
-			continue
+		if queryName == "screen_lock_info" || queryName == "antivirus_info" || queryName == "disk_encryption_info" || queryName == "password_manager_info" {
+			utils.Info("🔍 Results of query '%s': %v", queryName, rows)
+			fmt.Fprintf(utils.ConsoleOutput(), "🔍 Results of query '%s': %v\n", queryName, rows)
 		}
 
-		data[queryName] = results
+		if rows == nil {
+			rows = []map[string]interface{}{}
+		}
+		meta.RowCount = len(rows)
+		queryMeta[queryName] = meta
+		data[queryName] = rows
 	}
 
 	// Build final payload
 	collectedData := &CollectedData{
-		User:         c.config.Agent.UserEmail,
-		Version:      c.config.Agent.Version,
-		OSType:       c.sysInfo.OSType,
-		OSVersion:    c.sysInfo.OSVersion,
-		SerialNo:     c.sysInfo.SerialNo,
-		ComputerName: c.sysInfo.ComputerName,
-		Timestamp:    utils.GetCurrentISTString(),
-		Data:         data,
+		User:              c.config.Agent.UserEmail,
+		Version:           c.config.Agent.Version,
+		OSType:            c.sysInfo.OSType,
+		OSVersion:         c.sysInfo.OSVersion,
+		SerialNo:          c.sysInfo.SerialNo,
+		DeviceID:          c.sysInfo.DeviceID,
+		DeviceIdentifiers: c.sysInfo.Identifiers,
+		HardwareChanged:   c.sysInfo.HardwareChanged,
+		PreviousDeviceID:  c.sysInfo.PreviousDeviceID,
+		ComputerName:      c.sysInfo.ComputerName,
+		Timestamp:         utils.GetCurrentISTString(),
+		Data:              data,
+		QueryMeta:         queryMeta,
+		Redactions:        redactions,
+	}
+	if caps := c.capabilities(); caps != nil {
+		collectedData.OSQueryVersion = caps.Version
+	}
+	if c.watchdog != nil {
+		collectedData.QueryCost, collectedData.Denylist = c.watchdog.Snapshot()
+	}
+
+	// Normalize and match the inventory as reported, so neither reveals redacted values
+	collectedData.Software = software.Normalize(data, localDistro())
+	collectedData.Vulnerabilities, collectedData.VulnerabilityDB = c.scanVulnerabilities(ctx, data)
+	collectedData.ExtensionFindings = c.checkExtensions(data)
+
+	// Inventories are reported once, as the normalized software list; their raw rows
+	// only feed the analysis above and their query metadata is still reported
+	for queryName, queryConfig := range queries {
+		if queryConfig.LocalOnly {
+			delete(data, queryName)
+		}
 	}
 
 	return collectedData, nil
 }
 
+// runQueries executes the named queries on a bounded worker pool under the overall
+// collection deadline. The returned slice is index-aligned with queryNames.
+func (c *Collector) runQueries(ctx context.Context, queryNames []string, queries config.PlatformQueries, opts CollectOptions) []queryResult {
+	results := make([]queryResult, len(queryNames))
+
+	workers := c.config.GetMaxConcurrentQueries()
+	if workers > len(queryNames) {
+		workers = len(queryNames)
+	}
+
+	// The collection deadline cancels running queries, which kills their osqueryi children
+	collectionTimeout := c.config.GetCollectionTimeout()
+	ctx, cancel := context.WithTimeout(ctx, collectionTimeout)
+	defer cancel()
+
+	caps := c.capabilities()
+	jobs := make(chan int, len(queryNames))
+	for i := range queryNames {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				queryName := queryNames[i]
+				results[i].executor = ExecutorNone
+				if ctx.Err() != nil {
+					results[i].err = &SkippedQueryError{QueryName: queryName, Reason: fmt.Sprintf("collection aborted before start: %v", ctx.Err())}
+					continue
+				}
+
+				if c.watchdog != nil {
+					// User-scoped queries are also checked per user by the runner
+					if until := c.watchdog.DenylistedUntil(queryName, ""); !until.IsZero() {
+						results[i].err = &SkippedQueryError{
+							QueryName: queryName,
+							Reason:    fmt.Sprintf("denylisted until %s after repeated resource limit violations", until.Format(time.RFC3339)),
+						}
+						continue
+					}
+				}
+
+				queryConfig := queries[queryName]
+				if opts.SkipHeavy && queryConfig.Heavy {
+					results[i].err = &SkippedQueryError{QueryName: queryName, Reason: "heavy query suppressed during quiet hours"}
+					continue
+				}
+
+				if caps != nil {
+					if err := caps.CheckQuery(queryName, queryConfig); err != nil {
+						results[i].err = err
+						continue
+					}
+				}
+
+				queryCtx, queryCancel := context.WithTimeout(ctx, queryConfig.GetTimeout())
+				start := time.Now()
+				if queryConfig.UserContext {
+					results[i].executor = ExecutorOSQueryAsUser
+					results[i].rows, results[i].err = c.runner.ExecuteQueryForUsers(queryCtx, queryName, queryConfig.Query)
+				} else {
+					results[i].executor = ExecutorOSQuery
+					results[i].rows, results[i].err = c.runner.ExecuteQuery(queryCtx, queryName, queryConfig.Query)
+				}
+				results[i].duration = time.Since(start)
+				queryCancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		utils.Warning("Collection deadline of %v exceeded; remaining queries were aborted", collectionTimeout)
+	}
+
+	return results
+}
+
+// refreshCapabilities rediscovers the osquery table registry; on failure query checks are skipped
+func (c *Collector) refreshCapabilities(ctx context.Context) {
+	if c.osquery == nil {
+		return
+	}
+
+	queries, err := c.config.GetPlatformQueries()
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, capabilityDiscoveryTimeout)
+	defer cancel()
+	caps, err := c.osquery.DiscoverCapabilities(ctx, queryTables(queries))
+	if err != nil {
+		utils.Warning("osquery capability discovery failed, running all queries unchecked: %v", err)
+		caps = nil
+	} else {
+		utils.Info("osquery %s: %d tables available", caps.Version, len(caps.Tables))
+	}
+
+	c.capsMu.Lock()
+	c.caps = caps
+	c.capsMu.Unlock()
+}
+
+// capabilities returns the last discovered osquery capabilities, or nil if unknown
+func (c *Collector) capabilities() *Capabilities {
+	c.capsMu.Lock()
+	defer c.capsMu.Unlock()
+	return c.caps
+}
+
+// redactionSalt returns the configured hashing salt, falling back to a random
+// per-device salt kept in the state directory. It returns "" when neither is
+// available; hashed columns are then dropped instead.
+func (c *Collector) redactionSalt() string {
+	if c.config.Agent.RedactionSalt != "" {
+		return c.config.Agent.RedactionSalt
+	}
+	c.saltOnce.Do(func() {
+		salt, err := loadOrCreateRedactionSalt(c.stateDir)
+		if err != nil {
+			utils.Warning("Columns with hash redaction will be dropped: %v", err)
+			return
+		}
+		c.salt = salt
+	})
+	return c.salt
+}
+
+// redact applies rules to rows and returns the rules that were actually applied
+func (c *Collector) redact(rows []map[string]interface{}, rules *config.RedactionRules) ([]map[string]interface{}, config.RedactionRules) {
+	applied := *rules
+	salt := ""
+	if len(rules.Hash) > 0 {
+		salt = c.redactionSalt()
+	}
+	if salt == "" && len(rules.Hash) > 0 {
+		// Without a secret salt a hash is a dictionary lookup away from the value
+		applied.Drop = append(append([]string(nil), rules.Drop...), rules.Hash...)
+		applied.Hash = nil
+	}
+	return applyRedaction(rows, &applied, salt), applied
+}
+
 // GetSystemInfo returns the extracted system information
 func (c *Collector) GetSystemInfo() SystemInfo {
 	return c.sysInfo
@@ -0,0 +1,257 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"scanx/internal/config"
+)
+
+// fakeRunner answers queries after a per-query delay and records concurrency
+type fakeRunner struct {
+	delays map[string]time.Duration
+
+	mu        sync.Mutex
+	running   int
+	maxSeen   int
+	started   []string
+	cancelled []string
+	asUsers   []string
+	failUsers map[string]bool
+}
+
+func (f *fakeRunner) ExecuteQuery(ctx context.Context, queryName string, query string) ([]map[string]interface{}, error) {
+	f.mu.Lock()
+	f.running++
+	if f.running > f.maxSeen {
+		f.maxSeen = f.running
+	}
+	f.started = append(f.started, queryName)
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.running--
+		f.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(f.delays[queryName]):
+		return []map[string]interface{}{{"query": queryName}}, nil
+	case <-ctx.Done():
+		f.mu.Lock()
+		f.cancelled = append(f.cancelled, queryName)
+		f.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (f *fakeRunner) ExecuteQueryForUsers(ctx context.Context, queryName string, query string) ([]map[string]interface{}, error) {
+	return f.ExecuteQuery(ctx, queryName, query)
+}
+
+func (f *fakeRunner) ExecuteQueryAsUser(ctx context.Context, queryName string, query string, username string) ([]map[string]interface{}, error) {
+	f.mu.Lock()
+	f.asUsers = append(f.asUsers, username)
+	f.mu.Unlock()
+	if f.failUsers[username] {
+		return nil, fmt.Errorf("query failed for %s", username)
+	}
+	return f.ExecuteQuery(ctx, queryName, query)
+}
+
+func (f *fakeRunner) GetOSQueryPath() string {
+	return "fake"
+}
+
+// newTestCollector returns a collector running queries on runner with the given worker limit
+func newTestCollector(runner QueryRunner, workers int, collectionTimeout string) *Collector {
+	return &Collector{
+		config: &config.Config{Agent: config.AgentConfig{
+			MaxConcurrentQueries: workers,
+			CollectionTimeout:    collectionTimeout,
+		}},
+		runner: runner,
+	}
+}
+
+// testQueries builds one query per name with the given timeout
+func testQueries(timeout string, names ...string) config.PlatformQueries {
+	queries := make(config.PlatformQueries, len(names))
+	for _, name := range names {
+		queries[name] = config.QueryConfig{Query: "SELECT 1;", Timeout: timeout}
+	}
+	return queries
+}
+
+func TestRunQueriesResultsFollowQueryOrder(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e"}
+	runner := &fakeRunner{delays: map[string]time.Duration{
+		"a": 50 * time.Millisecond,
+		"b": 1 * time.Millisecond,
+		"c": 30 * time.Millisecond,
+		"d": 0,
+		"e": 10 * time.Millisecond,
+	}}
+	c := newTestCollector(runner, 3, "")
+
+	results := c.runQueries(context.Background(), names, testQueries("1s", names...), CollectOptions{})
+	if len(results) != len(names) {
+		t.Fatalf("got %d results, want %d", len(results), len(names))
+	}
+	for i, name := range names {
+		if results[i].err != nil {
+			t.Fatalf("query %s: unexpected error %v", name, results[i].err)
+		}
+		if len(results[i].rows) != 1 || results[i].rows[0]["query"] != name {
+			t.Errorf("result %d = %v, want rows of query %s", i, results[i].rows, name)
+		}
+		if results[i].executor != ExecutorOSQuery {
+			t.Errorf("query %s: executor %q, want %q", name, results[i].executor, ExecutorOSQuery)
+		}
+	}
+}
+
+func TestRunQueriesPerQueryTimeout(t *testing.T) {
+	names := []string{"fast1", "fast2", "slow"}
+	runner := &fakeRunner{delays: map[string]time.Duration{
+		"fast1": 5 * time.Millisecond,
+		"fast2": 5 * time.Millisecond,
+		"slow":  5 * time.Second,
+	}}
+	c := newTestCollector(runner, 3, "")
+	queries := testQueries("1s", "fast1", "fast2")
+	queries["slow"] = config.QueryConfig{Query: "SELECT 1;", Timeout: "50ms"}
+
+	start := time.Now()
+	results := c.runQueries(context.Background(), names, queries, CollectOptions{})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("runQueries took %v; the slow query was not cut off by its timeout", elapsed)
+	}
+
+	for i, name := range names[:2] {
+		if results[i].err != nil || len(results[i].rows) != 1 {
+			t.Errorf("query %s: rows %v, err %v; want one row", name, results[i].rows, results[i].err)
+		}
+	}
+	slow := results[2]
+	if !errors.Is(slow.err, context.DeadlineExceeded) {
+		t.Fatalf("slow query error = %v, want deadline exceeded", slow.err)
+	}
+	if status := queryStatus(slow.rows, slow.err); status != QueryStatusTimeout {
+		t.Errorf("slow query status = %q, want %q", status, QueryStatusTimeout)
+	}
+}
+
+func TestRunQueriesConcurrencyCap(t *testing.T) {
+	var names []string
+	delays := make(map[string]time.Duration)
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("q%02d", i)
+		names = append(names, name)
+		delays[name] = 20 * time.Millisecond
+	}
+	runner := &fakeRunner{delays: delays}
+	c := newTestCollector(runner, 3, "")
+
+	results := c.runQueries(context.Background(), names, testQueries("1s", names...), CollectOptions{})
+	for i, result := range results {
+		if result.err != nil {
+			t.Fatalf("query %s: %v", names[i], result.err)
+		}
+	}
+	if runner.maxSeen > 3 {
+		t.Errorf("%d queries ran at once, want at most 3", runner.maxSeen)
+	}
+	if runner.maxSeen < 2 {
+		t.Errorf("at most %d query ran at once; the pool did not run queries concurrently", runner.maxSeen)
+	}
+}
+
+func TestRunQueriesCancellation(t *testing.T) {
+	var names []string
+	delays := make(map[string]time.Duration)
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("q%d", i)
+		names = append(names, name)
+		delays[name] = 5 * time.Second
+	}
+	runner := &fakeRunner{delays: delays}
+	c := newTestCollector(runner, 2, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	results := c.runQueries(ctx, names, testQueries("10s", names...), CollectOptions{})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("runQueries took %v after cancellation", elapsed)
+	}
+
+	var interrupted, skipped int
+	for i, result := range results {
+		var skip *SkippedQueryError
+		switch {
+		case errors.Is(result.err, context.Canceled):
+			interrupted++
+		case errors.As(result.err, &skip):
+			skipped++
+			if result.executor != ExecutorNone {
+				t.Errorf("skipped query %s has executor %q, want %q", names[i], result.executor, ExecutorNone)
+			}
+		default:
+			t.Errorf("query %s: error %v, want cancellation or skip", names[i], result.err)
+		}
+	}
+	if interrupted != 2 {
+		t.Errorf("%d running queries were interrupted, want 2", interrupted)
+	}
+	if skipped != len(names)-2 {
+		t.Errorf("%d queued queries were skipped, want %d", skipped, len(names)-2)
+	}
+}
+
+func TestRunQueriesCollectionDeadline(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	runner := &fakeRunner{delays: map[string]time.Duration{"a": 5 * time.Second, "b": 5 * time.Second, "c": 5 * time.Second}}
+	c := newTestCollector(runner, 1, "50ms")
+
+	results := c.runQueries(context.Background(), names, testQueries("10s", names...), CollectOptions{})
+	if !errors.Is(results[0].err, context.DeadlineExceeded) {
+		t.Errorf("first query error = %v, want deadline exceeded", results[0].err)
+	}
+	for i := 1; i < len(results); i++ {
+		if status := queryStatus(results[i].rows, results[i].err); status != QueryStatusSkipped {
+			t.Errorf("query %s status = %q, want %q", names[i], status, QueryStatusSkipped)
+		}
+	}
+}
+
+func TestCollectDataKeepsLocalOnlyRowsOnDevice(t *testing.T) {
+	c := newTestCollector(&fakeRunner{}, 2, "")
+	c.config.Queries = config.QueriesConfig{Platform: map[string]config.PlatformQueries{
+		runtime.GOOS: {
+			"deb_packages_info": {Query: "SELECT 1;", LocalOnly: true},
+			"system_info":       {Query: "SELECT 1;"},
+		},
+	}}
+
+	data, err := c.CollectData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data.Data["deb_packages_info"]; ok {
+		t.Error("local-only rows were included in the report")
+	}
+	if len(data.Data["system_info"]) != 1 {
+		t.Errorf("system_info rows = %v", data.Data["system_info"])
+	}
+	if meta := data.QueryMeta["deb_packages_info"]; meta.Status != QueryStatusOK || meta.RowCount != 1 {
+		t.Errorf("local-only query meta = %+v, want it reported", meta)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+
+package collector
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// buildUserCredential resolves the uid, primary gid and supplementary groups for a user
+func buildUserCredential(u *user.User) (*syscall.Credential, error) {
+	groupIDs, err := u.GroupIds()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+	return parseCredential(u.Uid, u.Gid, groupIDs)
+}
+
+// parseCredential converts numeric user and group IDs to a credential. Anything
+// that is not a valid non-root uid is rejected rather than run as the agent.
+func parseCredential(uidStr, gidStr string, groupIDs []string) (*syscall.Credential, error) {
+	uid, err := strconv.ParseUint(uidStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uid '%s': %w", uidStr, err)
+	}
+	gid, err := strconv.ParseUint(gidStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid '%s': %w", gidStr, err)
+	}
+	if uid == 0 {
+		return nil, fmt.Errorf("refusing to run user-scoped query as root")
+	}
+
+	groups := make([]uint32, 0, len(groupIDs))
+	for _, groupID := range groupIDs {
+		g, err := strconv.ParseUint(groupID, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group id '%s': %w", groupID, err)
+		}
+		groups = append(groups, uint32(g))
+	}
+
+	return &syscall.Credential{
+		Uid:    uint32(uid),
+		Gid:    uint32(gid),
+		Groups: groups,
+	}, nil
+}
+
+// userSysProcAttr returns process attributes that drop privileges to the given user
+func userSysProcAttr(u *user.User) (*syscall.SysProcAttr, error) {
+	credential, err := buildUserCredential(u)
+	if err != nil {
+		return nil, err
+	}
+	return &syscall.SysProcAttr{Credential: credential}, nil
+}
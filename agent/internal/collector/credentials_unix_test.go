@@ -0,0 +1,52 @@
+//go:build !windows
+
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCredential(t *testing.T) {
+	tests := []struct {
+		name     string
+		uid      string
+		gid      string
+		groupIDs []string
+		wantUID  uint32
+		wantGID  uint32
+		wantGrps []uint32
+		wantErr  bool
+	}{
+		{name: "plain user", uid: "1000", gid: "1000", groupIDs: nil, wantUID: 1000, wantGID: 1000, wantGrps: []uint32{}},
+		{name: "supplementary groups", uid: "1001", gid: "100", groupIDs: []string{"100", "27", "998"}, wantUID: 1001, wantGID: 100, wantGrps: []uint32{100, 27, 998}},
+		{name: "root rejected", uid: "0", gid: "0", wantErr: true},
+		{name: "missing uid", uid: "", gid: "1000", wantErr: true},
+		{name: "non-numeric uid", uid: "S-1-5-21-1000", gid: "1000", wantErr: true},
+		{name: "negative uid", uid: "-1", gid: "1000", wantErr: true},
+		{name: "uid out of range", uid: "4294967296", gid: "1000", wantErr: true},
+		{name: "missing gid", uid: "1000", gid: "", wantErr: true},
+		{name: "non-numeric group", uid: "1000", gid: "1000", groupIDs: []string{"1000", "wheel"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credential, err := parseCredential(tt.uid, tt.gid, tt.groupIDs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseCredential(%q, %q, %v) = %+v, want error", tt.uid, tt.gid, tt.groupIDs, credential)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCredential: %v", err)
+			}
+			if credential.Uid != tt.wantUID || credential.Gid != tt.wantGID {
+				t.Errorf("uid/gid = %d/%d, want %d/%d", credential.Uid, credential.Gid, tt.wantUID, tt.wantGID)
+			}
+			if !reflect.DeepEqual(credential.Groups, tt.wantGrps) {
+				t.Errorf("groups = %v, want %v", credential.Groups, tt.wantGrps)
+			}
+		})
+	}
+}
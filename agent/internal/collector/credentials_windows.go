@@ -0,0 +1,14 @@
+//go:build windows
+
+package collector
+
+import (
+	"fmt"
+	"os/user"
+	"syscall"
+)
+
+// userSysProcAttr is not supported on Windows; user-scoped queries run in the agent's context
+func userSysProcAttr(u *user.User) (*syscall.SysProcAttr, error) {
+	return nil, fmt.Errorf("running queries as another user is not supported on windows")
+}
@@ -0,0 +1,33 @@
+package collector
+
+import (
+	"scanx/internal/extensions"
+	"scanx/internal/utils"
+)
+
+// checkExtensions reports the browser extensions in data that violate the
+// configured extension_denylist or extension_allowlist
+func (c *Collector) checkExtensions(data map[string][]map[string]interface{}) []extensions.Finding {
+	policy := extensions.Policy{
+		Deny:  c.config.Agent.ExtensionDenylist,
+		Allow: c.config.Agent.ExtensionAllowlist,
+	}
+	if policy.IsEmpty() {
+		return nil
+	}
+
+	findings := extensions.Evaluate(data, policy)
+	denied := 0
+	for _, finding := range findings {
+		if finding.Reason == extensions.ReasonDenylisted {
+			denied++
+		}
+	}
+	if denied > 0 {
+		utils.Warning("Found %d denylisted browser extension installation(s)", denied)
+	}
+	if len(findings) > denied {
+		utils.Info("Found %d browser extension installation(s) missing from the allowlist", len(findings)-denied)
+	}
+	return findings
+}
@@ -16,6 +16,7 @@ import (
 // OSQueryRunner handles osquery detection and execution
 type OSQueryRunner struct {
 	osqueryPath string
+	watchdog    *Watchdog
 }
 
 // NewOSQueryRunner creates a new OSQueryRunner with auto-detection
@@ -92,34 +93,50 @@ func (r *OSQueryRunner) IsExecutable(path string) bool {
 	return r.isExecutable(path)
 }
 
-// getCurrentUser returns the current logged-in user for macOS
+// getConsoleUser returns the non-root user at the macOS console, or "" if there is none
+func (r *OSQueryRunner) getConsoleUser() string {
+	// Try to get the current console user
+	cmd := exec.Command("stat", "-f", "%Su", "/dev/console")
+	output, err := cmd.Output()
+	if err == nil {
+		username := strings.TrimSpace(string(output))
+		if username != "" && username != "root" {
+			return username
+		}
+	}
+
+	// Fallback: try to get from who command
+	cmd = exec.Command("who")
+	output, err = cmd.Output()
+	if err == nil {
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		for _, line := range lines {
+			parts := strings.Fields(line)
+			if len(parts) > 0 {
+				username := parts[0]
+				if username != "root" {
+					return username
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// getCurrentUser returns the current logged-in user
 func (r *OSQueryRunner) getCurrentUser() (string, error) {
 	// For macOS, try to get the current console user
 	if runtime.GOOS == "darwin" {
-		// Try to get the current console user
-		cmd := exec.Command("stat", "-f", "%Su", "/dev/console")
-		output, err := cmd.Output()
-		if err == nil {
-			username := strings.TrimSpace(string(output))
-			if username != "" && username != "root" {
-				return username, nil
-			}
+		if username := r.getConsoleUser(); username != "" {
+			return username, nil
 		}
+	}
 
-		// Fallback: try to get from who command
-		cmd = exec.Command("who")
-		output, err = cmd.Output()
-		if err == nil {
-			lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-			for _, line := range lines {
-				parts := strings.Fields(line)
-				if len(parts) > 0 {
-					username := parts[0]
-					if username != "root" {
-						return username, nil
-					}
-				}
-			}
+	// On Linux, use the first active session from logind/utmp
+	if runtime.GOOS == "linux" {
+		users, err := r.getActiveUsers()
+		if err == nil && len(users) > 0 {
+			return users[0], nil
 		}
 	}
 
@@ -132,25 +149,146 @@ func (r *OSQueryRunner) getCurrentUser() (string, error) {
 	return currentUser.Username, nil
 }
 
-// ExecuteQueryAsUser executes an osquery query as a specific user using su command
-func (r *OSQueryRunner) ExecuteQueryAsUser(queryName string, query string, username string) ([]map[string]interface{}, error) {
+// LoggedInUser returns the user at the console or the first active session,
+// falling back to the user running the agent
+func (r *OSQueryRunner) LoggedInUser() (string, error) {
+	return r.getCurrentUser()
+}
+
+// getActiveUsers returns every non-root user with an active local or remote session.
+// It never falls back to the agent's own user.
+func (r *OSQueryRunner) getActiveUsers() ([]string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		sessions, err := ListLinuxSessions()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list user sessions: %w", err)
+		}
+		for _, session := range sessions {
+			utils.Debug("Found session %s: user=%s type=%s tty=%s remote=%t", session.ID, session.Username, session.Type, session.TTY, session.Remote)
+		}
+		return activeUsernames(sessions), nil
+	case "darwin":
+		if username := r.getConsoleUser(); username != "" {
+			return []string{username}, nil
+		}
+	}
+	return nil, nil
+}
+
+// userQueryRunner runs a query in the agent's own context or as another user
+type userQueryRunner interface {
+	ExecuteQuery(ctx context.Context, queryName string, query string) ([]map[string]interface{}, error)
+	ExecuteQueryAsUser(ctx context.Context, queryName string, query string, username string) ([]map[string]interface{}, error)
+}
+
+// ExecuteQueryForUsers runs a user-scoped query once per active user and tags
+// each result row with the username it was collected for, in UserContextColumn
+func (r *OSQueryRunner) ExecuteQueryForUsers(ctx context.Context, queryName string, query string) ([]map[string]interface{}, error) {
+	// Without root we cannot switch users, so run once in our own context
+	if runtime.GOOS == "windows" || os.Geteuid() != 0 {
+		return executeInOwnContext(ctx, r, queryName, query)
+	}
+
+	users, err := r.getActiveUsers()
+	if err != nil {
+		return nil, err
+	}
+	return executeForUsers(ctx, r, r.watchdog, queryName, query, users)
+}
+
+// executeInOwnContext runs a user-scoped query as the agent's user
+func executeInOwnContext(ctx context.Context, runner userQueryRunner, queryName string, query string) ([]map[string]interface{}, error) {
+	results, err := runner.ExecuteQuery(ctx, queryName, query)
+	if err != nil {
+		return nil, err
+	}
+	username := ""
+	if currentUser, err := user.Current(); err == nil {
+		username = currentUser.Username
+	}
+	return tagResults(results, username), nil
+}
+
+// executeForUsers runs a user-scoped query as each of users, skipping those the
+// watchdog has denylisted. Without any active user the query runs in the agent's
+// own context, as there is nobody to drop privileges to.
+func executeForUsers(ctx context.Context, runner userQueryRunner, watchdog *Watchdog, queryName string, query string, users []string) ([]map[string]interface{}, error) {
+	if len(users) == 0 {
+		utils.Info("No active user sessions found, running user-scoped query '%s' in the agent's context", queryName)
+		return executeInOwnContext(ctx, runner, queryName, query)
+	}
+
+	var combined []map[string]interface{}
+	var lastErr error
+	for _, username := range users {
+		if ctx.Err() != nil {
+			return nil, queryContextError(ctx, queryName)
+		}
+		if watchdog != nil {
+			if until := watchdog.DenylistedUntil(queryName, username); !until.IsZero() {
+				utils.Warning("Skipping user-scoped query '%s' for user '%s': denylisted until %s", queryName, username, until.Format(time.RFC3339))
+				lastErr = &SkippedQueryError{
+					QueryName: queryName,
+					Reason:    fmt.Sprintf("denylisted for user '%s' until %s after repeated resource limit violations", username, until.Format(time.RFC3339)),
+				}
+				continue
+			}
+		}
+		results, err := runner.ExecuteQueryAsUser(ctx, queryName, query, username)
+		if err != nil {
+			utils.Warning("User-scoped query '%s' failed for user '%s': %v", queryName, username, err)
+			lastErr = err
+			continue
+		}
+		combined = append(combined, tagResults(results, username)...)
+	}
+
+	// Only fail if no user produced results
+	if combined == nil && lastErr != nil {
+		return nil, lastErr
+	}
+	if combined == nil {
+		combined = []map[string]interface{}{}
+	}
+	return combined, nil
+}
+
+// UserContextColumn names the column user-scoped query rows are tagged with. It is
+// distinct from osquery's own "username" columns so query results are never overwritten.
+const UserContextColumn = "scanx_user"
+
+// tagResults adds the user context column to every row
+func tagResults(results []map[string]interface{}, username string) []map[string]interface{} {
+	for _, row := range results {
+		row[UserContextColumn] = username
+	}
+	return results
+}
+
+// ExecuteQueryAsUser executes an osquery query as a specific user by dropping
+// privileges directly in the child process. The query is fed over stdin so no
+// temporary file is written, and the child gets a minimal, user-specific environment.
+func (r *OSQueryRunner) ExecuteQueryAsUser(ctx context.Context, queryName string, query string, username string) ([]map[string]interface{}, error) {
 	utils.Info("Executing query '%s' as user '%s'", queryName, username)
 
-	// Create temporary query file
-	queryFile := "/tmp/scanx_query.sql"
-	err := os.WriteFile(queryFile, []byte(query), 0644)
+	targetUser, err := user.Lookup(username)
 	if err != nil {
-		return nil, fmt.Errorf("failed to write query file: %w", err)
+		return nil, fmt.Errorf("failed to look up user '%s': %w", username, err)
 	}
-	defer os.Remove(queryFile) // Clean up after execution
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	sysProcAttr, err := userSysProcAttr(targetUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build credentials for user '%s': %w", username, err)
+	}
 
-	// Execute osquery as the specified user using su
-	suCmd := fmt.Sprintf("osqueryi --json < %s", queryFile)
-	cmd := exec.CommandContext(ctx, "su", "-", username, "-c", suCmd)
+	// Execute the detected osqueryi binary directly - no shell, no PATH lookup
+	cmd := exec.CommandContext(ctx, r.osqueryPath, "--json")
+	cmd.SysProcAttr = sysProcAttr
+	cmd.Env = buildUserEnv(targetUser)
+	cmd.Dir = userWorkingDir(targetUser)
+	cmd.Stdin = strings.NewReader(query)
+	setProcessGroup(cmd)
 
 	// Capture both stdout and stderr
 	var stdout, stderr strings.Builder
@@ -158,11 +296,11 @@ func (r *OSQueryRunner) ExecuteQueryAsUser(queryName string, query string, usern
 	cmd.Stderr = &stderr
 
 	// Execute command
-	err = cmd.Run()
+	err = r.runCommand(queryName, username, cmd)
 
-	// Check for context timeout
-	if ctx.Err() == context.DeadlineExceeded {
-		return nil, fmt.Errorf("osquery execution timed out after 30 seconds for query: %s", queryName)
+	// Check for context timeout or cancellation
+	if ctxErr := queryContextError(ctx, queryName); ctxErr != nil {
+		return nil, ctxErr
 	}
 
 	// Check for execution errors
@@ -196,26 +334,12 @@ func (r *OSQueryRunner) ExecuteQueryAsUser(queryName string, query string, usern
 }
 
 // ExecuteQuery executes an osquery SQL query and returns JSON results with improved process handling
-func (r *OSQueryRunner) ExecuteQuery(queryName string, query string) ([]map[string]interface{}, error) {
+func (r *OSQueryRunner) ExecuteQuery(ctx context.Context, queryName string, query string) ([]map[string]interface{}, error) {
 	utils.Info("Executing queryName: %s with osquery path: %s", queryName, r.osqueryPath)
 
-	// For user-specific queries on macOS, execute as current user
-	if (runtime.GOOS == "darwin" || runtime.GOOS == "linux") && (queryName == "screen_lock_info" || strings.Contains(strings.ToLower(query), "screenlock")) {
-		username, err := r.getCurrentUser()
-		if err != nil {
-			utils.Info("Failed to get current user, falling back to root execution: %v", err)
-		} else {
-			utils.Info("Executing user-specific query '%s' as user '%s'", queryName, username)
-			return r.ExecuteQueryAsUser(queryName, query, username)
-		}
-	}
-
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	// Create command with context
 	cmd := exec.CommandContext(ctx, r.osqueryPath, "--json", query)
+	setProcessGroup(cmd)
 
 	// Set environment variables for better compatibility
 	// Use current user context instead of root for user-specific queries
@@ -230,11 +354,11 @@ func (r *OSQueryRunner) ExecuteQuery(queryName string, query string) ([]map[stri
 	cmd.Stderr = &stderr
 
 	// Execute command
-	err := cmd.Run()
+	err := r.runCommand(queryName, "", cmd)
 
-	// Check for context timeout
-	if ctx.Err() == context.DeadlineExceeded {
-		return nil, fmt.Errorf("osquery execution timed out after 30 seconds for query: %s", queryName)
+	// Check for context timeout or cancellation
+	if ctxErr := queryContextError(ctx, queryName); ctxErr != nil {
+		return nil, ctxErr
 	}
 
 	// Check for execution errors
@@ -267,6 +391,55 @@ func (r *OSQueryRunner) ExecuteQuery(queryName string, query string) ([]map[stri
 	return results, nil
 }
 
+// SetWatchdog enables resource limit enforcement for every osqueryi child
+func (r *OSQueryRunner) SetWatchdog(watchdog *Watchdog) {
+	r.watchdog = watchdog
+}
+
+// runCommand runs the command under the watchdog when one is configured; username
+// is the user the query runs as, or empty for the agent's own context
+func (r *OSQueryRunner) runCommand(queryName, username string, cmd *exec.Cmd) error {
+	if r.watchdog == nil {
+		return cmd.Run()
+	}
+	return r.watchdog.run(queryName, username, cmd)
+}
+
+// queryContextError describes why a query's context ended, or returns nil if it is still live
+func queryContextError(ctx context.Context, queryName string) error {
+	switch ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return fmt.Errorf("osquery execution timed out for query: %s: %w", queryName, ctx.Err())
+	default:
+		return fmt.Errorf("osquery execution cancelled for query: %s: %w", queryName, ctx.Err())
+	}
+}
+
+// buildUserEnv returns a clean environment for a process running as the given user,
+// with HOME falling back to "/" like the working directory. Nothing is inherited
+// from the agent's own (root) environment.
+func buildUserEnv(u *user.User) []string {
+	return []string{
+		"PATH=/usr/local/bin:/usr/bin:/bin",
+		"USER=" + u.Username,
+		"LOGNAME=" + u.Username,
+		"LANG=C",
+		"HOME=" + userWorkingDir(u),
+	}
+}
+
+// userWorkingDir returns the user's home directory if it exists, otherwise "/"
+func userWorkingDir(u *user.User) string {
+	if u.HomeDir != "" {
+		if info, err := os.Stat(u.HomeDir); err == nil && info.IsDir() {
+			return u.HomeDir
+		}
+	}
+	return string(os.PathSeparator)
+}
+
 // GetOSQueryPath returns the detected osquery path
 func (r *OSQueryRunner) GetOSQueryPath() string {
 	return r.osqueryPath
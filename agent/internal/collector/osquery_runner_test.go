@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildUserEnv(t *testing.T) {
+	t.Setenv("SCANX_TEST_SECRET", "leaked")
+	home := t.TempDir()
+
+	tests := []struct {
+		name     string
+		user     *user.User
+		wantHome string
+	}{
+		{name: "existing home", user: &user.User{Uid: "1000", Username: "alice", HomeDir: home}, wantHome: home},
+		{name: "missing home", user: &user.User{Uid: "1001", Username: "bob", HomeDir: filepath.Join(home, "absent")}, wantHome: string(os.PathSeparator)},
+		{name: "no home", user: &user.User{Uid: "1002", Username: "carol"}, wantHome: string(os.PathSeparator)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := make(map[string]string)
+			for _, entry := range buildUserEnv(tt.user) {
+				key, value, ok := strings.Cut(entry, "=")
+				if !ok {
+					t.Fatalf("malformed environment entry %q", entry)
+				}
+				if _, dup := env[key]; dup {
+					t.Errorf("%s set twice", key)
+				}
+				env[key] = value
+			}
+
+			want := map[string]string{
+				"HOME":    tt.wantHome,
+				"USER":    tt.user.Username,
+				"LOGNAME": tt.user.Username,
+			}
+			for key, value := range want {
+				if env[key] != value {
+					t.Errorf("%s = %q, want %q", key, env[key], value)
+				}
+			}
+			if env["PATH"] == "" {
+				t.Error("PATH is not set")
+			}
+
+			for key := range env {
+				switch key {
+				case "PATH", "HOME", "USER", "LOGNAME", "LANG":
+				default:
+					t.Errorf("unexpected variable %s in user environment", key)
+				}
+			}
+			if _, ok := env["SCANX_TEST_SECRET"]; ok {
+				t.Error("caller's environment leaked into the user environment")
+			}
+		})
+	}
+}
+
+func TestTagResultsKeepsQueryColumns(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"username": "bob", "screen_lock": "true"},
+		{"screen_lock": "false"},
+	}
+	tagged := tagResults(rows, "alice")
+
+	if tagged[0]["username"] != "bob" {
+		t.Errorf("query column username overwritten: %v", tagged[0])
+	}
+	for i, row := range tagged {
+		if row[UserContextColumn] != "alice" {
+			t.Errorf("row %d: %s = %v, want alice", i, UserContextColumn, row[UserContextColumn])
+		}
+	}
+}
+
+func TestExecuteForUsersTagsRowsPerUser(t *testing.T) {
+	runner := &fakeRunner{failUsers: map[string]bool{"bob": true}}
+	rows, err := executeForUsers(context.Background(), runner, nil, "screen_lock_info", "SELECT 1;", []string{"alice", "bob", "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(runner.asUsers, ",") != "alice,bob,carol" {
+		t.Errorf("ran as %v, want every active user", runner.asUsers)
+	}
+	// A failure for one user does not drop the others' rows
+	if len(rows) != 2 || rows[0][UserContextColumn] != "alice" || rows[1][UserContextColumn] != "carol" {
+		t.Errorf("rows = %v, want alice's and carol's", rows)
+	}
+}
+
+func TestExecuteForUsersWithoutUsersRunsInOwnContext(t *testing.T) {
+	runner := &fakeRunner{}
+	rows, err := executeForUsers(context.Background(), runner, nil, "screen_lock_info", "SELECT 1;", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.asUsers) != 0 {
+		t.Errorf("dropped privileges to %v without an active user", runner.asUsers)
+	}
+	if len(runner.started) != 1 || len(rows) != 1 {
+		t.Errorf("ran %v with %d rows, want one run in the agent's context", runner.started, len(rows))
+	}
+	current, err := user.Current()
+	if err == nil && rows[0][UserContextColumn] != current.Username {
+		t.Errorf("%s = %v, want %s", UserContextColumn, rows[0][UserContextColumn], current.Username)
+	}
+}
+
+func TestExecuteForUsersSkipsDenylistedUser(t *testing.T) {
+	watchdog := NewWatchdog(ResourceLimits{CPUTime: time.Second}, t.TempDir())
+	for i := 0; i < 2; i++ {
+		watchdog.record("screen_lock_info", "alice", 2*time.Second, 0, "")
+	}
+
+	runner := &fakeRunner{}
+	rows, err := executeForUsers(context.Background(), runner, watchdog, "screen_lock_info", "SELECT 1;", []string{"alice", "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(runner.asUsers, ",") != "bob" || len(rows) != 1 {
+		t.Errorf("ran as %v with %d rows, want only bob", runner.asUsers, len(rows))
+	}
+
+	// With every user denylisted the skip is reported
+	_, err = executeForUsers(context.Background(), runner, watchdog, "screen_lock_info", "SELECT 1;", []string{"alice"})
+	var skipped *SkippedQueryError
+	if !errors.As(err, &skipped) {
+		t.Errorf("err = %v, want a SkippedQueryError", err)
+	}
+}
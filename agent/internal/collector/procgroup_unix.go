@@ -0,0 +1,30 @@
+//go:build !windows
+
+package collector
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// processWaitDelay bounds how long Wait blocks on output pipes after the child is killed
+const processWaitDelay = 5 * time.Second
+
+// setProcessGroup starts the command in its own process group so cancellation
+// kills osqueryi together with any extension or worker processes it spawned
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	cmd.Cancel = func() error {
+		if cmd.Process == nil {
+			return nil
+		}
+		// Negative pid signals the whole group
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = processWaitDelay
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package collector
+
+import (
+	"os/exec"
+	"time"
+)
+
+// processWaitDelay bounds how long Wait blocks on output pipes after the child is killed
+const processWaitDelay = 5 * time.Second
+
+// setProcessGroup relies on the default Kill on cancellation; Windows has no POSIX process groups
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = processWaitDelay
+}
@@ -0,0 +1,148 @@
+//go:build linux
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicksPerSecond is USER_HZ, which is 100 on every mainstream Linux architecture
+const clockTicksPerSecond = 100
+
+// procStat is the part of /proc/<pid>/stat the watchdog uses
+type procStat struct {
+	pgrp int
+	// cpuTicks is the process's own CPU time plus that of the children it has reaped
+	cpuTicks uint64
+	rssPages uint64
+}
+
+// sampleProcessGroup sums the live CPU time and resident set size of every process in
+// the group led by pgid, so extensions and workers osqueryi spawns are counted too.
+// Only the leader's descendants are visited, found through the kernel's per-thread
+// children lists; kernels without those lists fall back to scanning all of /proc.
+func sampleProcessGroup(pgid int) (cpu time.Duration, rss uint64, ok bool) {
+	pids, err := descendants(pgid)
+	if err != nil {
+		if pids, err = allProcesses(); err != nil {
+			return 0, 0, false
+		}
+	}
+
+	var ticks, pages uint64
+	for _, pid := range pids {
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			// The process exited since it was listed
+			continue
+		}
+		stat, err := parseProcStat(string(data))
+		if err != nil || stat.pgrp != pgid {
+			continue
+		}
+		ok = true
+		ticks += stat.cpuTicks
+		pages += stat.rssPages
+	}
+
+	cpu = time.Duration(ticks) * time.Second / clockTicksPerSecond
+	rss = pages * uint64(os.Getpagesize())
+	return cpu, rss, ok
+}
+
+// descendants returns root and every process below it. It fails only when the
+// kernel does not provide /proc/<pid>/task/<tid>/children.
+func descendants(root int) ([]int, error) {
+	if _, err := os.Stat(fmt.Sprintf("/proc/%d/task/%d/children", root, root)); err != nil {
+		if os.IsNotExist(err) && processExists(root) {
+			return nil, err
+		}
+		// The leader has exited; there is nothing left to walk
+		return nil, nil
+	}
+
+	pids := []int{root}
+	seen := map[int]bool{root: true}
+	for i := 0; i < len(pids); i++ {
+		tasks, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", pids[i]))
+		if err != nil {
+			continue
+		}
+		for _, task := range tasks {
+			data, err := os.ReadFile(fmt.Sprintf("/proc/%d/task/%s/children", pids[i], task.Name()))
+			if err != nil {
+				continue
+			}
+			for _, field := range strings.Fields(string(data)) {
+				child, err := strconv.Atoi(field)
+				if err == nil && !seen[child] {
+					seen[child] = true
+					pids = append(pids, child)
+				}
+			}
+		}
+	}
+	return pids, nil
+}
+
+// processExists reports whether /proc has an entry for pid
+func processExists(pid int) bool {
+	_, err := os.Stat(fmt.Sprintf("/proc/%d", pid))
+	return err == nil
+}
+
+// allProcesses lists every pid in /proc
+func allProcesses() ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// parseProcStat parses one /proc/<pid>/stat line
+func parseProcStat(stat string) (procStat, error) {
+	// The command name may contain spaces; fields are counted after the closing paren
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return procStat{}, fmt.Errorf("no command name in stat line")
+	}
+	fields := strings.Fields(stat[end+1:])
+	// Fields after the name start at field 3 of the full line; rss is field 24
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("stat line has %d fields after the name", len(fields))
+	}
+
+	// pgrp is field 5; utime, stime, cutime and cstime are fields 14 to 17
+	pgrp, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return procStat{}, fmt.Errorf("invalid pgrp: %w", err)
+	}
+	var ticks uint64
+	for _, field := range fields[11:15] {
+		// cutime and cstime are signed in the kernel but never negative in practice
+		value, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return procStat{}, fmt.Errorf("invalid cpu time: %w", err)
+		}
+		if value > 0 {
+			ticks += uint64(value)
+		}
+	}
+	rssPages, err := strconv.ParseUint(fields[21], 10, 64)
+	if err != nil {
+		return procStat{}, fmt.Errorf("invalid rss: %w", err)
+	}
+
+	return procStat{pgrp: pgrp, cpuTicks: ticks, rssPages: rssPages}, nil
+}
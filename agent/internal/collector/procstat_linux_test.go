@@ -0,0 +1,102 @@
+//go:build linux
+
+package collector
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	// Command names may contain spaces and parens
+	line := "4242 (osquery (worker)) S 1 4200 4200 0 -1 4194560 100 0 0 0 30 12 5 3 20 0 1 0 100 12345678 2048 18446744073709551615"
+	stat, err := parseProcStat(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.pgrp != 4200 || stat.cpuTicks != 50 || stat.rssPages != 2048 {
+		t.Errorf("parsed %+v, want pgrp 4200, 50 ticks, 2048 pages", stat)
+	}
+
+	for _, bad := range []string{"", "4242 osquery S 1", "4242 (osqueryi) S 1 4200"} {
+		if _, err := parseProcStat(bad); err == nil {
+			t.Errorf("parseProcStat(%q) succeeded", bad)
+		}
+	}
+}
+
+// startSleepingGroup starts a shell leading its own process group with two sleeping children
+func startSleepingGroup(tb testing.TB) *exec.Cmd {
+	tb.Helper()
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		tb.Skip("/proc not available")
+	}
+
+	cmd := exec.CommandContext(context.Background(), "sh", "-c", "sleep 5 & sleep 5 & wait")
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		tb.Skipf("cannot start shell: %v", err)
+	}
+	tb.Cleanup(func() {
+		cmd.Cancel()
+		cmd.Wait()
+	})
+	return cmd
+}
+
+func TestSampleProcessGroupCountsChildren(t *testing.T) {
+	cmd := startSleepingGroup(t)
+
+	leaderRSS := uint64(0)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		data, err := os.ReadFile("/proc/" + strconv.Itoa(cmd.Process.Pid) + "/stat")
+		if err == nil {
+			if stat, err := parseProcStat(string(data)); err == nil {
+				leaderRSS = stat.rssPages * uint64(os.Getpagesize())
+			}
+		}
+		if _, rss, ok := sampleProcessGroup(cmd.Process.Pid); ok && leaderRSS > 0 && rss > leaderRSS {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("group RSS never exceeded the leader's own RSS")
+}
+
+func TestDescendantsVisitsOnlyTheGroup(t *testing.T) {
+	cmd := startSleepingGroup(t)
+	if _, err := descendants(cmd.Process.Pid); err != nil {
+		t.Skipf("kernel has no children lists: %v", err)
+	}
+
+	// The shell plus its two children, not every process on the host
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pids, err := descendants(cmd.Process.Pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pids) == 3 {
+			return
+		}
+		if len(pids) > 3 || time.Now().After(deadline) {
+			t.Fatalf("descendants = %v, want the shell and its two children", pids)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func BenchmarkSampleProcessGroup(b *testing.B) {
+	cmd := startSleepingGroup(b)
+	time.Sleep(100 * time.Millisecond)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sampleProcessGroup(cmd.Process.Pid)
+	}
+}
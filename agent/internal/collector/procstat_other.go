@@ -0,0 +1,10 @@
+//go:build !linux
+
+package collector
+
+import "time"
+
+// sampleProcessGroup has no cheap live source outside Linux; limits are checked from rusage after exit
+func sampleProcessGroup(pgid int) (cpu time.Duration, rss uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Query statuses reported in CollectedData.QueryMeta
+const (
+	QueryStatusOK          = "ok"
+	QueryStatusEmpty       = "empty"
+	QueryStatusError       = "error"
+	QueryStatusTimeout     = "timeout"
+	QueryStatusUnsupported = "unsupported"
+	QueryStatusSkipped     = "skipped"
+)
+
+// Executors reported in QueryMeta.Executor
+const (
+	ExecutorOSQuery       = "osqueryi"
+	ExecutorOSQueryAsUser = "osqueryi_as_user"
+	ExecutorNone          = "none"
+)
+
+// QueryMeta describes how a single query ran, separately from its result rows
+type QueryMeta struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	RowCount   int    `json:"row_count"`
+	DurationMs int64  `json:"duration_ms"`
+	Executor   string `json:"executor"`
+}
+
+// SkippedQueryError reports a query that was deliberately not run
+type SkippedQueryError struct {
+	QueryName string
+	Reason    string
+}
+
+func (e *SkippedQueryError) Error() string {
+	return fmt.Sprintf("query '%s' skipped: %s", e.QueryName, e.Reason)
+}
+
+// queryStatus classifies a query outcome
+func queryStatus(rows []map[string]interface{}, err error) string {
+	var unsupported *UnsupportedQueryError
+	var skipped *SkippedQueryError
+	switch {
+	case err == nil && len(rows) == 0:
+		return QueryStatusEmpty
+	case err == nil:
+		return QueryStatusOK
+	case errors.As(err, &unsupported):
+		return QueryStatusUnsupported
+	case errors.As(err, &skipped):
+		return QueryStatusSkipped
+	case errors.Is(err, context.DeadlineExceeded):
+		return QueryStatusTimeout
+	default:
+		return QueryStatusError
+	}
+}
@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"scanx/internal/config"
+)
+
+// hashPrefix marks values that were replaced by a salted hash
+const hashPrefix = "sha256:"
+
+// applyRedaction applies column-level redaction rules to every row in place
+func applyRedaction(rows []map[string]interface{}, rules *config.RedactionRules, salt string) []map[string]interface{} {
+	if rules.IsEmpty() {
+		return rows
+	}
+
+	allow := toSet(rules.Allow)
+	for _, row := range rows {
+		if len(allow) > 0 {
+			for column := range row {
+				if !allow[column] {
+					delete(row, column)
+				}
+			}
+		}
+
+		for _, column := range rules.Drop {
+			delete(row, column)
+		}
+
+		for _, column := range rules.Hash {
+			if value, ok := row[column]; ok && value != nil {
+				row[column] = hashValue(fmt.Sprint(value), salt)
+			}
+		}
+
+		for column, limit := range rules.Truncate {
+			value, ok := row[column].(string)
+			if !ok || limit < 0 {
+				continue
+			}
+			if runes := []rune(value); len(runes) > limit {
+				row[column] = string(runes[:limit])
+			}
+		}
+	}
+
+	return rows
+}
+
+// hashValue returns a salted, hex-encoded HMAC-SHA256 of the value
+func hashValue(value string, salt string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+	return hashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// toSet converts a list of column names into a lookup set
+func toSet(columns []string) map[string]bool {
+	set := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		set[column] = true
+	}
+	return set
+}
@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"scanx/internal/config"
+)
+
+func TestRedactionSaltIsRandomAndPersistent(t *testing.T) {
+	dir := t.TempDir()
+	first, err := loadOrCreateRedactionSalt(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := loadOrCreateRedactionSalt(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != again {
+		t.Fatalf("salt changed between loads: %s -> %s", first, again)
+	}
+
+	other, err := loadOrCreateRedactionSalt(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Fatal("two devices generated the same salt")
+	}
+}
+
+func TestRedactHashesWithDeviceSaltNotDeviceID(t *testing.T) {
+	c := &Collector{
+		config:   &config.Config{},
+		sysInfo:  SystemInfo{DeviceID: "scanx-device"},
+		stateDir: t.TempDir(),
+	}
+	rules := &config.RedactionRules{Hash: []string{"username"}}
+
+	rows, applied := c.redact([]map[string]interface{}{{"username": "alice"}}, rules)
+	got, _ := rows[0]["username"].(string)
+	if !strings.HasPrefix(got, hashPrefix) {
+		t.Fatalf("username = %v, want a hash", rows[0]["username"])
+	}
+	if got == hashValue("alice", "scanx-device") {
+		t.Fatal("value was hashed with the device ID, which is sent in the same report")
+	}
+	if len(applied.Hash) != 1 || len(applied.Drop) != 0 {
+		t.Errorf("applied rules = %+v, want the hash rule", applied)
+	}
+
+	c.config.Agent.RedactionSalt = "configured"
+	rows, _ = c.redact([]map[string]interface{}{{"username": "alice"}}, rules)
+	if rows[0]["username"] != hashValue("alice", "configured") {
+		t.Errorf("configured salt was not used: %v", rows[0]["username"])
+	}
+}
+
+func TestRedactDropsHashedColumnsWithoutSalt(t *testing.T) {
+	// Without a state directory there is nowhere to keep a salt
+	c := &Collector{config: &config.Config{}}
+
+	rules := &config.RedactionRules{Hash: []string{"username"}, Drop: []string{"uid"}}
+	rows, applied := c.redact([]map[string]interface{}{{"username": "alice", "uid": 501, "shell": "/bin/zsh"}}, rules)
+	if _, ok := rows[0]["username"]; ok {
+		t.Errorf("username kept without a salt: %v", rows[0])
+	}
+	if rows[0]["shell"] != "/bin/zsh" {
+		t.Errorf("unrelated column lost: %v", rows[0])
+	}
+	if len(applied.Hash) != 0 || len(applied.Drop) != 2 {
+		t.Errorf("applied rules = %+v, want username reported as dropped", applied)
+	}
+	if len(rules.Drop) != 1 {
+		t.Errorf("configured rules were modified: %+v", rules)
+	}
+}
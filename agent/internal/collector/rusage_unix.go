@@ -0,0 +1,29 @@
+//go:build !windows
+
+package collector
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// processUsage returns total CPU time and peak RSS in bytes from a finished process
+func processUsage(state *os.ProcessState) (time.Duration, uint64) {
+	if state == nil {
+		return 0, 0
+	}
+
+	cpu := state.UserTime() + state.SystemTime()
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || rusage.Maxrss <= 0 {
+		return cpu, 0
+	}
+
+	// ru_maxrss is reported in bytes on macOS and kilobytes elsewhere
+	if runtime.GOOS == "darwin" {
+		return cpu, uint64(rusage.Maxrss)
+	}
+	return cpu, uint64(rusage.Maxrss) * 1024
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package collector
+
+import (
+	"os"
+	"time"
+)
+
+// processUsage returns total CPU time from a finished process; peak RSS is not available
+func processUsage(state *os.ProcessState) (time.Duration, uint64) {
+	if state == nil {
+		return 0, 0
+	}
+	return state.UserTime() + state.SystemTime(), 0
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"scanx/internal/securestore"
+	"scanx/internal/utils"
+)
+
+// redactionSaltFile holds the random per-device salt used when agent.conf sets none
+const redactionSaltFile = "redaction.salt"
+
+// loadOrCreateRedactionSalt returns the device's redaction salt from the encrypted
+// state directory, generating it on first use. It never leaves the machine, so
+// hashed values cannot be reversed by a dictionary lookup on the backend.
+func loadOrCreateRedactionSalt(stateDir string) (string, error) {
+	if stateDir == "" {
+		return "", fmt.Errorf("no state directory to keep the redaction salt in")
+	}
+	store, err := securestore.Open(stateDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to open state store: %w", err)
+	}
+	path := filepath.Join(stateDir, redactionSaltFile)
+
+	if data, err := store.ReadFile(path); err == nil {
+		salt := strings.TrimSpace(string(data))
+		if len(salt) != 64 {
+			return "", fmt.Errorf("redaction salt %s is corrupt; remove it to generate a new salt", path)
+		}
+		return salt, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("failed to read redaction salt: %w", err)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate redaction salt: %w", err)
+	}
+	salt := hex.EncodeToString(raw)
+
+	// Exclusive create so concurrent processes agree on a single salt
+	if err := store.CreateFile(path, []byte(salt+"\n")); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return loadOrCreateRedactionSalt(stateDir)
+		}
+		return "", fmt.Errorf("failed to save redaction salt: %w", err)
+	}
+
+	utils.Info("Generated new redaction salt in %s", path)
+	return salt, nil
+}
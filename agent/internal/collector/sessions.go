@@ -0,0 +1,232 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Session describes a logged-in user session discovered on the host
+type Session struct {
+	ID        string
+	Username  string
+	UID       int
+	TTY       string
+	Type      string // x11, wayland, tty, remote, unspecified
+	Remote    bool
+	Graphical bool
+}
+
+// logindSessionsDir is where systemd-logind publishes per-session state files
+const logindSessionsDir = "/run/systemd/sessions"
+
+// utmpPaths lists the utmp database locations in order of preference
+var utmpPaths = []string{"/run/utmp", "/var/run/utmp"}
+
+// ListLinuxSessions returns active local user sessions, preferring logind and
+// falling back to utmp on systems without systemd
+func ListLinuxSessions() ([]Session, error) {
+	sessions, err := listLogindSessions(logindSessionsDir)
+	if err == nil && len(sessions) > 0 {
+		return sessions, nil
+	}
+
+	var lastErr error = err
+	for _, path := range utmpPaths {
+		sessions, err := listUtmpSessions(path)
+		if err == nil {
+			return sessions, nil
+		}
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no session sources available")
+	}
+	return nil, lastErr
+}
+
+// listLogindSessions parses the logind session state files
+func listLogindSessions(dir string) ([]Session, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []Session
+	for _, entry := range entries {
+		// Skip reference files such as "*.ref" used for session fd passing
+		if entry.IsDir() || strings.Contains(entry.Name(), ".") {
+			continue
+		}
+
+		fields, err := readKeyValueFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		// Ignore greeters, lock screens and background sessions
+		if fields["CLASS"] != "user" {
+			continue
+		}
+		if state := fields["STATE"]; state != "active" && state != "online" {
+			continue
+		}
+
+		uid, _ := strconv.Atoi(fields["UID"])
+		sessionType := fields["TYPE"]
+		session := Session{
+			ID:        entry.Name(),
+			Username:  fields["USER"],
+			UID:       uid,
+			TTY:       fields["TTY"],
+			Type:      sessionType,
+			Remote:    fields["REMOTE"] == "1",
+			Graphical: sessionType == "x11" || sessionType == "wayland" || sessionType == "mir",
+		}
+		if session.Username == "" {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+// readKeyValueFile reads a KEY=VALUE file as written by systemd
+func readKeyValueFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return fields, scanner.Err()
+}
+
+// utmpUserProcess is the ut_type value for a normal login process
+const utmpUserProcess = 7
+
+// utmpRecord mirrors glibc's 384-byte struct utmp on Linux (amd64 and arm64)
+type utmpRecord struct {
+	Type    int16
+	_       [2]byte
+	PID     int32
+	Line    [32]byte
+	ID      [4]byte
+	User    [32]byte
+	Host    [256]byte
+	Exit    [2]int16
+	Session int32
+	TvSec   int32
+	TvUsec  int32
+	AddrV6  [4]int32
+	_       [20]byte
+}
+
+// listUtmpSessions parses a utmp file and returns live user processes
+func listUtmpSessions(path string) ([]Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bytes.NewReader(data)
+	var sessions []Session
+	for {
+		var record utmpRecord
+		if err := binary.Read(reader, binary.LittleEndian, &record); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse utmp %s: %w", path, err)
+		}
+
+		if record.Type != utmpUserProcess {
+			continue
+		}
+		// utmp entries can be stale after a crash; only trust live login processes
+		if _, err := os.Stat(fmt.Sprintf("/proc/%d", record.PID)); err != nil {
+			continue
+		}
+
+		line := cString(record.Line[:])
+		host := cString(record.Host[:])
+		session := Session{
+			ID:        strconv.Itoa(int(record.Session)),
+			Username:  cString(record.User[:]),
+			UID:       -1,
+			TTY:       line,
+			Remote:    host != "" && !strings.HasPrefix(host, ":"),
+			Graphical: strings.HasPrefix(line, ":") || strings.HasPrefix(host, ":"),
+		}
+		switch {
+		case session.Graphical:
+			session.Type = "x11"
+		case session.Remote:
+			session.Type = "remote"
+		default:
+			session.Type = "tty"
+		}
+		if session.Username == "" {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+// cString converts a NUL-padded byte array to a string
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// sortSessions orders sessions so local graphical sessions come first
+func sortSessions(sessions []Session) {
+	sort.SliceStable(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		if a.Graphical != b.Graphical {
+			return a.Graphical
+		}
+		if a.Remote != b.Remote {
+			return !a.Remote
+		}
+		return a.Username < b.Username
+	})
+}
+
+// activeUsernames returns the distinct non-root users across sessions, in session order
+func activeUsernames(sessions []Session) []string {
+	seen := make(map[string]bool)
+	var users []string
+	for _, session := range sessions {
+		if session.Username == "root" || seen[session.Username] {
+			continue
+		}
+		seen[session.Username] = true
+		users = append(users, session.Username)
+	}
+	return users
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	"scanx/internal/software"
+	"scanx/internal/utils"
+)
+
+// CollectSoftware runs only this platform's software inventory queries and returns
+// the normalized items. Redaction applies as in a full collection; queries that
+// fail are logged and skipped, and it fails only when none succeeded.
+func (c *Collector) CollectSoftware(ctx context.Context) ([]software.Item, error) {
+	queries, err := c.config.GetPlatformQueries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get platform queries: %w", err)
+	}
+
+	var queryNames []string
+	for _, queryName := range software.InventoryQueries {
+		if _, ok := queries[queryName]; ok {
+			queryNames = append(queryNames, queryName)
+		}
+	}
+	if len(queryNames) == 0 {
+		return nil, fmt.Errorf("no software inventory queries for platform: %s", runtime.GOOS)
+	}
+
+	results := c.runQueries(ctx, queryNames, queries, CollectOptions{})
+	if ctx.Err() == context.Canceled {
+		return nil, fmt.Errorf("software collection cancelled: %w", ctx.Err())
+	}
+
+	data := make(map[string][]map[string]interface{})
+	var lastErr error
+	for i, queryName := range queryNames {
+		rows, err := results[i].rows, results[i].err
+		if err != nil {
+			if status := queryStatus(rows, err); status == QueryStatusUnsupported || status == QueryStatusSkipped {
+				utils.Info("Skipping query '%s': %v", queryName, err)
+			} else {
+				utils.Warning("Failed to execute query '%s': %v", queryName, err)
+			}
+			lastErr = err
+			continue
+		}
+		if redaction := queries[queryName].Redaction; !redaction.IsEmpty() {
+			rows, _ = c.redact(rows, redaction)
+		}
+		data[queryName] = rows
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("all software inventory queries failed: %w", lastErr)
+	}
+
+	return software.Normalize(data, localDistro()), nil
+}
+
+// localDistro identifies the running Linux distribution for package URLs
+func localDistro() software.Distro {
+	if runtime.GOOS != "linux" {
+		return software.Distro{}
+	}
+	fields, err := utils.ReadOSRelease(utils.OSReleasePath)
+	if err != nil {
+		return software.Distro{}
+	}
+	return software.Distro{ID: fields["ID"], VersionID: fields["VERSION_ID"]}
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"scanx/internal/software"
+	"scanx/internal/utils"
+	"scanx/internal/vulndb"
+)
+
+// Vulnerability database statuses reported in CollectedData.VulnerabilityDB
+const (
+	VulnDBStatusOK          = "ok"
+	VulnDBStatusUnavailable = "unavailable"
+	VulnDBStatusError       = "error"
+)
+
+// vulnDBDirName is the default database location inside the state directory
+const vulnDBDirName = "vulndb"
+
+// vulnDBRefreshTimeout bounds the download of a newer database
+const vulnDBRefreshTimeout = 2 * time.Minute
+
+// VulnerabilityScan describes the database a collection was matched against
+type VulnerabilityScan struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Path   string `json:"path"`
+	// Entries is the number of vulnerability records in the database
+	Entries int `json:"entries,omitempty"`
+	// Updated is the newest record modification time, showing how current the data is
+	Updated string `json:"updated,omitempty"`
+	// Packages is the number of installed packages that were checked
+	Packages int `json:"packages"`
+}
+
+// vulnDBCache keeps the parsed database between collections
+type vulnDBCache struct {
+	db          *vulndb.Database
+	fingerprint string
+}
+
+// scanVulnerabilities matches the software inventoried in data against the local
+// OSV database, first refreshing it from vulnerability_db_url when configured.
+// A missing database is not an error: the scan is reported as unavailable.
+func (c *Collector) scanVulnerabilities(ctx context.Context, data map[string][]map[string]interface{}) ([]vulndb.Finding, *VulnerabilityScan) {
+	path := c.vulnDBPath()
+	scan := &VulnerabilityScan{Status: VulnDBStatusOK, Path: path}
+
+	if url := c.config.Agent.VulnerabilityDBURL; url != "" {
+		c.refreshVulnDB(ctx, url, path)
+	}
+
+	fingerprint, err := vulndb.Fingerprint(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			utils.Debug("No vulnerability database at %s, skipping vulnerability matching", path)
+			scan.Status = VulnDBStatusUnavailable
+		} else {
+			scan.Status = VulnDBStatusError
+			scan.Error = err.Error()
+		}
+		return nil, scan
+	}
+
+	if c.vulnDB.db == nil || fingerprint != c.vulnDB.fingerprint {
+		db, err := vulndb.Load(path)
+		if err != nil {
+			utils.Warning("Failed to load vulnerability database: %v", err)
+			scan.Status = VulnDBStatusError
+			scan.Error = err.Error()
+			if c.vulnDB.db == nil {
+				return nil, scan
+			}
+			// Keep matching against the last good copy
+		} else {
+			utils.Info("Loaded %d vulnerability records from %s", db.Entries, path)
+			c.vulnDB = vulnDBCache{db: db, fingerprint: fingerprint}
+		}
+	}
+
+	db := c.vulnDB.db
+	scan.Entries = db.Entries
+	if !db.Modified.IsZero() {
+		scan.Updated = db.Modified.UTC().Format(time.RFC3339)
+	}
+
+	linuxEcosystem := ""
+	if runtime.GOOS == "linux" {
+		linuxEcosystem = vulndb.LinuxEcosystem(utils.OSReleasePath)
+	}
+
+	var packages []vulndb.InstalledPackage
+	for _, queryName := range software.InventoryQueries {
+		packages = append(packages, vulndb.PackagesFromRows(queryName, data[queryName], linuxEcosystem)...)
+	}
+	scan.Packages = len(packages)
+
+	findings := db.Match(packages)
+	if len(findings) > 0 {
+		utils.Warning("Found %d known vulnerabilities in installed software", len(findings))
+	}
+	return findings, scan
+}
+
+// refreshVulnDB downloads a newer signed database and caches the parsed copy
+func (c *Collector) refreshVulnDB(ctx context.Context, url, path string) {
+	key, err := c.config.GetVulnerabilityDBKey()
+	if err != nil {
+		utils.Warning("Using cached vulnerability database, vulnerability_db_key is invalid: %v", err)
+		return
+	}
+
+	refreshCtx, cancel := context.WithTimeout(ctx, vulnDBRefreshTimeout)
+	db, err := vulndb.Refresh(refreshCtx, &http.Client{}, url, path, key)
+	cancel()
+	if err != nil {
+		utils.Warning("Using cached vulnerability database: %v", err)
+		return
+	}
+	if db == nil {
+		return
+	}
+
+	utils.Info("Downloaded vulnerability database from %s (%d records)", url, db.Entries)
+	if fingerprint, err := vulndb.Fingerprint(path); err == nil {
+		c.vulnDB = vulnDBCache{db: db, fingerprint: fingerprint}
+	}
+}
+
+// vulnDBPath returns the configured database path, defaulting to the state directory
+func (c *Collector) vulnDBPath() string {
+	if c.config.Agent.VulnerabilityDB != "" {
+		return c.config.Agent.VulnerabilityDB
+	}
+	return filepath.Join(utils.GetStateDir(), vulnDBDirName)
+}
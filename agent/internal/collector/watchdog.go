@@ -0,0 +1,277 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"scanx/internal/securestore"
+	"scanx/internal/utils"
+)
+
+// watchdogStateFile persists the denylist across agent restarts
+const watchdogStateFile = "watchdog.json"
+
+// Denylist back-off: base period doubled for each consecutive violation, capped at maxDenyPeriod
+const (
+	baseDenyPeriod = 15 * time.Minute
+	maxDenyPeriod  = 24 * time.Hour
+	samplePeriod   = 250 * time.Millisecond
+)
+
+// ResourceLimits bounds the resources a single osqueryi child may consume; zero disables a limit
+type ResourceLimits struct {
+	CPUTime     time.Duration
+	MaxRSSBytes uint64
+}
+
+// QueryCost is the measured resource usage of a query during the last collection
+type QueryCost struct {
+	CPUTimeMs  int64  `json:"cpu_time_ms"`
+	PeakRSSKB  int64  `json:"peak_rss_kb"`
+	Runs       int    `json:"runs"`
+	Killed     bool   `json:"killed"`
+	KillReason string `json:"kill_reason,omitempty"`
+}
+
+// DenyState tracks resource limit violations for a query, or for one user's runs of a
+// user-scoped query
+type DenyState struct {
+	Violations int       `json:"violations"`
+	Until      time.Time `json:"denylisted_until"`
+}
+
+// Watchdog enforces resource limits on osquery children and denylists queries that keep exceeding them
+type Watchdog struct {
+	mu        sync.Mutex
+	limits    ResourceLimits
+	costs     map[string]*QueryCost
+	deny      map[string]*DenyState // keyed by denyKey
+	store     *securestore.Store
+	statePath string
+}
+
+// NewWatchdog creates a watchdog, restoring any persisted denylist from stateDir
+func NewWatchdog(limits ResourceLimits, stateDir string) *Watchdog {
+	w := &Watchdog{
+		limits: limits,
+		costs:  make(map[string]*QueryCost),
+		deny:   make(map[string]*DenyState),
+	}
+
+	if stateDir == "" {
+		return w
+	}
+	store, err := securestore.Open(stateDir)
+	if err != nil {
+		utils.Warning("Watchdog denylist will not persist: %v", err)
+		return w
+	}
+
+	w.store = store
+	w.statePath = filepath.Join(stateDir, watchdogStateFile)
+	if data, err := store.ReadFile(w.statePath); err == nil {
+		if err := json.Unmarshal(data, &w.deny); err != nil {
+			utils.Warning("Ignoring unreadable watchdog state %s: %v", w.statePath, err)
+			w.deny = make(map[string]*DenyState)
+		}
+	}
+
+	return w
+}
+
+// denyKey identifies a query's runs for one user; username is empty for queries
+// that run in the agent's own context
+func denyKey(queryName, username string) string {
+	if username == "" {
+		return queryName
+	}
+	return queryName + "@" + username
+}
+
+// DenylistedUntil returns the end of the back-off period for the query run as username
+// (empty for the agent's own context), or zero if it may run
+func (w *Watchdog) DenylistedUntil(queryName, username string) time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	state, ok := w.deny[denyKey(queryName, username)]
+	if !ok || time.Now().After(state.Until) {
+		return time.Time{}
+	}
+	return state.Until
+}
+
+// ResetCosts clears the measured costs at the start of a collection cycle
+func (w *Watchdog) ResetCosts() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.costs = make(map[string]*QueryCost)
+}
+
+// Snapshot returns copies of the measured costs and the active denylist
+func (w *Watchdog) Snapshot() (map[string]QueryCost, map[string]DenyState) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	costs := make(map[string]QueryCost, len(w.costs))
+	for name, cost := range w.costs {
+		costs[name] = *cost
+	}
+
+	deny := make(map[string]DenyState)
+	for name, state := range w.deny {
+		if time.Now().Before(state.Until) {
+			deny[name] = *state
+		}
+	}
+	return costs, deny
+}
+
+// record accumulates a run's cost and updates the denylist. Costs are kept per query,
+// violations per query and user so one user's data cannot deny the query for everyone.
+func (w *Watchdog) record(queryName, username string, cpu time.Duration, peakRSS uint64, killReason string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// User-scoped queries run several times per cycle; sum CPU and keep the peak RSS
+	cost, ok := w.costs[queryName]
+	if !ok {
+		cost = &QueryCost{}
+		w.costs[queryName] = cost
+	}
+	cost.Runs++
+	cost.CPUTimeMs += cpu.Milliseconds()
+	if rssKB := int64(peakRSS / 1024); rssKB > cost.PeakRSSKB {
+		cost.PeakRSSKB = rssKB
+	}
+
+	// A run that finished but still overshot the limits counts as a violation too
+	if killReason == "" {
+		killReason = w.exceeded(cpu, peakRSS)
+	} else {
+		cost.Killed = true
+	}
+
+	key := denyKey(queryName, username)
+	if killReason == "" {
+		// Clean run - forget earlier violations
+		if _, denied := w.deny[key]; denied {
+			delete(w.deny, key)
+			w.save()
+		}
+		return
+	}
+
+	cost.KillReason = killReason
+	state, ok := w.deny[key]
+	if !ok {
+		state = &DenyState{}
+		w.deny[key] = state
+	}
+	state.Violations++
+
+	// First violation is tolerated; repeat offenders back off exponentially
+	if state.Violations > 1 {
+		period := baseDenyPeriod << (state.Violations - 2)
+		if period > maxDenyPeriod || period <= 0 {
+			period = maxDenyPeriod
+		}
+		state.Until = time.Now().Add(period)
+		utils.Warning("Query '%s' denylisted for %v after %d resource limit violations", key, period, state.Violations)
+	}
+	w.save()
+}
+
+// exceeded describes which limit the measured usage broke, or returns empty
+func (w *Watchdog) exceeded(cpu time.Duration, rss uint64) string {
+	if w.limits.CPUTime > 0 && cpu > w.limits.CPUTime {
+		return fmt.Sprintf("cpu time %v exceeded limit %v", cpu.Round(time.Millisecond), w.limits.CPUTime)
+	}
+	if w.limits.MaxRSSBytes > 0 && rss > w.limits.MaxRSSBytes {
+		return fmt.Sprintf("peak rss %d MB exceeded limit %d MB", rss/(1024*1024), w.limits.MaxRSSBytes/(1024*1024))
+	}
+	return ""
+}
+
+// save persists the denylist; caller must hold the lock
+func (w *Watchdog) save() {
+	if w.store == nil {
+		return
+	}
+	data, err := json.MarshalIndent(w.deny, "", "    ")
+	if err != nil {
+		return
+	}
+	if err := w.store.WriteFile(w.statePath, data); err != nil {
+		utils.Warning("Failed to persist watchdog state: %v", err)
+	}
+}
+
+// run starts the command, samples its process group's usage while it runs and kills
+// the group if a limit is exceeded. Usage is recorded against queryName and username.
+func (w *Watchdog) run(queryName, username string, cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	waitDone := make(chan error, 1)
+	go func() { waitDone <- cmd.Wait() }()
+
+	ticker := time.NewTicker(samplePeriod)
+	defer ticker.Stop()
+
+	var peakRSS uint64
+	var sampledCPU time.Duration
+	var killReason string
+
+	for {
+		select {
+		case err := <-waitDone:
+			cpu, maxRSS := processUsage(cmd.ProcessState)
+			if cpu < sampledCPU {
+				cpu = sampledCPU
+			}
+			if maxRSS > peakRSS {
+				peakRSS = maxRSS
+			}
+			w.record(queryName, username, cpu, peakRSS, killReason)
+
+			if killReason != "" {
+				return fmt.Errorf("osquery query '%s' killed by resource watchdog: %s", queryName, killReason)
+			}
+			return err
+
+		case <-ticker.C:
+			if killReason != "" {
+				continue
+			}
+			// The child leads its own process group (see setProcessGroup)
+			cpu, rss, ok := sampleProcessGroup(cmd.Process.Pid)
+			if !ok {
+				continue
+			}
+			sampledCPU = cpu
+			if rss > peakRSS {
+				peakRSS = rss
+			}
+			if reason := w.exceeded(cpu, rss); reason != "" {
+				killReason = reason
+				utils.Warning("Killing osquery for query '%s': %s", queryName, reason)
+				killProcess(cmd)
+			}
+		}
+	}
+}
+
+// killProcess kills the command's process group when one was configured, else the process
+func killProcess(cmd *exec.Cmd) {
+	if cmd.Cancel != nil {
+		cmd.Cancel()
+		return
+	}
+	cmd.Process.Kill()
+}
@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchdogDeniesPerUser(t *testing.T) {
+	w := NewWatchdog(ResourceLimits{MaxRSSBytes: 1024}, t.TempDir())
+
+	// Repeated violations while running as alice deny only alice's runs
+	for i := 0; i < 2; i++ {
+		w.record("chrome_extensions", "alice", time.Millisecond, 4096, "")
+	}
+	if w.DenylistedUntil("chrome_extensions", "alice").IsZero() {
+		t.Fatal("query not denylisted for the user that exceeded the limit")
+	}
+	if until := w.DenylistedUntil("chrome_extensions", "bob"); !until.IsZero() {
+		t.Errorf("query denylisted for another user until %v", until)
+	}
+	if until := w.DenylistedUntil("chrome_extensions", ""); !until.IsZero() {
+		t.Errorf("query denylisted in the agent context until %v", until)
+	}
+
+	// A clean run for bob leaves alice's back-off alone
+	w.record("chrome_extensions", "bob", time.Millisecond, 512, "")
+	if w.DenylistedUntil("chrome_extensions", "alice").IsZero() {
+		t.Error("clean run for another user cleared the denylist")
+	}
+
+	costs, deny := w.Snapshot()
+	if costs["chrome_extensions"].Runs != 3 {
+		t.Errorf("runs = %d, want costs summed across users", costs["chrome_extensions"].Runs)
+	}
+	if _, ok := deny[denyKey("chrome_extensions", "alice")]; !ok || len(deny) != 1 {
+		t.Errorf("denylist = %v, want only alice's entry", deny)
+	}
+}
+
+func TestWatchdogDenylistPersistsPerUser(t *testing.T) {
+	dir := t.TempDir()
+	w := NewWatchdog(ResourceLimits{CPUTime: time.Second}, dir)
+	for i := 0; i < 2; i++ {
+		w.record("screen_lock_info", "alice", 2*time.Second, 0, "")
+	}
+
+	restored := NewWatchdog(ResourceLimits{CPUTime: time.Second}, dir)
+	if restored.DenylistedUntil("screen_lock_info", "alice").IsZero() {
+		t.Error("per-user denylist entry not restored")
+	}
+	if !restored.DenylistedUntil("screen_lock_info", "bob").IsZero() {
+		t.Error("restored denylist applies to another user")
+	}
+}
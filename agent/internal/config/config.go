@@ -1,12 +1,14 @@
 package config
 
 import (
-	"encoding/json"
+	"crypto/ed25519"
+	"encoding/base64"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"runtime"
 	"scanx/internal/utils"
+	"strings"
 	"time"
 )
 
@@ -17,12 +19,142 @@ type AgentConfig struct {
 	Interval   string `json:"interval"`
 	LogLevel   string `json:"log_level"`
 	BackendURL string `json:"backend_url"`
+
+	// Schedule is an optional 5-field cron expression; when empty Interval is used
+	Schedule string `json:"schedule,omitempty"`
+	// Splay is the maximum per-device delay added to each scheduled run
+	Splay string `json:"splay,omitempty"`
+	// QuietHours are local-time windows during which heavy queries are suppressed
+	QuietHours []QuietWindow `json:"quiet_hours,omitempty"`
+
+	// MaxConcurrentQueries bounds how many osquery processes run at once
+	MaxConcurrentQueries int `json:"max_concurrent_queries,omitempty"`
+	// CollectionTimeout is the overall deadline for one collection cycle
+	CollectionTimeout string `json:"collection_timeout,omitempty"`
+
+	// QueryCPULimit and QueryMemoryLimitMB cap each osqueryi child; exceeding them kills the
+	// query and zero disables the limit
+	QueryCPULimit      string `json:"query_cpu_limit,omitempty"`
+	QueryMemoryLimitMB *int   `json:"query_memory_limit_mb,omitempty"`
+
+	// RedactionSalt is mixed into hashed columns so values cannot be reversed by dictionary lookup;
+	// when empty a random per-device salt is generated and kept in the encrypted state directory
+	RedactionSalt string `json:"redaction_salt,omitempty"`
+	// Redaction sets column redaction rules per query, keyed by query name; it replaces
+	// any `redaction` block the query has in the query config
+	Redaction map[string]RedactionRules `json:"redaction,omitempty"`
+
+	// EnrollmentDomain is the company email domain `scanx enroll` uses to guess the user's address
+	EnrollmentDomain string `json:"enrollment_domain,omitempty"`
+
+	// VulnerabilityDB is the OSV database matched against installed software: a directory
+	// of .json/.zip files, a zip archive or a JSON file; defaults to the vulndb state directory
+	VulnerabilityDB string `json:"vulnerability_db,omitempty"`
+	// VulnerabilityDBURL is where a newer database is downloaded from before each collection;
+	// when empty or unreachable the local copy is used as is
+	VulnerabilityDBURL string `json:"vulnerability_db_url,omitempty"`
+	// VulnerabilityDBKey is the base64 Ed25519 public key downloads must be signed with;
+	// required with vulnerability_db_url
+	VulnerabilityDBKey string `json:"vulnerability_db_key,omitempty"`
+
+	// ExtensionDenylist are browser extension IDs reported as findings wherever they are installed
+	ExtensionDenylist []string `json:"extension_denylist,omitempty"`
+	// ExtensionAllowlist, when set, makes every installed extension missing from it a finding
+	ExtensionAllowlist []string `json:"extension_allowlist,omitempty"`
+
+	// TrustedReportKeys are fingerprints of other devices' signing keys whose reports
+	// `scanx send --file` accepts; this device's own key is always trusted
+	TrustedReportKeys []string `json:"trusted_report_keys,omitempty"`
+
+	// StateKeyRotation is how old the state encryption key may get before the daemon rotates it; "0s" disables
+	StateKeyRotation string `json:"state_key_rotation,omitempty"`
+
+	// Sinks are the outputs each report is delivered to; when empty only the REST backend is used
+	Sinks []SinkConfig `json:"sinks,omitempty"`
+}
+
+// Sink types accepted in SinkConfig.Type
+const (
+	SinkTypeBackend       = "backend"
+	SinkTypeFile          = "file"
+	SinkTypeSyslog        = "syslog"
+	SinkTypeSplunkHEC     = "splunk_hec"
+	SinkTypeElasticsearch = "elasticsearch"
+	SinkTypeWebhook       = "webhook"
+)
+
+// Webhook payload modes accepted in SinkConfig.Mode
+const (
+	WebhookModeReport  = "report"  // every full report
+	WebhookModeChanges = "changes" // only compliance checks and findings that changed since the last delivery
+)
+
+// SinkConfig configures one output sink. Which fields apply depends on Type:
+// backend uses URL (defaulting to backend_url), file uses Path/MaxSizeMB/MaxFiles,
+// syslog uses Network/Address, splunk_hec and elasticsearch use URL/Token/Index,
+// webhook uses URL/Secret/Mode/DeadLetterDir.
+type SinkConfig struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+
+	URL        string `json:"url,omitempty"`
+	Token      string `json:"token,omitempty"`
+	Index      string `json:"index,omitempty"`
+	SourceType string `json:"sourcetype,omitempty"`
+
+	Path      string `json:"path,omitempty"`
+	MaxSizeMB int    `json:"max_size_mb,omitempty"`
+	MaxFiles  int    `json:"max_files,omitempty"`
+
+	Network string `json:"network,omitempty"`
+	Address string `json:"address,omitempty"`
+
+	// Secret is the HMAC-SHA256 key used to sign webhook deliveries
+	Secret        string `json:"secret,omitempty"`
+	Mode          string `json:"mode,omitempty"`
+	DeadLetterDir string `json:"dead_letter_dir,omitempty"`
+
+	// Timeout bounds each delivery attempt; Retries and RetryBackoff control re-attempts
+	Timeout      string `json:"timeout,omitempty"`
+	Retries      *int   `json:"retries,omitempty"`
+	RetryBackoff string `json:"retry_backoff,omitempty"`
+}
+
+// QuietWindow is a daily local-time window such as {"start": "09:00", "end": "18:00", "days": ["mon", "fri"]}.
+// Windows where end is before start wrap past midnight; an empty day list means every day.
+type QuietWindow struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Days  []string `json:"days,omitempty"`
 }
 
 // QueryConfig represents a single query configuration
 type QueryConfig struct {
 	Query       string `yaml:"query"`
 	Description string `yaml:"description"`
+	UserContext bool   `yaml:"user_context"` // run once per logged-in user, rows tagged with scanx_user
+	Timeout     string `yaml:"timeout"`      // per-query timeout, defaults to DefaultQueryTimeout
+
+	MinOSQueryVersion string `yaml:"min_osquery_version"` // skip as unsupported on older osquery builds
+	Heavy             bool   `yaml:"heavy"`               // suppressed during quiet hours
+	LocalOnly         bool   `yaml:"local_only"`          // rows feed on-device analysis but are not uploaded
+	Compliance        bool   `yaml:"compliance"`          // a compliance check; changes-mode webhooks report only these
+
+	Redaction *RedactionRules `yaml:"redaction"`
+}
+
+// RedactionRules describes column-level data minimization applied before results leave the device.
+// Rules are applied in order: allow, drop, hash, truncate.
+type RedactionRules struct {
+	Allow    []string       `yaml:"allow" json:"allow,omitempty"`       // if set, only these columns are kept
+	Drop     []string       `yaml:"drop" json:"drop,omitempty"`         // columns removed entirely
+	Hash     []string       `yaml:"hash" json:"hash,omitempty"`         // columns replaced by a salted SHA-256
+	Truncate map[string]int `yaml:"truncate" json:"truncate,omitempty"` // columns cut to N characters
+}
+
+// IsEmpty reports whether the rules would leave rows unchanged
+func (r *RedactionRules) IsEmpty() bool {
+	return r == nil || (len(r.Allow) == 0 && len(r.Drop) == 0 && len(r.Hash) == 0 && len(r.Truncate) == 0)
 }
 
 // PlatformQueries represents queries for a specific platform
@@ -33,80 +165,83 @@ type QueriesConfig struct {
 	Platform map[string]PlatformQueries `yaml:"platform"`
 }
 
+// Collection defaults used when agent.conf or the query config does not specify a value
+const (
+	DefaultQueryTimeout         = 30 * time.Second
+	DefaultCollectionTimeout    = 5 * time.Minute
+	DefaultMaxConcurrentQueries = 4
+	DefaultQueryCPULimit        = 20 * time.Second
+	DefaultQueryMemoryLimitMB   = 512
+	DefaultSplay                = 5 * time.Minute
+
+	DefaultStateKeyRotation = 90 * 24 * time.Hour
+
+	DefaultSinkTimeout      = 30 * time.Second
+	DefaultSinkRetries      = 3
+	DefaultSinkRetryBackoff = 5 * time.Second
+	DefaultSinkMaxSizeMB    = 50
+	DefaultSinkMaxFiles     = 5
+)
+
+// GetTimeout returns the parsed per-query timeout with fallback to DefaultQueryTimeout
+func (q QueryConfig) GetTimeout() time.Duration {
+	if q.Timeout == "" {
+		return DefaultQueryTimeout
+	}
+
+	duration, err := time.ParseDuration(q.Timeout)
+	if err != nil || duration <= 0 {
+		return DefaultQueryTimeout
+	}
+
+	return duration
+}
+
 // Config holds all configuration data
 type Config struct {
 	Agent   AgentConfig
 	Queries QueriesConfig
+
+	// Dir is the config directory agent.conf was loaded from
+	Dir string
 }
 
-// LoadConfig loads agent.conf from file and uses embedded queries.go
-func LoadConfig() (*Config, error) {
-	// Try a series of candidate config directories so the binary works without -config
-	// this is a fallback for the case where the binary is not run with -config
-	candidateDirs := []string{
-		"config",                           // running from source tree / unpacked package
-		"/etc/scanx/config",                // standardized Unix install path
-		"C:\\Program Files\\scanx\\config", // Windows install path
-	}
-
-	var lastErr error
-	for _, dir := range candidateDirs {
-		cfg, err := LoadConfigFromPath(dir)
-		if err == nil {
-			return cfg, nil
+// candidateConfigDirs are searched in order when no config directory is given
+var candidateConfigDirs = []string{
+	"config",                           // running from source tree / unpacked package
+	"/etc/scanx/config",                // standardized Unix install path
+	"C:\\Program Files\\scanx\\config", // Windows install path
+}
+
+// FindConfigDir returns the first candidate directory containing agent.conf
+func FindConfigDir() (string, error) {
+	for _, dir := range candidateConfigDirs {
+		if _, err := os.Stat(configFilePath(dir)); err == nil {
+			return dir, nil
 		}
-		lastErr = err
 	}
+	return "", fmt.Errorf("no agent.conf found in %s: %w", strings.Join(candidateConfigDirs, ", "), fs.ErrNotExist)
+}
 
-	if lastErr == nil {
-		lastErr = fmt.Errorf("no configuration directories found")
-	}
-	return nil, lastErr
+// LoadConfig loads the layered agent configuration from the first standard
+// config directory containing agent.conf and uses embedded queries.go
+func LoadConfig() (*Config, error) {
+	return LoadConfigFromPath("")
 }
 
-// LoadConfigFromPath loads agent configuration from file and uses embedded queries
+// LoadConfigFromPath loads the layered agent configuration from a custom path and uses embedded queries
 func LoadConfigFromPath(configDir string) (*Config, error) {
-	config := &Config{}
-
-	// Load agent configuration from file
-	agentConfig, err := loadAgentConfigFromPath(configDir)
+	config, err := Load(LoadOptions{Dir: configDir})
 	if err != nil {
-		utils.Error("failed to load agent config: %w", err)
+		utils.Error("failed to load agent config: %v", err)
 		return nil, fmt.Errorf("failed to load agent config: %w", err)
 	}
-	config.Agent = *agentConfig
-	utils.Info("Agent config loaded successfully")
-
-	// Use embedded queries instead of loading from file
-	queriesConfig := GetQueriesConfig()
-	config.Queries = *queriesConfig
+	utils.Info("Agent config loaded successfully from %s", config.Dir)
 	utils.Info("Embedded queries loaded successfully")
 
 	return config, nil
 }
 
-// loadAgentConfigFromPath loads the agent.conf file from a custom path
-func loadAgentConfigFromPath(configDir string) (*AgentConfig, error) {
-	configPath := filepath.Join(configDir, "agent.conf")
-
-	// Check if file exists and is readable
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("agent config file does not exist: %s", configPath)
-	}
-
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read agent config file %s: %w", configPath, err)
-	}
-
-	var config AgentConfig
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse agent config: %w", err)
-	}
-
-	return &config, nil
-}
-
 // GetPlatformQueries returns queries for the current platform
 func (c *Config) GetPlatformQueries() (PlatformQueries, error) {
 	platform := runtime.GOOS
@@ -116,37 +251,29 @@ func (c *Config) GetPlatformQueries() (PlatformQueries, error) {
 		return nil, fmt.Errorf("no queries found for platform: %s", platform)
 	}
 
-	return queries, nil
-}
-
-// UpdateUserEmail updates the user email in agent.conf
-func UpdateUserEmail(email string) error {
-	configPath := filepath.Join("config", "agent.conf")
-
-	// Read current config
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read agent config file: %w", err)
+	if len(c.Agent.Redaction) == 0 {
+		return queries, nil
 	}
 
-	var config AgentConfig
-	if err := json.Unmarshal(data, &config); err != nil {
-		return fmt.Errorf("failed to parse agent config: %w", err)
+	// Apply redaction overrides from agent.conf on a copy so the embedded defaults stay intact
+	merged := make(PlatformQueries, len(queries))
+	for name, queryConfig := range queries {
+		if rules, ok := c.Agent.Redaction[name]; ok {
+			rules := rules
+			queryConfig.Redaction = &rules
+		}
+		merged[name] = queryConfig
 	}
 
-	// Update email
-	config.UserEmail = email
-
-	// Write back to file
-	updatedData, err := json.MarshalIndent(config, "", "    ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated config: %w", err)
-	}
+	return merged, nil
+}
 
-	if err := os.WriteFile(configPath, updatedData, 0644); err != nil {
-		return fmt.Errorf("failed to write updated config: %w", err)
+// UpdateUserEmail updates the user email in agent.conf of configDir, or of the
+// first standard config directory when configDir is empty
+func UpdateUserEmail(configDir, email string) error {
+	if _, err := SetField(configDir, "user_email", email); err != nil {
+		return fmt.Errorf("failed to update user email: %w", err)
 	}
-
 	return nil
 }
 
@@ -158,7 +285,7 @@ func (c *Config) GetInterval() time.Duration {
 
 	duration, err := time.ParseDuration(c.Agent.Interval)
 	if err != nil {
-		fmt.Printf("Warning: Invalid interval '%s', using default 1h\n", c.Agent.Interval)
+		fmt.Fprintf(utils.ConsoleOutput(), "Warning: Invalid interval '%s', using default 1h\n", c.Agent.Interval)
 		return time.Hour
 	}
 
@@ -176,7 +303,169 @@ func (c *Config) GetLogLevel() string {
 	case "debug", "info", "warning", "error":
 		return c.Agent.LogLevel
 	default:
-		fmt.Printf("Warning: Invalid log level '%s', using default 'info'\n", c.Agent.LogLevel)
+		fmt.Fprintf(utils.ConsoleOutput(), "Warning: Invalid log level '%s', using default 'info'\n", c.Agent.LogLevel)
 		return "info"
 	}
 }
+
+// GetMaxConcurrentQueries returns the worker limit for query execution with fallback to the default
+func (c *Config) GetMaxConcurrentQueries() int {
+	if c.Agent.MaxConcurrentQueries <= 0 {
+		return DefaultMaxConcurrentQueries
+	}
+	return c.Agent.MaxConcurrentQueries
+}
+
+// GetCollectionTimeout returns the overall collection deadline with fallback to the default
+func (c *Config) GetCollectionTimeout() time.Duration {
+	if c.Agent.CollectionTimeout == "" {
+		return DefaultCollectionTimeout
+	}
+
+	duration, err := time.ParseDuration(c.Agent.CollectionTimeout)
+	if err != nil || duration <= 0 {
+		fmt.Fprintf(utils.ConsoleOutput(), "Warning: Invalid collection_timeout '%s', using default %v\n", c.Agent.CollectionTimeout, DefaultCollectionTimeout)
+		return DefaultCollectionTimeout
+	}
+
+	return duration
+}
+
+// GetQueryCPULimit returns the per-query CPU time limit with fallback to the default
+func (c *Config) GetQueryCPULimit() time.Duration {
+	if c.Agent.QueryCPULimit == "" {
+		return DefaultQueryCPULimit
+	}
+
+	duration, err := time.ParseDuration(c.Agent.QueryCPULimit)
+	if err != nil || duration < 0 {
+		fmt.Fprintf(utils.ConsoleOutput(), "Warning: Invalid query_cpu_limit '%s', using default %v\n", c.Agent.QueryCPULimit, DefaultQueryCPULimit)
+		return DefaultQueryCPULimit
+	}
+
+	return duration
+}
+
+// GetQueryMemoryLimitBytes returns the per-query peak RSS limit in bytes with fallback to the
+// default; zero disables the limit
+func (c *Config) GetQueryMemoryLimitBytes() uint64 {
+	if c.Agent.QueryMemoryLimitMB == nil {
+		return DefaultQueryMemoryLimitMB * 1024 * 1024
+	}
+	limitMB := *c.Agent.QueryMemoryLimitMB
+	if limitMB < 0 {
+		fmt.Fprintf(utils.ConsoleOutput(), "Warning: Invalid query_memory_limit_mb %d, using default %d\n", limitMB, DefaultQueryMemoryLimitMB)
+		limitMB = DefaultQueryMemoryLimitMB
+	}
+	return uint64(limitMB) * 1024 * 1024
+}
+
+// GetVulnerabilityDBKey decodes the key vulnerability database downloads are verified with
+func (c *Config) GetVulnerabilityDBKey() (ed25519.PublicKey, error) {
+	return parseEd25519Key(c.Agent.VulnerabilityDBKey)
+}
+
+// parseEd25519Key decodes a base64 Ed25519 public key
+func parseEd25519Key(value string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("not a base64 Ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// GetSplay returns the maximum scheduling splay with fallback to the default
+func (c *Config) GetSplay() time.Duration {
+	if c.Agent.Splay == "" {
+		return DefaultSplay
+	}
+
+	duration, err := time.ParseDuration(c.Agent.Splay)
+	if err != nil || duration < 0 {
+		fmt.Fprintf(utils.ConsoleOutput(), "Warning: Invalid splay '%s', using default %v\n", c.Agent.Splay, DefaultSplay)
+		return DefaultSplay
+	}
+
+	return duration
+}
+
+// GetStateKeyRotation returns the maximum state key age; zero disables automatic rotation
+func (c *Config) GetStateKeyRotation() time.Duration {
+	if c.Agent.StateKeyRotation == "" {
+		return DefaultStateKeyRotation
+	}
+
+	duration, err := time.ParseDuration(c.Agent.StateKeyRotation)
+	if err != nil || duration < 0 {
+		fmt.Fprintf(utils.ConsoleOutput(), "Warning: Invalid state_key_rotation '%s', using default %v\n", c.Agent.StateKeyRotation, DefaultStateKeyRotation)
+		return DefaultStateKeyRotation
+	}
+
+	return duration
+}
+
+// GetName returns the sink's display name, defaulting to its type
+func (s SinkConfig) GetName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Type
+}
+
+// GetTimeout returns the per-attempt delivery timeout with fallback to DefaultSinkTimeout
+func (s SinkConfig) GetTimeout() time.Duration {
+	if s.Timeout == "" {
+		return DefaultSinkTimeout
+	}
+
+	duration, err := time.ParseDuration(s.Timeout)
+	if err != nil || duration <= 0 {
+		fmt.Fprintf(utils.ConsoleOutput(), "Warning: Invalid timeout '%s' for sink '%s', using default %v\n", s.Timeout, s.GetName(), DefaultSinkTimeout)
+		return DefaultSinkTimeout
+	}
+
+	return duration
+}
+
+// GetRetries returns how many times a failed delivery is retried; zero disables retries
+func (s SinkConfig) GetRetries() int {
+	if s.Retries == nil {
+		return DefaultSinkRetries
+	}
+	if *s.Retries < 0 {
+		fmt.Fprintf(utils.ConsoleOutput(), "Warning: Invalid retries %d for sink '%s', using default %d\n", *s.Retries, s.GetName(), DefaultSinkRetries)
+		return DefaultSinkRetries
+	}
+	return *s.Retries
+}
+
+// GetRetryBackoff returns the delay before the first retry; it doubles on each attempt
+func (s SinkConfig) GetRetryBackoff() time.Duration {
+	if s.RetryBackoff == "" {
+		return DefaultSinkRetryBackoff
+	}
+
+	duration, err := time.ParseDuration(s.RetryBackoff)
+	if err != nil || duration < 0 {
+		fmt.Fprintf(utils.ConsoleOutput(), "Warning: Invalid retry_backoff '%s' for sink '%s', using default %v\n", s.RetryBackoff, s.GetName(), DefaultSinkRetryBackoff)
+		return DefaultSinkRetryBackoff
+	}
+
+	return duration
+}
+
+// GetMaxSizeBytes returns the size at which a file sink rotates
+func (s SinkConfig) GetMaxSizeBytes() int64 {
+	if s.MaxSizeMB <= 0 {
+		return int64(DefaultSinkMaxSizeMB) * 1024 * 1024
+	}
+	return int64(s.MaxSizeMB) * 1024 * 1024
+}
+
+// GetMaxFiles returns how many rotated files a file sink keeps besides the active one
+func (s SinkConfig) GetMaxFiles() int {
+	if s.MaxFiles <= 0 {
+		return DefaultSinkMaxFiles
+	}
+	return s.MaxFiles
+}
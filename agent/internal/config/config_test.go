@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestGetQueryMemoryLimitBytes(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit *int
+		want  uint64
+	}{
+		{name: "unset", limit: nil, want: DefaultQueryMemoryLimitMB * 1024 * 1024},
+		{name: "zero disables", limit: intPtr(0), want: 0},
+		{name: "configured", limit: intPtr(256), want: 256 * 1024 * 1024},
+		{name: "negative", limit: intPtr(-1), want: DefaultQueryMemoryLimitMB * 1024 * 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Agent: AgentConfig{QueryMemoryLimitMB: tt.limit}}
+			if got := cfg.GetQueryMemoryLimitBytes(); got != tt.want {
+				t.Errorf("GetQueryMemoryLimitBytes() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadKeepsZeroMemoryLimit(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "agent.conf"), []byte(`{"query_memory_limit_mb": 0}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(LoadOptions{Dir: dir, Environ: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetQueryMemoryLimitBytes(); got != 0 {
+		t.Errorf("memory limit = %d, want 0 (disabled)", got)
+	}
+
+	cfg, err = Load(LoadOptions{Dir: dir, Environ: []string{}, Overrides: map[string]string{"query_memory_limit_mb": "128"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.GetQueryMemoryLimitBytes(); got != 128*1024*1024 {
+		t.Errorf("memory limit = %d, want the 128 MB override", got)
+	}
+}
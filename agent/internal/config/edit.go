@@ -0,0 +1,245 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// objectField is one top-level key of a config file, kept in file order
+type objectField struct {
+	key   string
+	value json.RawMessage
+}
+
+// SetField sets a top-level agent.conf field from its textual value and returns
+// the file written. The field is changed in the highest-precedence file that
+// already sets it (a drop-in or agent.conf), otherwise in agent.conf. Other
+// keys, including ones this version does not know, are kept as they are.
+func SetField(configDir, field, value string) (string, error) {
+	configDir, err := resolveConfigDir(configDir)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := FieldValueJSON(field, value)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
+	}
+	if err := validateField(field, raw); err != nil {
+		return "", err
+	}
+
+	files, err := configFiles(configDir)
+	if err != nil {
+		return "", err
+	}
+	target := files[0]
+	for _, path := range files {
+		fields, err := readObject(path)
+		if err != nil {
+			return "", err
+		}
+		if indexOf(fields, field) >= 0 {
+			target = path
+		}
+	}
+
+	fields, err := readObject(target)
+	if err != nil {
+		return "", err
+	}
+	if i := indexOf(fields, field); i >= 0 {
+		fields[i].value = raw
+	} else {
+		fields = append(fields, objectField{key: field, value: raw})
+	}
+
+	if err := writeObject(target, fields); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// UnsetField removes a top-level field from agent.conf and every drop-in that
+// sets it, so the default applies again. It returns the files changed.
+func UnsetField(configDir, field string) ([]string, error) {
+	configDir, err := resolveConfigDir(configDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckField(field); err != nil {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+
+	files, err := configFiles(configDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, path := range files {
+		fields, err := readObject(path)
+		if err != nil {
+			return changed, err
+		}
+		i := indexOf(fields, field)
+		if i < 0 {
+			continue
+		}
+		fields = append(fields[:i], fields[i+1:]...)
+		if err := writeObject(path, fields); err != nil {
+			return changed, err
+		}
+		changed = append(changed, path)
+	}
+	return changed, nil
+}
+
+// resolveConfigDir defaults an empty directory to the first standard location with agent.conf
+func resolveConfigDir(configDir string) (string, error) {
+	if configDir != "" {
+		return configDir, nil
+	}
+	return FindConfigDir()
+}
+
+// configFiles returns agent.conf followed by its drop-ins in precedence order
+func configFiles(configDir string) ([]string, error) {
+	dropIns, err := filepath.Glob(filepath.Join(configDir, DropInDirName, "*.conf"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", DropInDirName, err)
+	}
+	sort.Strings(dropIns)
+	return append([]string{configFilePath(configDir)}, dropIns...), nil
+}
+
+// validateField rejects a value that would make the configuration invalid
+func validateField(field string, raw json.RawMessage) error {
+	data, _ := json.Marshal(map[string]json.RawMessage{field: raw})
+	config, issues := ParseAgentConfig(data)
+	if config != nil {
+		issues = append(issues, config.Validate()...)
+	}
+
+	var problems []string
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			problems = append(problems, issue.Message)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid value for %s: %s", field, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// readObject reads a config file's top-level keys in file order
+func readObject(path string) ([]objectField, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("%s is not a JSON object", path)
+	}
+
+	var fields []objectField
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		fields = append(fields, objectField{key: token.(string), value: value})
+	}
+	return fields, nil
+}
+
+// writeObject atomically replaces a config file with the given keys, keeping
+// the file's permissions and owner
+func writeObject(path string, fields []objectField) error {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(field.key)
+		buf.WriteString("\n    ")
+		buf.Write(key)
+		buf.WriteString(": ")
+		if err := json.Indent(&buf, field.value, "    ", "    "); err != nil {
+			return fmt.Errorf("failed to format %s: %w", field.key, err)
+		}
+	}
+	if len(fields) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it and
+// renames it over path so readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	perm := os.FileMode(0600)
+	info, statErr := os.Stat(path)
+	if statErr == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if statErr == nil {
+		if err := preserveOwner(tmpPath, info); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func indexOf(fields []objectField, key string) int {
+	for i, field := range fields {
+		if field.key == key {
+			return i
+		}
+	}
+	return -1
+}
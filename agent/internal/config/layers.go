@@ -0,0 +1,344 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"scanx/internal/utils"
+)
+
+// Configuration layers, lowest precedence first. Each layer replaces whole
+// top-level fields of the ones before it; lists such as sinks are not appended.
+//
+//  1. built-in defaults
+//  2. agent.conf in the config directory
+//  3. agent.conf.d/*.conf in the config directory, in lexical order
+//  4. SCANX_<FIELD> environment variables (e.g. SCANX_LOG_LEVEL=debug)
+//  5. command-line overrides (-set field=value)
+const (
+	// DropInDirName is the directory of partial config files next to agent.conf
+	DropInDirName = "agent.conf.d"
+	// EnvPrefix prefixes environment variables that override agent.conf fields
+	EnvPrefix = "SCANX_"
+
+	// SourceDefault marks values that come from the built-in defaults
+	SourceDefault = "default"
+)
+
+// LoadOptions selects where layered configuration is read from
+type LoadOptions struct {
+	// Dir is the config directory; empty searches the standard locations
+	Dir string
+	// Overrides are command-line values keyed by agent.conf field name
+	Overrides map[string]string
+	// Environ replaces os.Environ() when set
+	Environ []string
+}
+
+// Layered is the merged agent configuration together with where each field came from
+type Layered struct {
+	Dir     string
+	Agent   AgentConfig
+	Values  map[string]json.RawMessage // merged top-level fields as JSON
+	Sources map[string]string          // field -> source of its value
+}
+
+// layer is one source of top-level agent.conf fields
+type layer struct {
+	source string
+	values map[string]json.RawMessage
+}
+
+// Load builds the layered agent configuration and the embedded queries, failing
+// on any error-level issue and logging warnings
+func Load(opts LoadOptions) (*Config, error) {
+	layered, issues, err := Resolve(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []Issue
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		} else {
+			utils.Warning("%s", issue.Describe())
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{File: configFilePath(layered.Dir), Issues: errs}
+	}
+
+	return &Config{
+		Agent:   layered.Agent,
+		Queries: *GetQueriesConfig(),
+		Dir:     layered.Dir,
+	}, nil
+}
+
+// Resolve merges every configuration layer and returns all problems found, each
+// attributed to the layer that supplied the offending value. Only a missing or
+// unreadable agent.conf is returned as an error.
+func Resolve(opts LoadOptions) (*Layered, []Issue, error) {
+	dir := opts.Dir
+	if dir == "" {
+		found, err := FindConfigDir()
+		if err != nil {
+			return nil, nil, err
+		}
+		dir = found
+	}
+
+	configPath := configFilePath(dir)
+	if _, err := os.Stat(configPath); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("agent config file does not exist: %s: %w", configPath, fs.ErrNotExist)
+	}
+
+	layers := []layer{defaultLayer()}
+	var issues []Issue
+
+	files := []string{configPath}
+	dropIns, err := filepath.Glob(filepath.Join(dir, DropInDirName, "*.conf"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list %s: %w", DropInDirName, err)
+	}
+	sort.Strings(dropIns)
+	files = append(files, dropIns...)
+
+	for _, path := range files {
+		fileLayer, fileIssues, err := readFileLayer(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read agent config file %s: %w", path, err)
+		}
+		layers = append(layers, fileLayer)
+		issues = append(issues, fileIssues...)
+	}
+
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	envLayers, envIssues := environmentLayers(environ)
+	layers = append(layers, envLayers...)
+	issues = append(issues, envIssues...)
+
+	flagLayers, flagIssues := overrideLayers(opts.Overrides)
+	layers = append(layers, flagLayers...)
+	issues = append(issues, flagIssues...)
+
+	layered := &Layered{
+		Dir:     dir,
+		Values:  make(map[string]json.RawMessage),
+		Sources: make(map[string]string),
+	}
+	for _, l := range layers {
+		for key, value := range l.values {
+			layered.Values[key] = value
+			layered.Sources[key] = l.source
+		}
+	}
+
+	// Each layer was checked for unknown fields and types already, so decoding the merge only fails on bugs
+	merged, err := json.Marshal(layered.Values)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to merge configuration: %w", err)
+	}
+	if err := json.Unmarshal(merged, &layered.Agent); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode merged configuration: %w", err)
+	}
+
+	for _, issue := range layered.Agent.Validate() {
+		issue.Source = layered.Sources[topLevelField(issue.Path)]
+		issues = append(issues, issue)
+	}
+
+	return layered, issues, nil
+}
+
+// defaultLayer returns the built-in defaults, matching the fallbacks used by the getters
+func defaultLayer() layer {
+	defaults := map[string]interface{}{
+		"interval":               "1h",
+		"log_level":              "info",
+		"splay":                  DefaultSplay.String(),
+		"collection_timeout":     DefaultCollectionTimeout.String(),
+		"max_concurrent_queries": DefaultMaxConcurrentQueries,
+		"query_cpu_limit":        DefaultQueryCPULimit.String(),
+		"query_memory_limit_mb":  DefaultQueryMemoryLimitMB,
+		"state_key_rotation":     DefaultStateKeyRotation.String(),
+	}
+
+	values := make(map[string]json.RawMessage, len(defaults))
+	for key, value := range defaults {
+		data, _ := json.Marshal(value)
+		values[key] = data
+	}
+	return layer{source: SourceDefault, values: values}
+}
+
+// readFileLayer parses one config file strictly; a file that cannot be parsed
+// contributes no values but its problems are reported
+func readFileLayer(path string) (layer, []Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return layer{}, nil, err
+	}
+
+	config, issues := ParseAgentConfig(data)
+	if config != nil {
+		issues = append(issues, checkFilePermissions(path, config.hasSecrets())...)
+		if HasErrors(issues) {
+			// The file is left out of the merge, so check its values here or
+			// their problems would only surface once the first ones are fixed
+			issues = append(issues, config.Validate()...)
+		}
+	}
+	for i := range issues {
+		issues[i].Source = path
+	}
+
+	result := layer{source: path}
+	if HasErrors(issues) {
+		return result, issues, nil
+	}
+	if err := json.Unmarshal(data, &result.values); err != nil {
+		return result, issues, nil
+	}
+	dropNulls(result.values)
+	return result, issues, nil
+}
+
+// environmentLayers turns SCANX_<FIELD> variables into one layer per variable
+func environmentLayers(environ []string) ([]layer, []Issue) {
+	fields := jsonFields(reflect.TypeOf(AgentConfig{}))
+
+	var layers []layer
+	var issues []Issue
+	for _, name := range sortedKeys(fields) {
+		envName := EnvPrefix + strings.ToUpper(name)
+		value, ok := lookupEnv(environ, envName)
+		if !ok {
+			continue
+		}
+		l, layerIssues := valueLayer("env "+envName, name, value)
+		if l != nil {
+			layers = append(layers, *l)
+		}
+		issues = append(issues, layerIssues...)
+	}
+	return layers, issues
+}
+
+// overrideLayers turns command-line overrides into one layer per field
+func overrideLayers(overrides map[string]string) ([]layer, []Issue) {
+	var layers []layer
+	var issues []Issue
+	for _, name := range sortedKeys(overrides) {
+		l, layerIssues := valueLayer("flag -set "+name, name, overrides[name])
+		if l != nil {
+			layers = append(layers, *l)
+		}
+		issues = append(issues, layerIssues...)
+	}
+	return layers, issues
+}
+
+// valueLayer converts a textual field value into a layer. String fields take the
+// text as-is; numbers, lists and objects must be given as JSON.
+func valueLayer(source, field, value string) (*layer, []Issue) {
+	raw, err := FieldValueJSON(field, value)
+	if err != nil {
+		return nil, []Issue{{Path: field, Message: err.Error(), Severity: SeverityError, Source: source}}
+	}
+
+	// Reuse the strict parser so type mismatches are reported like they are for files
+	data, _ := json.Marshal(map[string]json.RawMessage{field: raw})
+	_, issues := ParseAgentConfig(data)
+	for i := range issues {
+		issues[i].Source = source
+	}
+	if HasErrors(issues) {
+		return nil, issues
+	}
+	return &layer{source: source, values: map[string]json.RawMessage{field: raw}}, issues
+}
+
+// FieldValueJSON converts a textual value for a top-level agent.conf field into
+// JSON. String fields take the text as-is; other fields must be valid JSON.
+func FieldValueJSON(field, value string) (json.RawMessage, error) {
+	if err := CheckField(field); err != nil {
+		return nil, err
+	}
+	structField := jsonFields(reflect.TypeOf(AgentConfig{}))[field]
+
+	if structField.Type.Kind() == reflect.String {
+		data, _ := json.Marshal(value)
+		return data, nil
+	}
+	if !json.Valid([]byte(value)) {
+		return nil, fmt.Errorf("'%s' is not valid JSON; expected %s", value, jsonTypeName(structField.Type))
+	}
+	return json.RawMessage(value), nil
+}
+
+// CheckField returns an error naming the closest match if field is not a top-level agent.conf field
+func CheckField(field string) error {
+	fields := jsonFields(reflect.TypeOf(AgentConfig{}))
+	if _, known := fields[field]; known {
+		return nil
+	}
+	if suggestion := closestName(field, fields); suggestion != "" {
+		return fmt.Errorf("unknown field (did you mean '%s'?)", suggestion)
+	}
+	return fmt.Errorf("unknown field")
+}
+
+// lookupEnv finds a variable in an environment list
+func lookupEnv(environ []string, name string) (string, bool) {
+	for _, entry := range environ {
+		if key, value, ok := strings.Cut(entry, "="); ok && key == name {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// dropNulls removes fields explicitly set to null so they do not hide lower layers
+func dropNulls(values map[string]json.RawMessage) {
+	for key, value := range values {
+		if string(value) == "null" {
+			delete(values, key)
+		}
+	}
+}
+
+// topLevelField returns the first segment of a field path such as "sinks[0].url"
+func topLevelField(path string) string {
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// FieldNames returns the top-level agent.conf field names in declaration order
+func FieldNames() []string {
+	typ := reflect.TypeOf(AgentConfig{})
+	fields := jsonFields(typ)
+
+	names := make([]string, 0, len(fields))
+	for i := 0; i < typ.NumField(); i++ {
+		for name, field := range fields {
+			if field.Index[0] == i {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,77 @@
+//go:build !windows
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// checkFilePermissions flags config files (and their directory) that other users
+// could modify, and world-readable files holding secrets
+func checkFilePermissions(configPath string, hasSecrets bool) []Issue {
+	var issues []Issue
+
+	for _, path := range []string{configPath, filepath.Dir(configPath)} {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		mode := info.Mode().Perm()
+		// World-writable lets any local user change where data is sent; group-writable
+		// is common in source checkouts and only worth a warning
+		if mode&0002 != 0 {
+			issues = append(issues, Issue{
+				Message:  fmt.Sprintf("%s is writable by all users (mode %v)", path, mode),
+				Severity: SeverityError,
+			})
+		} else if mode&0020 != 0 {
+			issues = append(issues, Issue{
+				Message:  fmt.Sprintf("%s is writable by group (mode %v)", path, mode),
+				Severity: SeverityWarning,
+			})
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 && int(stat.Uid) != os.Geteuid() {
+			issues = append(issues, Issue{
+				Message:  fmt.Sprintf("%s is owned by uid %d; it must be owned by root or the agent user", path, stat.Uid),
+				Severity: SeverityError,
+			})
+		}
+	}
+
+	if info, err := os.Stat(configPath); err == nil && hasSecrets && info.Mode().Perm()&0004 != 0 {
+		issues = append(issues, Issue{
+			Message:  fmt.Sprintf("%s contains secrets but is world-readable (mode %v); use 0600 or 0640", configPath, info.Mode().Perm()),
+			Severity: SeverityWarning,
+		})
+	}
+
+	return issues
+}
+
+// preserveOwner gives a replacement file the owner and group of the file it replaces
+func preserveOwner(path string, original os.FileInfo) error {
+	stat, ok := original.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if int(stat.Uid) == os.Geteuid() && int(stat.Gid) == os.Getegid() {
+		return nil
+	}
+	if err := os.Chown(path, int(stat.Uid), int(stat.Gid)); err != nil {
+		return fmt.Errorf("failed to keep owner of %s: %w", original.Name(), err)
+	}
+	return nil
+}
+
+// syncDir flushes a directory so a rename within it survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package config
+
+import "os"
+
+// checkFilePermissions is a no-op on Windows, where access is governed by ACLs
+// set by the installer rather than mode bits
+func checkFilePermissions(configPath string, hasSecrets bool) []Issue {
+	return nil
+}
+
+// preserveOwner is a no-op on Windows; the replacement inherits the directory's ACLs
+func preserveOwner(path string, original os.FileInfo) error {
+	return nil
+}
+
+// syncDir is a no-op on Windows, where directories cannot be opened for flushing
+func syncDir(dir string) error {
+	return nil
+}
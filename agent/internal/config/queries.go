@@ -12,22 +12,38 @@ func GetQueriesConfig() *QueriesConfig {
 				"screen_lock_info": {
 					Query:       "SELECT CASE WHEN enabled = '1' THEN 'true' ELSE 'false' END AS screen_lock, grace_period FROM screenlock WHERE enabled IS NOT NULL;",
 					Description: "Screen lock information for macOS",
+					UserContext: true,
+					Compliance:  true,
 				},
 				"disk_encryption_info": {
 					Query:       "SELECT CASE WHEN COUNT(*) > 0 THEN 'true' ELSE 'false' END AS disk_encryption FROM disk_encryption WHERE uid != '' AND encrypted = '1';",
 					Description: "Disk encryption information for macOS",
+					Compliance:  true,
 				},
 				"password_manager_info": {
 					Query:       "SELECT CASE WHEN COUNT(*) > 0 THEN 'true' ELSE 'false' END AS password_manager FROM apps WHERE bundle_name IN ('MacPass','KeyPassXC','KeyPass');",
 					Description: "Password manager information for macOS",
+					Compliance:  true,
 				},
 				"antivirus_info": {
 					Query:       "SELECT CASE WHEN (SELECT assessments_enabled FROM gatekeeper LIMIT 1) = 1 THEN 'true' WHEN (SELECT global_state FROM alf LIMIT 1) = 1 THEN 'true' ELSE 'false' END AS antivirus_info;",
 					Description: "Gatekeeper information for macOS",
+					Compliance:  true,
 				},
 				"apps_info": {
 					Query:       "SELECT bundle_identifier, bundle_name, bundle_short_version, bundle_version, category, display_name, last_opened_time, minimum_system_version FROM apps;",
 					Description: "Installed apps information",
+					Heavy:       true,
+					LocalOnly:   true,
+				},
+				"chrome_extensions_info": {
+					Query:             "SELECT u.username, e.browser_type, e.profile, e.identifier, e.name, e.version, e.permissions, e.optional_permissions, e.from_webstore, e.state FROM users u CROSS JOIN chrome_extensions e USING (uid);",
+					Description:       "Chromium-based browser extensions of every local user",
+					MinOSQueryVersion: "5.0.0",
+				},
+				"firefox_addons_info": {
+					Query:       "SELECT u.username, f.identifier, f.name, f.version, f.type, f.creator, f.location, f.active, f.disabled, f.source_url FROM users u CROSS JOIN firefox_addons f USING (uid);",
+					Description: "Firefox add-ons of every local user",
 				},
 			},
 			"windows": {
@@ -42,18 +58,32 @@ func GetQueriesConfig() *QueriesConfig {
 				"disk_encryption_info": {
 					Query:       "SELECT CASE WHEN COUNT(*) > 0 THEN 'true' ELSE 'false' END AS disk_encryption FROM bitlocker_info WHERE protection_status = 1 OR percentage_encrypted > 0;",
 					Description: "Disk encryption information",
+					Compliance:  true,
 				},
 				"antivirus_info": {
 					Query:       "SELECT CASE WHEN antivirus = 'Good' THEN 'true' ELSE 'false' END AS antivirus_info FROM windows_security_center;",
 					Description: "Antivirus information for Windows",
+					Compliance:  true,
 				},
 				"password_manager_info": {
 					Query:       "SELECT CASE WHEN COUNT(*) > 0 THEN 'true' ELSE 'false' END AS password_manager FROM programs WHERE name IN ('KeePassXC','KeePass','Keepass','1Password','LastPass','1Password X','Password Wolf','Dashlane','Nordpass','1Password7','Bitwarden','Bitwarden Legacy','TeamPassword');",
 					Description: "Password manager information for Windows",
+					Compliance:  true,
 				},
 				"apps_info": {
 					Query:       "SELECT name, version, language, publisher, install_date, identifying_number, package_family_name, upgrade_code FROM programs;",
 					Description: "List all programs",
+					Heavy:       true,
+					LocalOnly:   true,
+				},
+				"chrome_extensions_info": {
+					Query:             "SELECT u.username, e.browser_type, e.profile, e.identifier, e.name, e.version, e.permissions, e.optional_permissions, e.from_webstore, e.state FROM users u CROSS JOIN chrome_extensions e USING (uid);",
+					Description:       "Chromium-based browser extensions of every local user",
+					MinOSQueryVersion: "5.0.0",
+				},
+				"firefox_addons_info": {
+					Query:       "SELECT u.username, f.identifier, f.name, f.version, f.type, f.creator, f.location, f.active, f.disabled, f.source_url FROM users u CROSS JOIN firefox_addons f USING (uid);",
+					Description: "Firefox add-ons of every local user",
 				},
 			},
 			"linux": {
@@ -64,6 +94,28 @@ func GetQueriesConfig() *QueriesConfig {
 				"disk_encryption_info": {
 					Query:       "SELECT CASE WHEN COUNT(*) > 0 THEN 'true' ELSE 'false' END AS disk_encryption FROM disk_encryption WHERE uid != '' AND encrypted = '1';",
 					Description: "Disk encryption information",
+					Compliance:  true,
+				},
+				"deb_packages_info": {
+					Query:       "SELECT name, version, source, arch, revision, maintainer FROM deb_packages;",
+					Description: "Installed Debian packages",
+					Heavy:       true,
+					LocalOnly:   true,
+				},
+				"rpm_packages_info": {
+					Query:       "SELECT name, version, release, epoch, arch, source, vendor, install_time FROM rpm_packages;",
+					Description: "Installed RPM packages",
+					Heavy:       true,
+					LocalOnly:   true,
+				},
+				"chrome_extensions_info": {
+					Query:             "SELECT u.username, e.browser_type, e.profile, e.identifier, e.name, e.version, e.permissions, e.optional_permissions, e.from_webstore, e.state FROM users u CROSS JOIN chrome_extensions e USING (uid);",
+					Description:       "Chromium-based browser extensions of every local user",
+					MinOSQueryVersion: "5.0.0",
+				},
+				"firefox_addons_info": {
+					Query:       "SELECT u.username, f.identifier, f.name, f.version, f.type, f.creator, f.location, f.active, f.disabled, f.source_url FROM users u CROSS JOIN firefox_addons f USING (uid);",
+					Description: "Firefox add-ons of every local user",
 				},
 			},
 		},
@@ -0,0 +1,567 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Interval bounds accepted by validation
+const (
+	MinInterval = time.Minute
+	MaxInterval = 7 * 24 * time.Hour
+)
+
+// Issue severities; only errors make a configuration unusable
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue is one configuration problem, located by its JSON field path (e.g. "sinks[1].url")
+type Issue struct {
+	Path     string
+	Message  string
+	Severity string
+	// Source is the file, environment variable or flag that supplied the value, when known
+	Source string
+}
+
+func (i Issue) String() string {
+	if i.Path == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Path, i.Message)
+}
+
+// Describe returns the issue prefixed with its source
+func (i Issue) Describe() string {
+	if i.Source == "" {
+		return i.String()
+	}
+	return i.Source + ": " + i.String()
+}
+
+// ValidationError reports every error-level issue found in a configuration file
+type ValidationError struct {
+	File   string
+	Issues []Issue
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s has %d problem(s):", e.File, len(e.Issues))
+	for _, issue := range e.Issues {
+		b.WriteString("\n  - ")
+		b.WriteString(issue.Describe())
+	}
+	return b.String()
+}
+
+// HasErrors reports whether any issue is an error
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// validLogLevels are the levels accepted in log_level
+var validLogLevels = []string{"debug", "info", "warning", "error"}
+
+// ParseAgentConfig decodes agent.conf, reporting syntax errors, unknown fields
+// and type mismatches instead of silently ignoring them
+func ParseAgentConfig(data []byte) (*AgentConfig, []Issue) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, []Issue{{Message: describeJSONError(data, err), Severity: SeverityError}}
+	}
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, []Issue{{Message: "configuration must be a JSON object", Severity: SeverityError}}
+	}
+
+	issues := unknownFields(raw, reflect.TypeOf(AgentConfig{}), "")
+
+	// Decode one top-level field at a time: json stops reporting after the first
+	// type mismatch, and every wrong field should be reported at once
+	var values map[string]json.RawMessage
+	json.Unmarshal(data, &values)
+
+	var config AgentConfig
+	for _, key := range sortedKeys(fields) {
+		field, _ := json.Marshal(map[string]json.RawMessage{key: values[key]})
+		if err := json.Unmarshal(field, &config); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				issues = append(issues, Issue{
+					Path:     typeErr.Field,
+					Message:  fmt.Sprintf("expected %s, got %s", jsonTypeName(typeErr.Type), typeErr.Value),
+					Severity: SeverityError,
+				})
+			} else {
+				issues = append(issues, Issue{Path: key, Message: err.Error(), Severity: SeverityError})
+			}
+		}
+	}
+
+	return &config, issues
+}
+
+// Validate checks the values of every field and returns all problems found
+func (c *AgentConfig) Validate() []Issue {
+	var v validator
+
+	if c.UserEmail != "" {
+		if !ValidEmail(c.UserEmail) {
+			v.errorf("user_email", "'%s' is not a valid email address", c.UserEmail)
+		}
+	}
+
+	if c.BackendURL != "" {
+		v.checkURL("backend_url", c.BackendURL)
+	}
+
+	if c.EnrollmentDomain != "" && !ValidEmailDomain(c.EnrollmentDomain) {
+		v.errorf("enrollment_domain", "'%s' is not a valid domain (e.g. company.com)", c.EnrollmentDomain)
+	}
+
+	if c.VulnerabilityDBURL != "" {
+		v.checkURL("vulnerability_db_url", c.VulnerabilityDBURL)
+		if c.VulnerabilityDBKey == "" {
+			v.errorf("vulnerability_db_key", "is required with vulnerability_db_url so downloads can be verified")
+		}
+	}
+	if c.VulnerabilityDBKey != "" {
+		if _, err := parseEd25519Key(c.VulnerabilityDBKey); err != nil {
+			v.errorf("vulnerability_db_key", "'%s' is %v", c.VulnerabilityDBKey, err)
+		}
+	}
+
+	v.checkExtensionIDs("extension_denylist", c.ExtensionDenylist)
+	v.checkExtensionIDs("extension_allowlist", c.ExtensionAllowlist)
+	allowed := make(map[string]bool)
+	for _, id := range c.ExtensionAllowlist {
+		allowed[strings.ToLower(strings.TrimSpace(id))] = true
+	}
+	for i, id := range c.ExtensionDenylist {
+		if allowed[strings.ToLower(strings.TrimSpace(id))] {
+			v.warnf(fmt.Sprintf("extension_denylist[%d]", i), "'%s' is also allowlisted; the denylist wins", id)
+		}
+	}
+
+	for i, fingerprint := range c.TrustedReportKeys {
+		if !keyFingerprintPattern.MatchString(strings.ToLower(strings.TrimSpace(fingerprint))) {
+			v.errorf(fmt.Sprintf("trusted_report_keys[%d]", i), "'%s' is not a key fingerprint (64 hex characters)", fingerprint)
+		}
+	}
+
+	if c.Interval != "" {
+		if interval, ok := v.checkDuration("interval", c.Interval, false); ok && (interval < MinInterval || interval > MaxInterval) {
+			v.errorf("interval", "%v is outside the allowed range %v to %v", interval, MinInterval, MaxInterval)
+		}
+	}
+
+	if c.LogLevel != "" && !contains(validLogLevels, c.LogLevel) {
+		v.errorf("log_level", "'%s' is not one of %s", c.LogLevel, strings.Join(validLogLevels, ", "))
+	}
+
+	v.checkDuration("splay", c.Splay, true)
+	v.checkDuration("collection_timeout", c.CollectionTimeout, false)
+	v.checkDuration("query_cpu_limit", c.QueryCPULimit, true)
+	v.checkDuration("state_key_rotation", c.StateKeyRotation, true)
+
+	if c.MaxConcurrentQueries < 0 || c.MaxConcurrentQueries > 64 {
+		v.errorf("max_concurrent_queries", "%d is outside the allowed range 0 to 64", c.MaxConcurrentQueries)
+	}
+	if c.QueryMemoryLimitMB != nil && *c.QueryMemoryLimitMB < 0 {
+		v.errorf("query_memory_limit_mb", "must not be negative")
+	}
+
+	for i, window := range c.QuietHours {
+		path := fmt.Sprintf("quiet_hours[%d]", i)
+		v.checkClock(path+".start", window.Start)
+		v.checkClock(path+".end", window.End)
+		for j, day := range window.Days {
+			if !validDay(day) {
+				v.errorf(fmt.Sprintf("%s.days[%d]", path, j), "unknown day '%s'", day)
+			}
+		}
+	}
+
+	knownQueries := allQueryNames()
+	for _, name := range sortedKeys(c.Redaction) {
+		path := "redaction." + name
+		if !knownQueries[name] {
+			v.warnf(path, "no built-in query named '%s'", name)
+		}
+		for column, length := range c.Redaction[name].Truncate {
+			if length <= 0 {
+				v.errorf(path+".truncate."+column, "length must be positive")
+			}
+		}
+	}
+
+	names := make(map[string]int)
+	for i, sink := range c.Sinks {
+		path := fmt.Sprintf("sinks[%d]", i)
+		v.checkSink(path, sink)
+		if previous, dup := names[sink.GetName()]; dup {
+			v.errorf(path+".name", "duplicates sinks[%d]; give each sink a unique name", previous)
+		}
+		names[sink.GetName()] = i
+	}
+
+	return v.issues
+}
+
+// ValidEmail reports whether email is a bare address with a dotted domain
+func ValidEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email && strings.Contains(email[strings.LastIndex(email, "@")+1:], ".")
+}
+
+// ValidEmailDomain reports whether domain can follow the @ of an email address
+func ValidEmailDomain(domain string) bool {
+	return !strings.ContainsAny(domain, "@ ") && ValidEmail("user@"+domain)
+}
+
+// hasSecrets reports whether the config holds credentials that must not be world-readable
+func (c *AgentConfig) hasSecrets() bool {
+	if c.RedactionSalt != "" {
+		return true
+	}
+	for _, sink := range c.Sinks {
+		if sink.Token != "" || sink.Secret != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// validator accumulates issues
+type validator struct {
+	issues []Issue
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.issues = append(v.issues, Issue{Path: path, Message: fmt.Sprintf(format, args...), Severity: SeverityError})
+}
+
+func (v *validator) warnf(path, format string, args ...interface{}) {
+	v.issues = append(v.issues, Issue{Path: path, Message: fmt.Sprintf(format, args...), Severity: SeverityWarning})
+}
+
+// checkDuration validates an optional duration; allowZero permits "0s"
+func (v *validator) checkDuration(path, value string, allowZero bool) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		v.errorf(path, "'%s' is not a valid duration (e.g. 30s, 10m, 1h)", value)
+		return 0, false
+	}
+	if duration < 0 || (duration == 0 && !allowZero) {
+		v.errorf(path, "must be positive")
+		return 0, false
+	}
+	return duration, true
+}
+
+// checkURL requires an absolute http or https URL
+func (v *validator) checkURL(path, value string) {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		v.errorf(path, "'%s' is not a valid URL", value)
+		return
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		v.errorf(path, "URL scheme must be http or https, got '%s'", parsed.Scheme)
+		return
+	}
+	if parsed.Host == "" {
+		v.errorf(path, "URL has no host")
+	}
+}
+
+// checkClock requires HH:MM
+func (v *validator) checkClock(path, value string) {
+	if _, err := time.Parse("15:04", strings.TrimSpace(value)); err != nil {
+		v.errorf(path, "invalid time '%s', expected HH:MM", value)
+	}
+}
+
+// keyFingerprintPattern matches the hex SHA-256 fingerprint of a signing key
+var keyFingerprintPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// extensionIDPattern matches Chromium extension IDs and the GUID or email-style IDs of Firefox add-ons
+var extensionIDPattern = regexp.MustCompile(`^([a-p]{32}|\{[0-9A-Fa-f-]{36}\}|[A-Za-z0-9._+-]*@[A-Za-z0-9._-]+)$`)
+
+// checkExtensionIDs flags empty entries and warns about IDs no browser would use
+func (v *validator) checkExtensionIDs(path string, ids []string) {
+	for i, id := range ids {
+		entry := fmt.Sprintf("%s[%d]", path, i)
+		id = strings.TrimSpace(id)
+		if id == "" {
+			v.errorf(entry, "extension ID is empty")
+		} else if !extensionIDPattern.MatchString(strings.ToLower(id)) {
+			v.warnf(entry, "'%s' does not look like a Chrome or Firefox extension ID", id)
+		}
+	}
+}
+
+// checkSink validates the fields required by each sink type
+func (v *validator) checkSink(path string, sink SinkConfig) {
+	require := func(field, value string) bool {
+		if value == "" {
+			v.errorf(path+"."+field, "required for %s sinks", sink.Type)
+			return false
+		}
+		return true
+	}
+
+	switch sink.Type {
+	case SinkTypeBackend:
+		if sink.URL != "" {
+			v.checkURL(path+".url", sink.URL)
+		}
+	case SinkTypeFile:
+		require("path", sink.Path)
+	case SinkTypeSyslog:
+		if sink.Network != "" && sink.Network != "tcp" && sink.Network != "udp" {
+			v.errorf(path+".network", "must be tcp or udp")
+		}
+		require("address", sink.Address)
+	case SinkTypeSplunkHEC:
+		if require("url", sink.URL) {
+			v.checkURL(path+".url", sink.URL)
+		}
+		require("token", sink.Token)
+	case SinkTypeElasticsearch:
+		if require("url", sink.URL) {
+			v.checkURL(path+".url", sink.URL)
+		}
+	case SinkTypeWebhook:
+		if require("url", sink.URL) {
+			v.checkURL(path+".url", sink.URL)
+		}
+		require("secret", sink.Secret)
+		if sink.Mode != "" && sink.Mode != WebhookModeReport && sink.Mode != WebhookModeChanges {
+			v.errorf(path+".mode", "must be '%s' or '%s'", WebhookModeReport, WebhookModeChanges)
+		}
+	case "":
+		v.errorf(path+".type", "required")
+		return
+	default:
+		v.errorf(path+".type", "unknown sink type '%s'", sink.Type)
+		return
+	}
+
+	v.checkDuration(path+".timeout", sink.Timeout, false)
+	v.checkDuration(path+".retry_backoff", sink.RetryBackoff, true)
+	if sink.Retries != nil && *sink.Retries < 0 {
+		v.errorf(path+".retries", "must not be negative")
+	}
+	if sink.MaxSizeMB < 0 {
+		v.errorf(path+".max_size_mb", "must not be negative")
+	}
+	if sink.MaxFiles < 0 {
+		v.errorf(path+".max_files", "must not be negative")
+	}
+}
+
+// unknownFields walks decoded JSON alongside the Go type and reports keys that no field accepts
+func unknownFields(value interface{}, typ reflect.Type, path string) []Issue {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	var issues []Issue
+	switch typ.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := jsonFields(typ)
+		for _, key := range sortedKeys(object) {
+			fieldPath := joinPath(path, key)
+			field, known := fields[key]
+			if !known {
+				message := "unknown field"
+				if suggestion := closestName(key, fields); suggestion != "" {
+					message += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+				}
+				issues = append(issues, Issue{Path: fieldPath, Message: message, Severity: SeverityError})
+				continue
+			}
+			issues = append(issues, unknownFields(object[key], field.Type, fieldPath)...)
+		}
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, item := range items {
+			issues = append(issues, unknownFields(item, typ.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for _, key := range sortedKeys(object) {
+			issues = append(issues, unknownFields(object[key], typ.Elem(), joinPath(path, key))...)
+		}
+	}
+	return issues
+}
+
+// jsonFields maps the JSON names of a struct's fields to the fields
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// closestName suggests a known field within edit distance 2 of a misspelt key
+func closestName(key string, fields map[string]reflect.StructField) string {
+	best, bestDistance := "", 3
+	for name := range fields {
+		if distance := editDistance(strings.ToLower(key), name); distance < bestDistance {
+			best, bestDistance = name, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// describeJSONError adds a line and column to JSON syntax errors
+func describeJSONError(data []byte, err error) string {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return fmt.Sprintf("invalid JSON: %v", err)
+	}
+	before := data[:min(int(syntaxErr.Offset), len(data))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("invalid JSON at line %d, column %d: %v", line, column, err)
+}
+
+// jsonTypeName describes a Go type in JSON terms
+func jsonTypeName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Float64:
+		return "a number"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	default:
+		return typ.String()
+	}
+}
+
+// allQueryNames returns the names of built-in queries on every platform
+func allQueryNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, queries := range GetQueriesConfig().Platform {
+		for name := range queries {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// validDay accepts day names or their three-letter abbreviations
+func validDay(day string) bool {
+	day = strings.ToLower(day)
+	if len(day) < 3 {
+		return false
+	}
+	for _, name := range []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"} {
+		if strings.HasPrefix(name, day) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// configFilePath returns the agent.conf path inside a config directory
+func configFilePath(configDir string) string {
+	return filepath.Join(configDir, "agent.conf")
+}
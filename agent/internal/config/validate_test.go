@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// issuePaths returns the sorted paths of the error-level issues
+func issuePaths(issues []Issue) []string {
+	var paths []string
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			paths = append(paths, issue.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config AgentConfig
+		errors []string
+	}{
+		{name: "empty", config: AgentConfig{}},
+		{
+			name: "valid",
+			config: AgentConfig{
+				UserEmail:            "alice@example.com",
+				BackendURL:           "https://mdm.example.com",
+				Interval:             "1h",
+				LogLevel:             "debug",
+				Splay:                "0",
+				MaxConcurrentQueries: 8,
+				QuietHours:           []QuietWindow{{Start: "22:00", End: "06:00", Days: []string{"Mon", "saturday"}}},
+			},
+		},
+		{name: "bad email", config: AgentConfig{UserEmail: "alice"}, errors: []string{"user_email"}},
+		{name: "email with display name", config: AgentConfig{UserEmail: "Alice <alice@example.com>"}, errors: []string{"user_email"}},
+		{name: "ftp backend", config: AgentConfig{BackendURL: "ftp://mdm.example.com"}, errors: []string{"backend_url"}},
+		{name: "backend without host", config: AgentConfig{BackendURL: "https://"}, errors: []string{"backend_url"}},
+		{name: "interval too short", config: AgentConfig{Interval: "10s"}, errors: []string{"interval"}},
+		{name: "interval not a duration", config: AgentConfig{Interval: "hourly"}, errors: []string{"interval"}},
+		{name: "unknown log level", config: AgentConfig{LogLevel: "verbose"}, errors: []string{"log_level"}},
+		{name: "zero collection timeout", config: AgentConfig{CollectionTimeout: "0s"}, errors: []string{"collection_timeout"}},
+		{name: "too many workers", config: AgentConfig{MaxConcurrentQueries: 65}, errors: []string{"max_concurrent_queries"}},
+		{name: "negative memory limit", config: AgentConfig{QueryMemoryLimitMB: intPtr(-1)}, errors: []string{"query_memory_limit_mb"}},
+		{
+			name:   "quiet hours",
+			config: AgentConfig{QuietHours: []QuietWindow{{Start: "25:00", End: "6pm", Days: []string{"Funday"}}}},
+			errors: []string{"quiet_hours[0].days[0]", "quiet_hours[0].end", "quiet_hours[0].start"},
+		},
+		{
+			name:   "vulnerability db url without key",
+			config: AgentConfig{VulnerabilityDBURL: "https://mdm.example.com/api/devices/agent/vulndb"},
+			errors: []string{"vulnerability_db_key"},
+		},
+		{
+			name: "vulnerability db key",
+			config: AgentConfig{
+				VulnerabilityDBURL: "https://mdm.example.com/api/devices/agent/vulndb",
+				VulnerabilityDBKey: "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=",
+			},
+		},
+		{name: "short vulnerability db key", config: AgentConfig{VulnerabilityDBKey: "abc="}, errors: []string{"vulnerability_db_key"}},
+		{
+			name:   "trusted key",
+			config: AgentConfig{TrustedReportKeys: []string{"abc"}},
+			errors: []string{"trusted_report_keys[0]"},
+		},
+		{
+			name: "sinks",
+			config: AgentConfig{Sinks: []SinkConfig{
+				{Type: SinkTypeFile, Path: "/var/log/scanx.json"},
+				{Type: "carrier-pigeon"},
+				{Type: SinkTypeFile, Path: "/tmp/other.json"},
+			}},
+			errors: []string{"sinks[1].type", "sinks[2].name"},
+		},
+		{
+			name:   "every problem reported",
+			config: AgentConfig{UserEmail: "bad", Interval: "10s", LogLevel: "verbose", BackendURL: "ftp://x"},
+			errors: []string{"backend_url", "interval", "log_level", "user_email"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := issuePaths(tt.config.Validate())
+			want := append([]string(nil), tt.errors...)
+			sort.Strings(want)
+			if !equalPaths(got, want) {
+				t.Errorf("errors at %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseAgentConfigReportsEveryTypeMismatch(t *testing.T) {
+	data := []byte(`{"intervall":"1h","max_concurrent_queries":"4","splay":5,"log_level":"info"}`)
+	config, issues := ParseAgentConfig(data)
+	if config == nil {
+		t.Fatal("no config returned")
+	}
+	want := []string{"intervall", "max_concurrent_queries", "splay"}
+	if got := issuePaths(issues); !equalPaths(got, want) {
+		t.Errorf("errors at %v, want %v", got, want)
+	}
+	if config.LogLevel != "info" {
+		t.Errorf("log_level = %q; well-typed fields must still be decoded", config.LogLevel)
+	}
+}
+
+func TestParseAgentConfigSyntaxError(t *testing.T) {
+	for _, data := range []string{`{"interval": }`, `[]`, `"x"`} {
+		if config, issues := ParseAgentConfig([]byte(data)); config != nil || !HasErrors(issues) {
+			t.Errorf("%s: config %v, issues %v; want an error and no config", data, config, issues)
+		}
+	}
+}
+
+func TestResolveValidatesFileWithUnknownFields(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"intervall":"1h","user_email":"bad","interval":"10s","log_level":"verbose","backend_url":"ftp://x"}`
+	if err := os.WriteFile(filepath.Join(dir, "agent.conf"), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, issues, err := Resolve(LoadOptions{Dir: dir, Environ: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"backend_url", "interval", "intervall", "log_level", "user_email"}
+	if got := issuePaths(issues); !equalPaths(got, want) {
+		t.Errorf("errors at %v, want %v", got, want)
+	}
+	for _, issue := range issues {
+		if issue.Source != filepath.Join(dir, "agent.conf") {
+			t.Errorf("issue %s attributed to %q", issue, issue.Source)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package extensions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Queries inventorying browser extensions across all local users
+const (
+	ChromeQuery  = "chrome_extensions_info"
+	FirefoxQuery = "firefox_addons_info"
+)
+
+// Reasons an installed extension is reported
+const (
+	ReasonDenylisted     = "denylisted"
+	ReasonNotAllowlisted = "not_allowlisted"
+)
+
+// builtinFirefoxLocations are add-on locations populated by Firefox itself
+var builtinFirefoxLocations = map[string]bool{
+	"app-builtin":         true,
+	"app-system-defaults": true,
+	"app-system-addons":   true,
+}
+
+// Policy lists the extension IDs an organization bans or approves. IDs compare
+// case-insensitively.
+type Policy struct {
+	Deny  []string
+	Allow []string
+}
+
+// IsEmpty reports whether the policy would never produce findings
+func (p Policy) IsEmpty() bool {
+	return len(p.Deny) == 0 && len(p.Allow) == 0
+}
+
+// Finding is an installed extension that violates the policy
+type Finding struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Browser is the Chromium browser type ("chrome", "edge", "brave", ...) or "firefox"
+	Browser  string `json:"browser"`
+	Username string `json:"username,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+	// Permissions are the required and optional permissions the extension requests
+	Permissions string `json:"permissions,omitempty"`
+	Enabled     bool   `json:"enabled"`
+	Reason      string `json:"reason"`
+}
+
+// Evaluate checks the extensions inventoried in data against the policy. The
+// denylist always applies; the allowlist only covers user-installed extensions,
+// not Firefox's built-in add-ons, themes or language packs.
+func Evaluate(data map[string][]map[string]interface{}, policy Policy) []Finding {
+	if policy.IsEmpty() {
+		return nil
+	}
+	deny := idSet(policy.Deny)
+	allow := idSet(policy.Allow)
+
+	var findings []Finding
+	check := func(finding Finding, allowlistApplies bool) {
+		id := strings.ToLower(finding.ID)
+		switch {
+		case id == "":
+			return
+		case deny[id]:
+			finding.Reason = ReasonDenylisted
+		case len(allow) > 0 && allowlistApplies && !allow[id]:
+			finding.Reason = ReasonNotAllowlisted
+		default:
+			return
+		}
+		findings = append(findings, finding)
+	}
+
+	for _, row := range data[ChromeQuery] {
+		check(chromeExtension(row), true)
+	}
+	for _, row := range data[FirefoxQuery] {
+		addonType := column(row, "type")
+		userInstalled := (addonType == "" || addonType == "extension") && !builtinFirefoxLocations[column(row, "location")]
+		check(firefoxAddon(row), userInstalled)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Reason != b.Reason {
+			return a.Reason == ReasonDenylisted
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		if a.Username != b.Username {
+			return a.Username < b.Username
+		}
+		return a.Profile < b.Profile
+	})
+	return findings
+}
+
+// chromeExtension maps a chrome_extensions_info row
+func chromeExtension(row map[string]interface{}) Finding {
+	browser := column(row, "browser_type")
+	if browser == "" {
+		browser = "chrome"
+	}
+	permissions := column(row, "permissions")
+	if optional := column(row, "optional_permissions"); optional != "" {
+		if permissions != "" {
+			permissions += ", "
+		}
+		permissions += optional
+	}
+	state := column(row, "state")
+	return Finding{
+		ID:          column(row, "identifier"),
+		Name:        column(row, "name"),
+		Version:     column(row, "version"),
+		Browser:     browser,
+		Username:    column(row, "username"),
+		Profile:     column(row, "profile"),
+		Permissions: permissions,
+		Enabled:     state == "" || state == "1",
+	}
+}
+
+// firefoxAddon maps a firefox_addons_info row; osquery does not expose add-on permissions
+func firefoxAddon(row map[string]interface{}) Finding {
+	return Finding{
+		ID:       column(row, "identifier"),
+		Name:     column(row, "name"),
+		Version:  column(row, "version"),
+		Browser:  "firefox",
+		Username: column(row, "username"),
+		Enabled:  column(row, "active") == "1" && column(row, "disabled") != "1",
+	}
+}
+
+// idSet lowercases IDs for case-insensitive lookup
+func idSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if id = strings.ToLower(strings.TrimSpace(id)); id != "" {
+			set[id] = true
+		}
+	}
+	return set
+}
+
+// column returns a row value as a trimmed string
+func column(row map[string]interface{}, name string) string {
+	value, ok := row[name]
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
@@ -0,0 +1,286 @@
+package identity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"scanx/internal/securestore"
+	"scanx/internal/utils"
+)
+
+// identityFileName is the file under the state directory holding the persisted identity
+const identityFileName = "device_id.json"
+
+// Identifiers holds the raw hardware/OS identifiers used to derive the device ID
+type Identifiers struct {
+	DMISerial  string `json:"dmi_serial,omitempty"`
+	SystemUUID string `json:"system_uuid,omitempty"`
+	MachineID  string `json:"machine_id,omitempty"`
+	PrimaryMAC string `json:"primary_mac,omitempty"`
+}
+
+// DeviceIdentity is the stable device identity persisted across runs
+type DeviceIdentity struct {
+	DeviceID         string      `json:"device_id"`
+	Identifiers      Identifiers `json:"identifiers"`
+	CreatedAt        string      `json:"created_at"`
+	HardwareChanged  bool        `json:"hardware_changed"`
+	PreviousDeviceID string      `json:"previous_device_id,omitempty"`
+}
+
+// placeholderValues are vendor defaults that must never be treated as unique identifiers
+var placeholderValues = map[string]bool{
+	"":                                     true,
+	"0":                                    true,
+	"none":                                 true,
+	"unknown":                              true,
+	"default string":                       true,
+	"not specified":                        true,
+	"not applicable":                       true,
+	"to be filled by o.e.m.":               true,
+	"system serial number":                 true,
+	"0123456789":                           true,
+	"00000000-0000-0000-0000-000000000000": true,
+	"ffffffff-ffff-ffff-ffff-ffffffffffff": true,
+	"03000200-0400-0500-0006-000700080009": true,
+}
+
+// normalize trims an identifier and discards known placeholder values
+func normalize(value string) string {
+	value = strings.TrimSpace(value)
+	if placeholderValues[strings.ToLower(value)] {
+		return ""
+	}
+	return value
+}
+
+// CollectIdentifiers gathers identifiers from the system, preferring the values
+// already reported by osquery's system_info table when available
+func CollectIdentifiers(hardwareSerial, systemUUID string) Identifiers {
+	ids := Identifiers{
+		DMISerial:  normalize(hardwareSerial),
+		SystemUUID: strings.ToLower(normalize(systemUUID)),
+		MachineID:  readMachineID(),
+		PrimaryMAC: primaryMAC(),
+	}
+
+	// Fall back to sysfs DMI data on Linux if osquery returned nothing usable
+	if ids.DMISerial == "" {
+		ids.DMISerial = normalize(readFirstLine("/sys/class/dmi/id/product_serial"))
+	}
+	if ids.SystemUUID == "" {
+		ids.SystemUUID = strings.ToLower(normalize(readFirstLine("/sys/class/dmi/id/product_uuid")))
+	}
+
+	return ids
+}
+
+// readMachineID reads the systemd/dbus machine ID where present
+func readMachineID() string {
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if id := normalize(readFirstLine(path)); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// readFirstLine returns the trimmed first line of a file, or empty on any error
+func readFirstLine(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSpace(line)
+}
+
+// primaryMAC returns the MAC of the first physical, non-loopback interface sorted by name
+func primaryMAC() string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	sort.Slice(ifaces, func(i, j int) bool { return ifaces[i].Name < ifaces[j].Name })
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) != 6 {
+			continue
+		}
+		// Skip locally administered addresses (docker, VPN, randomized Wi-Fi MACs)
+		if iface.HardwareAddr[0]&0x02 != 0 {
+			continue
+		}
+		return iface.HardwareAddr.String()
+	}
+	return ""
+}
+
+// sources returns the non-empty identifiers in a fixed order
+func (ids Identifiers) sources() []string {
+	var out []string
+	for _, kv := range [][2]string{
+		{"dmi_serial", ids.DMISerial},
+		{"system_uuid", ids.SystemUUID},
+		{"machine_id", ids.MachineID},
+		{"primary_mac", ids.PrimaryMAC},
+	} {
+		if kv[1] != "" {
+			out = append(out, kv[0]+"="+kv[1])
+		}
+	}
+	return out
+}
+
+// matchCount returns how many identifiers known on both sides are equal, and how many were comparable
+func (ids Identifiers) matchCount(other Identifiers) (matched, compared int) {
+	pairs := [][2]string{
+		{ids.DMISerial, other.DMISerial},
+		{ids.SystemUUID, other.SystemUUID},
+		{ids.MachineID, other.MachineID},
+		{ids.PrimaryMAC, other.PrimaryMAC},
+	}
+	for _, p := range pairs {
+		if p[0] == "" || p[1] == "" {
+			continue
+		}
+		compared++
+		if strings.EqualFold(p[0], p[1]) {
+			matched++
+		}
+	}
+	return matched, compared
+}
+
+// DeriveID computes a deterministic device ID from the available identifiers
+func DeriveID(ids Identifiers) (string, error) {
+	sources := ids.sources()
+	if len(sources) == 0 {
+		return "", fmt.Errorf("no usable hardware identifiers found")
+	}
+
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.Join(sources, "|"))))
+	return "scanx-" + hex.EncodeToString(sum[:16]), nil
+}
+
+// LoadOrCreate returns the persisted device identity, creating it on first run.
+// A hardware swap is detected when fewer than half of the comparable identifiers
+// still match; a new ID is then issued and the previous one is kept for reference.
+// HardwareChanged stays set until ClearHardwareChanged confirms it was reported.
+func LoadOrCreate(stateDir string, current Identifiers) (*DeviceIdentity, error) {
+	store, err := securestore.Open(stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state store: %w", err)
+	}
+	path := filepath.Join(stateDir, identityFileName)
+
+	stored, err := load(store, path)
+	if err != nil && !os.IsNotExist(err) {
+		utils.Warning("Ignoring unreadable device identity file %s: %v", path, err)
+	}
+
+	if stored != nil {
+		matched, compared := stored.Identifiers.matchCount(current)
+		if compared > 0 && matched*2 >= compared {
+			// Same machine - keep the ID but refresh identifiers that changed (e.g. NIC replaced)
+			if stored.Identifiers != current {
+				stored.Identifiers = current
+				if err := save(store, path, stored); err != nil {
+					utils.Warning("Failed to update device identity file: %v", err)
+				}
+			}
+			return stored, nil
+		}
+		utils.Warning("Hardware change detected: %d of %d identifiers match stored identity %s", matched, compared, stored.DeviceID)
+	}
+
+	deviceID, err := DeriveID(current)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &DeviceIdentity{
+		DeviceID:    deviceID,
+		Identifiers: current,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if stored != nil {
+		identity.HardwareChanged = stored.DeviceID != deviceID
+		identity.PreviousDeviceID = stored.DeviceID
+	}
+
+	if err := save(store, path, identity); err != nil {
+		return nil, fmt.Errorf("failed to persist device identity: %w", err)
+	}
+
+	return identity, nil
+}
+
+// ClearHardwareChanged records that a hardware change has been reported, so later
+// runs stop flagging it
+func ClearHardwareChanged(stateDir string) error {
+	store, err := securestore.Open(stateDir)
+	if err != nil {
+		return fmt.Errorf("failed to open state store: %w", err)
+	}
+	path := filepath.Join(stateDir, identityFileName)
+
+	stored, err := load(store, path)
+	if err != nil {
+		return err
+	}
+	if !stored.HardwareChanged {
+		return nil
+	}
+	stored.HardwareChanged = false
+	return save(store, path, stored)
+}
+
+// DegradedID returns a device ID for when the persisted identity is unavailable:
+// derived from the identifiers when there are any, otherwise from the host name
+func DegradedID(ids Identifiers) string {
+	if deviceID, err := DeriveID(ids); err == nil {
+		return deviceID
+	}
+	hostname, _ := os.Hostname()
+	sum := sha256.Sum256([]byte("hostname=" + strings.ToLower(hostname)))
+	return "scanx-" + hex.EncodeToString(sum[:16])
+}
+
+// load reads a persisted identity file
+func load(store *securestore.Store, path string) (*DeviceIdentity, error) {
+	data, err := store.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var identity DeviceIdentity
+	if err := json.Unmarshal(data, &identity); err != nil {
+		return nil, fmt.Errorf("failed to parse device identity: %w", err)
+	}
+	if identity.DeviceID == "" {
+		return nil, fmt.Errorf("device identity file has no device_id")
+	}
+	return &identity, nil
+}
+
+// save writes the identity file encrypted and atomically
+func save(store *securestore.Store, path string, identity *DeviceIdentity) error {
+	data, err := json.MarshalIndent(identity, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal device identity: %w", err)
+	}
+
+	if err := store.WriteFile(path, data); err != nil {
+		return fmt.Errorf("failed to write device identity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package identity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHardwareChangeFlaggedUntilCleared(t *testing.T) {
+	dir := t.TempDir()
+	before := Identifiers{DMISerial: "SERIAL-1", SystemUUID: "uuid-1", MachineID: "machine-1"}
+	after := Identifiers{DMISerial: "SERIAL-2", SystemUUID: "uuid-2", MachineID: "machine-2"}
+
+	original, err := LoadOrCreate(dir, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if original.HardwareChanged {
+		t.Fatal("first identity flagged as a hardware change")
+	}
+
+	changed, err := LoadOrCreate(dir, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed.HardwareChanged || changed.PreviousDeviceID != original.DeviceID {
+		t.Fatalf("swap not detected: %+v", changed)
+	}
+
+	// A restart before the change was reported must still report it
+	again, err := LoadOrCreate(dir, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.HardwareChanged || again.DeviceID != changed.DeviceID {
+		t.Fatalf("unreported hardware change lost on reload: %+v", again)
+	}
+
+	if err := ClearHardwareChanged(dir); err != nil {
+		t.Fatal(err)
+	}
+	cleared, err := LoadOrCreate(dir, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleared.HardwareChanged || cleared.DeviceID != changed.DeviceID {
+		t.Fatalf("after clearing: %+v", cleared)
+	}
+}
+
+func TestDegradedID(t *testing.T) {
+	ids := Identifiers{DMISerial: "SERIAL-1"}
+	derived, err := DeriveID(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := DegradedID(ids); got != derived {
+		t.Errorf("DegradedID = %s, want the derived ID %s", got, derived)
+	}
+
+	fallback := DegradedID(Identifiers{})
+	if !strings.HasPrefix(fallback, "scanx-") || fallback != DegradedID(Identifiers{}) {
+		t.Errorf("DegradedID without identifiers = %q, want a stable scanx- ID", fallback)
+	}
+}
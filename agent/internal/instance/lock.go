@@ -0,0 +1,150 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lock file names under the agent state directory
+const (
+	// DaemonLockFile is held for the daemon's whole lifetime and records its PID
+	DaemonLockFile = "scanx.lock"
+	// CollectionLockFile is held while a collection cycle runs, by the daemon or a one-shot run
+	CollectionLockFile = "collect.lock"
+)
+
+// pollPeriod is how often a waiting caller retries a busy lock
+const pollPeriod = 500 * time.Millisecond
+
+// ErrLocked is returned when another process holds the lock
+var ErrLocked = errors.New("lock is held by another process")
+
+// Lock is an exclusive, process-wide lock backed by a file
+type Lock struct {
+	file *os.File
+	path string
+	mode string
+}
+
+// Holder describes the process currently holding a lock
+type Holder struct {
+	PID  int
+	Mode string
+	// ConfigDir is the configuration directory the holder loaded, if it recorded one
+	ConfigDir string
+}
+
+// TryAcquire takes the lock without waiting and records our PID and mode in it.
+// If another process holds it, the returned error wraps ErrLocked.
+func TryAcquire(stateDir string, name string, mode string) (*Lock, error) {
+	path := filepath.Join(stateDir, name)
+
+	file, err := lockFile(path)
+	if err != nil {
+		if errors.Is(err, ErrLocked) {
+			if holder, readErr := ReadHolder(stateDir, name); readErr == nil {
+				return nil, fmt.Errorf("%w (pid %d, mode %s)", ErrLocked, holder.PID, holder.Mode)
+			}
+		}
+		return nil, err
+	}
+
+	// Record who holds the lock so others can report it
+	lock := &Lock{file: file, path: path, mode: mode}
+	lock.writeHolder(Holder{PID: os.Getpid(), Mode: mode})
+	return lock, nil
+}
+
+// SetConfigDir records the configuration directory the holder is using, so
+// other commands can act on the same files
+func (l *Lock) SetConfigDir(dir string) error {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return l.writeHolder(Holder{PID: os.Getpid(), Mode: l.mode, ConfigDir: dir})
+}
+
+// writeHolder replaces the lock file contents with the holder record
+func (l *Lock) writeHolder(holder Holder) error {
+	if err := l.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to record lock holder: %w", err)
+	}
+	record := fmt.Sprintf("%d\n%s\n", holder.PID, holder.Mode)
+	if holder.ConfigDir != "" {
+		record += holder.ConfigDir + "\n"
+	}
+	if _, err := l.file.WriteAt([]byte(record), 0); err != nil {
+		return fmt.Errorf("failed to record lock holder: %w", err)
+	}
+	return l.file.Sync()
+}
+
+// Acquire waits until the lock is free, ctx ends or timeout elapses
+func Acquire(ctx context.Context, stateDir string, name string, mode string, timeout time.Duration) (*Lock, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		lock, err := TryAcquire(stateDir, name, mode)
+		if err == nil || !errors.Is(err, ErrLocked) {
+			return lock, err
+		}
+
+		select {
+		case <-time.After(pollPeriod):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for %s: %w", name, err)
+		}
+	}
+}
+
+// ReadHolder returns the PID, mode and configuration directory recorded in a lock file
+func ReadHolder(stateDir string, name string) (*Holder, error) {
+	data, err := os.ReadFile(filepath.Join(stateDir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return nil, fmt.Errorf("lock file has no pid")
+	}
+
+	holder := &Holder{PID: pid}
+	if len(lines) > 1 {
+		holder.Mode = strings.TrimSpace(lines[1])
+	}
+	if len(lines) > 2 {
+		holder.ConfigDir = strings.TrimSpace(lines[2])
+	}
+	return holder, nil
+}
+
+// IsHeld reports whether some process currently holds the named lock
+func IsHeld(stateDir string, name string) bool {
+	file, err := lockFile(filepath.Join(stateDir, name))
+	if err != nil {
+		return errors.Is(err, ErrLocked)
+	}
+	file.Close()
+	return false
+}
+
+// Release unlocks and closes the lock file. The file is left in place; removing it
+// would let a waiter lock an unlinked inode while a newcomer creates a fresh one.
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	l.file.Truncate(0)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
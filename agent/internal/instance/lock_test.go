@@ -0,0 +1,36 @@
+package instance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHolderRecordsConfigDir(t *testing.T) {
+	stateDir := t.TempDir()
+	lock, err := TryAcquire(stateDir, DaemonLockFile, "daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+
+	holder, err := ReadHolder(stateDir, DaemonLockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder.PID != os.Getpid() || holder.Mode != "daemon" || holder.ConfigDir != "" {
+		t.Fatalf("holder = %+v before SetConfigDir", holder)
+	}
+
+	configDir := filepath.Join(t.TempDir(), "etc")
+	if err := lock.SetConfigDir(configDir); err != nil {
+		t.Fatal(err)
+	}
+	holder, err = ReadHolder(stateDir, DaemonLockFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder.PID != os.Getpid() || holder.Mode != "daemon" || holder.ConfigDir != configDir {
+		t.Errorf("holder = %+v, want config dir %s", holder, configDir)
+	}
+}
@@ -0,0 +1,38 @@
+//go:build windows
+
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation is ERROR_SHARING_VIOLATION
+const errorSharingViolation syscall.Errno = 32
+
+// lockFile opens path without write sharing so only one process can hold it
+func lockFile(path string) (*os.File, error) {
+	pathp, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// FILE_SHARE_READ lets other processes read the recorded PID while we hold the lock
+	handle, err := syscall.CreateFile(pathp,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		syscall.FILE_SHARE_READ,
+		nil,
+		syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0)
+	if err != nil {
+		if errors.Is(err, errorSharingViolation) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	return os.NewFile(uintptr(handle), path), nil
+}
@@ -0,0 +1,80 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ReloadRequestFile is created under the state directory to ask the daemon to
+// reload its configuration; it covers platforms without SIGHUP and daemons
+// running as a user the requester cannot signal
+const ReloadRequestFile = "reload.request"
+
+// reloadPollPeriod is how often the daemon checks for a reload request file
+const reloadPollPeriod = 5 * time.Second
+
+// ErrNotRunning is returned by RequestReload when no daemon holds the daemon lock
+var ErrNotRunning = errors.New("no running daemon")
+
+// RequestReload asks the running daemon to reload its configuration
+func RequestReload(stateDir string) error {
+	if !IsHeld(stateDir, DaemonLockFile) {
+		return ErrNotRunning
+	}
+
+	requestPath := filepath.Join(stateDir, ReloadRequestFile)
+	fileErr := os.WriteFile(requestPath, []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0600)
+
+	// Signal as well so the reload happens immediately where possible
+	holder, err := ReadHolder(stateDir, DaemonLockFile)
+	if err == nil {
+		err = signalReload(holder.PID)
+	}
+	if err != nil && fileErr != nil {
+		return fmt.Errorf("failed to notify daemon: %w", errors.Join(err, fileErr))
+	}
+	return nil
+}
+
+// WatchReload returns a channel that receives a value for each reload request
+// until ctx ends
+func WatchReload(ctx context.Context, stateDir string) <-chan struct{} {
+	requests := make(chan struct{}, 1)
+	signals := make(chan os.Signal, 1)
+	stopSignals := notifyReload(signals)
+	requestPath := filepath.Join(stateDir, ReloadRequestFile)
+
+	// A request left over from before the daemon started is already reflected in its config
+	os.Remove(requestPath)
+
+	go func() {
+		defer stopSignals()
+		ticker := time.NewTicker(reloadPollPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+			case <-ticker.C:
+				if _, err := os.Stat(requestPath); err != nil {
+					continue
+				}
+			}
+
+			os.Remove(requestPath)
+			select {
+			case requests <- struct{}{}:
+			default:
+				// A reload is already pending
+			}
+		}
+	}()
+
+	return requests
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package instance
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// signalReload sends SIGHUP to the daemon
+func signalReload(pid int) error {
+	return syscall.Kill(pid, syscall.SIGHUP)
+}
+
+// notifyReload delivers SIGHUP to ch and returns a function that stops it
+func notifyReload(ch chan os.Signal) func() {
+	signal.Notify(ch, syscall.SIGHUP)
+	return func() { signal.Stop(ch) }
+}
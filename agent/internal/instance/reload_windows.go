@@ -0,0 +1,18 @@
+//go:build windows
+
+package instance
+
+import (
+	"errors"
+	"os"
+)
+
+// signalReload is unsupported on Windows; the daemon picks up the request file instead
+func signalReload(pid int) error {
+	return errors.New("reload signals are not supported on Windows")
+}
+
+// notifyReload is a no-op on Windows, which has no SIGHUP
+func notifyReload(ch chan os.Signal) func() {
+	return func() {}
+}
@@ -0,0 +1,265 @@
+package report
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"scanx/internal/collector"
+	"scanx/pkg/payloadsig"
+)
+
+// SignatureSuffix is appended to a report path to form its detached signature path
+const SignatureSuffix = ".sig"
+
+// SignatureAlgorithm is the only signature scheme written and accepted for reports
+const SignatureAlgorithm = "ed25519"
+
+// Signature is the detached signature stored next to a report file. It covers
+// the canonical JSON of the report (see payloadsig.Canonicalize), so it survives
+// compression and re-encoding and can be checked again on the body sent upstream.
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+	SignedAt  string `json:"signed_at"`
+	DeviceID  string `json:"device_id,omitempty"`
+}
+
+// WriteOptions controls how a report is written
+type WriteOptions struct {
+	Gzip       bool
+	SigningKey ed25519.PrivateKey // nil writes an unsigned report
+}
+
+// SignaturePath returns the detached signature path for a report
+func SignaturePath(reportPath string) string {
+	return reportPath + SignatureSuffix
+}
+
+// Write saves the collected data to path, optionally gzip-compressed and signed.
+// Files are written atomically so an interrupted export never leaves a partial report.
+func Write(path string, data *collector.CollectedData, opts WriteOptions) error {
+	payload, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	canonical, err := payloadsig.Canonicalize(payload)
+	if err != nil {
+		return fmt.Errorf("failed to canonicalize report: %w", err)
+	}
+
+	if opts.Gzip {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(payload); err != nil {
+			return fmt.Errorf("failed to compress report: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress report: %w", err)
+		}
+		payload = compressed.Bytes()
+	}
+
+	if err := writeFileAtomic(path, payload, 0600); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	sigPath := SignaturePath(path)
+	if opts.SigningKey == nil {
+		// Never leave a stale signature that no longer matches the report
+		if err := os.Remove(sigPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale signature: %w", err)
+		}
+		return nil
+	}
+
+	sig := sign(canonical, opts.SigningKey, data.DeviceID)
+	sigData, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal signature: %w", err)
+	}
+	if err := writeFileAtomic(sigPath, append(sigData, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	return nil
+}
+
+// Read loads a report written by Write, transparently decompressing it. When
+// trustedKeys (public key fingerprints) are given, a detached signature that
+// exists must be valid and made by one of them; a bad or untrusted signature is
+// an error. Without trusted keys the signature is neither checked nor returned.
+func Read(path string, trustedKeys []string) (*collector.CollectedData, *Signature, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	var sig *Signature
+	if len(trustedKeys) > 0 {
+		if sig, err = readSignature(SignaturePath(path)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	payload := raw
+	// Detect gzip by its magic bytes rather than the file extension
+	if len(raw) >= 2 && raw[0] == 0x1f && raw[1] == 0x8b {
+		gz, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress report: %w", err)
+		}
+		payload, err = io.ReadAll(gz)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress report: %w", err)
+		}
+	}
+
+	if sig != nil {
+		canonical, err := payloadsig.Canonicalize(payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse report: %w", err)
+		}
+		if err := Verify(canonical, sig, trustedKeys); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var data collector.CollectedData
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse report: %w", err)
+	}
+	if data.DeviceID == "" || data.Timestamp == "" {
+		return nil, nil, fmt.Errorf("file is not a scanx report: missing device_id or timestamp")
+	}
+	if sig != nil && sig.DeviceID != "" && sig.DeviceID != data.DeviceID {
+		return nil, nil, fmt.Errorf("signature is for device %s but report is from %s", sig.DeviceID, data.DeviceID)
+	}
+
+	return &data, sig, nil
+}
+
+// Verify checks a detached signature against the canonical report JSON. The
+// public key embedded in the signature is only used if its fingerprint is one
+// of trustedKeys; anyone can re-sign an edited report with a key of their own.
+func Verify(canonical []byte, sig *Signature, trustedKeys []string) error {
+	if sig.Algorithm != SignatureAlgorithm {
+		return fmt.Errorf("unsupported signature algorithm '%s'", sig.Algorithm)
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(sig.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key in signature")
+	}
+	fingerprint := payloadsig.Fingerprint(publicKey)
+	if sig.KeyID != fingerprint {
+		return fmt.Errorf("signature key ID does not match its public key")
+	}
+	if !isTrusted(fingerprint, trustedKeys) {
+		return fmt.Errorf("report is signed by untrusted key %s", fingerprint)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	digest := sha256.Sum256(canonical)
+	if hex.EncodeToString(digest[:]) != sig.SHA256 {
+		return fmt.Errorf("report does not match signature digest (file modified?)")
+	}
+	if !ed25519.Verify(publicKey, canonical, signature) {
+		return fmt.Errorf("report signature verification failed")
+	}
+	return nil
+}
+
+// Encode returns the signature as compact base64-encoded JSON for use in a header
+func (sig *Signature) Encode() (string, error) {
+	encoded, err := json.Marshal(sig)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode signature: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+// isTrusted reports whether fingerprint is among the trusted ones, ignoring case
+func isTrusted(fingerprint string, trustedKeys []string) bool {
+	for _, trusted := range trustedKeys {
+		if strings.EqualFold(strings.TrimSpace(trusted), fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+// sign produces a detached signature over the canonical report JSON
+func sign(payload []byte, key ed25519.PrivateKey, deviceID string) *Signature {
+	publicKey := key.Public().(ed25519.PublicKey)
+	digest := sha256.Sum256(payload)
+	return &Signature{
+		Algorithm: SignatureAlgorithm,
+		KeyID:     payloadsig.Fingerprint(publicKey),
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+		SHA256:    hex.EncodeToString(digest[:]),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+		SignedAt:  time.Now().UTC().Format(time.RFC3339),
+		DeviceID:  deviceID,
+	}
+}
+
+// readSignature loads a detached signature, returning nil if none exists
+func readSignature(sigPath string) (*Signature, error) {
+	data, err := os.ReadFile(sigPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+
+	var sig Signature
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return nil, fmt.Errorf("failed to parse signature %s: %w", sigPath, err)
+	}
+	return &sig, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and renames it into place
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+strings.TrimPrefix(filepath.Base(path), ".")+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,142 @@
+package report
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"scanx/internal/collector"
+	"scanx/pkg/payloadsig"
+)
+
+func newKey(t *testing.T) (ed25519.PrivateKey, string) {
+	t.Helper()
+	publicKey, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, payloadsig.Fingerprint(publicKey)
+}
+
+func testReport() *collector.CollectedData {
+	return &collector.CollectedData{
+		DeviceID:  "scanx-device",
+		Timestamp: "2026-01-02T03:04:05+05:30",
+		Data: map[string][]map[string]interface{}{
+			"disk_encryption_info": {{"disk_encryption": "true"}},
+		},
+	}
+}
+
+func TestReadVerifiesAgainstTrustedKeys(t *testing.T) {
+	for _, gz := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "report.json")
+		key, fingerprint := newKey(t)
+		if err := Write(path, testReport(), WriteOptions{Gzip: gz, SigningKey: key}); err != nil {
+			t.Fatal(err)
+		}
+
+		data, sig, err := Read(path, []string{fingerprint})
+		if err != nil {
+			t.Fatalf("gzip=%v: Read with the signing key trusted: %v", gz, err)
+		}
+		if sig == nil || sig.KeyID != fingerprint || data.DeviceID != "scanx-device" {
+			t.Fatalf("gzip=%v: got sig %+v, data %+v", gz, sig, data)
+		}
+
+		_, otherFingerprint := newKey(t)
+		if _, _, err := Read(path, []string{otherFingerprint}); err == nil || !strings.Contains(err.Error(), "untrusted") {
+			t.Errorf("gzip=%v: Read trusting another key: err = %v, want untrusted key error", gz, err)
+		}
+	}
+}
+
+func TestReadRejectsReportResignedWithAnotherKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	deviceKey, deviceFingerprint := newKey(t)
+	if err := Write(path, testReport(), WriteOptions{SigningKey: deviceKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	// An attacker edits the report and signs it with their own key
+	tampered := testReport()
+	tampered.Data["disk_encryption_info"][0]["disk_encryption"] = "false"
+	attackerKey, _ := newKey(t)
+	if err := Write(path, tampered, WriteOptions{SigningKey: attackerKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Read(path, []string{deviceFingerprint}); err == nil {
+		t.Fatal("re-signed report was accepted")
+	}
+}
+
+func TestReadRejectsModifiedReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	key, fingerprint := newKey(t)
+	if err := Write(path, testReport(), WriteOptions{SigningKey: key}); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := strings.Replace(string(raw), `"true"`, `"false"`, 1)
+	if err := os.WriteFile(path, []byte(modified), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := Read(path, []string{fingerprint}); err == nil {
+		t.Fatal("modified report was accepted")
+	}
+}
+
+func TestReadUnsignedAndUnchecked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	_, fingerprint := newKey(t)
+	if err := Write(path, testReport(), WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, sig, err := Read(path, []string{fingerprint}); err != nil || sig != nil {
+		t.Fatalf("unsigned report: sig %+v, err %v; want no signature and no error", sig, err)
+	}
+
+	// Without trusted keys the signature is ignored, even one from an unknown key
+	key, _ := newKey(t)
+	if err := Write(path, testReport(), WriteOptions{SigningKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	if _, sig, err := Read(path, nil); err != nil || sig != nil {
+		t.Fatalf("unchecked read: sig %+v, err %v; want no signature and no error", sig, err)
+	}
+}
+
+func TestSignatureCoversReencodedReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json.gz")
+	key, fingerprint := newKey(t)
+	if err := Write(path, testReport(), WriteOptions{Gzip: true, SigningKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	data, sig, err := Read(path, []string{fingerprint})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The body `scanx send --file` sends must still verify against the signature
+	body, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := payloadsig.Canonicalize(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(canonical, sig, []string{fingerprint}); err != nil {
+		t.Fatalf("re-encoded report does not verify: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package sbom
+
+import (
+	"fmt"
+	"time"
+
+	"scanx/internal/software"
+)
+
+// cycloneDXSpecVersion is the CycloneDX JSON schema version written
+const cycloneDXSpecVersion = "1.5"
+
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies,omitempty"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Publisher  string        `json:"publisher,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	Purl       string        `json:"purl,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// cycloneDXDocument describes the device as the BOM's subject with each installed
+// item as a component it depends on
+func cycloneDXDocument(device Device, items []software.Item, created time.Time, serial string) cdxBOM {
+	deviceRef := "device"
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXSpecVersion,
+		SerialNumber: "urn:uuid:" + serial,
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools: cdxTools{Components: []cdxComponent{
+				{Type: "application", Name: toolName, Version: device.AgentVersion},
+			}},
+			Component: cdxComponent{
+				Type:   "device",
+				BOMRef: deviceRef,
+				Name:   device.displayName(),
+				Properties: properties(
+					"scanx:device_id", device.DeviceID,
+					"scanx:serial_no", device.SerialNo,
+					"scanx:os_type", device.OSType,
+					"scanx:os_version", device.OSVersion,
+				),
+			},
+		},
+		Components: []cdxComponent{},
+	}
+
+	dependency := cdxDependency{Ref: deviceRef, DependsOn: []string{}}
+	for i, item := range items {
+		component := cdxComponent{
+			Type:      "application",
+			BOMRef:    fmt.Sprintf("component-%d", i+1),
+			Publisher: item.Vendor,
+			Name:      item.Name,
+			Version:   item.Version,
+			Purl:      item.Purl,
+			Properties: properties(
+				"scanx:source", item.Source,
+				"scanx:identifier", item.Identifier,
+				"scanx:arch", item.Arch,
+				"scanx:install_date", item.InstallDate,
+			),
+		}
+		if isOSPackage(item) {
+			component.Type = "library"
+		}
+		bom.Components = append(bom.Components, component)
+		dependency.DependsOn = append(dependency.DependsOn, component.BOMRef)
+	}
+	bom.Dependencies = []cdxDependency{dependency}
+	return bom
+}
+
+// properties builds name/value pairs from alternating arguments, skipping empty values
+func properties(pairs ...string) []cdxProperty {
+	var props []cdxProperty
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			props = append(props, cdxProperty{Name: pairs[i], Value: pairs[i+1]})
+		}
+	}
+	return props
+}
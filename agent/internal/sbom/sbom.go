@@ -0,0 +1,88 @@
+package sbom
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"scanx/internal/software"
+)
+
+// Supported SBOM formats
+const (
+	FormatCycloneDX = "cyclonedx"
+	FormatSPDX      = "spdx"
+)
+
+// Formats lists the formats accepted by Write
+var Formats = []string{FormatCycloneDX, FormatSPDX}
+
+// toolName identifies the agent as the SBOM author
+const toolName = "scanx"
+
+// Device describes the endpoint the software is installed on
+type Device struct {
+	Name         string
+	DeviceID     string
+	SerialNo     string
+	OSType       string
+	OSVersion    string
+	AgentVersion string
+}
+
+// displayName names the device in documents, falling back to its ID or serial
+// number when the computer name is unknown
+func (d Device) displayName() string {
+	for _, name := range []string{d.Name, d.DeviceID, d.SerialNo} {
+		if strings.TrimSpace(name) != "" {
+			return name
+		}
+	}
+	return "device"
+}
+
+// Write encodes the device's software as an SBOM document in the given format
+func Write(w io.Writer, format string, device Device, items []software.Item, created time.Time) error {
+	serial, err := newUUID()
+	if err != nil {
+		return fmt.Errorf("failed to generate document ID: %w", err)
+	}
+
+	var document interface{}
+	switch format {
+	case FormatCycloneDX:
+		document = cycloneDXDocument(device, items, created, serial)
+	case FormatSPDX:
+		document = spdxDocument(device, items, created, serial)
+	default:
+		return fmt.Errorf("unknown SBOM format '%s'", format)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	// Package URLs contain '&', which must stay readable
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("failed to write SBOM: %w", err)
+	}
+	return nil
+}
+
+// isOSPackage reports whether an item is a distribution package rather than an application
+func isOSPackage(item software.Item) bool {
+	return item.Source == software.SourceDeb || item.Source == software.SourceRPM
+}
+
+// newUUID returns a random RFC 4122 version 4 UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
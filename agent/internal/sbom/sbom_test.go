@@ -0,0 +1,50 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"scanx/internal/software"
+)
+
+func TestDeviceWithoutComputerNameIsNamed(t *testing.T) {
+	device := Device{DeviceID: "scanx-1234", OSType: "linux"}
+	items := []software.Item{{Name: "openssl", Version: "3.0.11", Source: software.SourceDeb}}
+
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, format, device, items, time.Now()); err != nil {
+				t.Fatal(err)
+			}
+
+			var doc struct {
+				Name     string `json:"name"`
+				Packages []struct {
+					Name string `json:"name"`
+				} `json:"packages"`
+				Metadata struct {
+					Component struct {
+						Name string `json:"name"`
+					} `json:"component"`
+				} `json:"metadata"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			}
+
+			deviceName := doc.Metadata.Component.Name
+			if format == FormatSPDX {
+				deviceName = doc.Packages[0].Name
+				if doc.Name != "scanx-scanx-1234" {
+					t.Errorf("document name = %q", doc.Name)
+				}
+			}
+			if deviceName != "scanx-1234" {
+				t.Errorf("device name = %q, want the device ID", deviceName)
+			}
+		})
+	}
+}
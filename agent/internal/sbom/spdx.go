@@ -0,0 +1,143 @@
+package sbom
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"scanx/internal/software"
+)
+
+// spdxVersion is the SPDX specification version written
+const spdxVersion = "SPDX-2.3"
+
+// spdxNoAssertion marks fields the agent has no information about
+const spdxNoAssertion = "NOASSERTION"
+
+type spdxDoc struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name                  string            `json:"name"`
+	SPDXID                string            `json:"SPDXID"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	Supplier              string            `json:"supplier"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	LicenseConcluded      string            `json:"licenseConcluded"`
+	LicenseDeclared       string            `json:"licenseDeclared"`
+	CopyrightText         string            `json:"copyrightText"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose,omitempty"`
+	Comment               string            `json:"comment,omitempty"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// spdxNameSanitizer keeps document names to characters every SPDX tool accepts
+var spdxNameSanitizer = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+
+// spdxDocument describes the device as a package that contains each installed item
+func spdxDocument(device Device, items []software.Item, created time.Time, serial string) spdxDoc {
+	name := strings.Trim(spdxNameSanitizer.ReplaceAllString(device.displayName(), "-"), "-")
+	if name == "" {
+		name = "device"
+	}
+
+	creator := "Tool: " + toolName
+	if device.AgentVersion != "" {
+		creator += "-" + device.AgentVersion
+	}
+
+	deviceID := "SPDXRef-Device"
+	doc := spdxDoc{
+		SPDXVersion:       spdxVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              toolName + "-" + name,
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/%s-%s-%s", toolName, name, serial),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{creator},
+		},
+		Packages: []spdxPackage{{
+			Name:                  device.displayName(),
+			SPDXID:                deviceID,
+			Supplier:              spdxNoAssertion,
+			DownloadLocation:      spdxNoAssertion,
+			LicenseConcluded:      spdxNoAssertion,
+			LicenseDeclared:       spdxNoAssertion,
+			CopyrightText:         spdxNoAssertion,
+			PrimaryPackagePurpose: "DEVICE",
+			Comment:               fmt.Sprintf("device_id=%s serial_no=%s os=%s %s", device.DeviceID, device.SerialNo, device.OSType, device.OSVersion),
+		}},
+		Relationships: []spdxRelationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: deviceID,
+		}},
+	}
+
+	for i, item := range items {
+		pkg := spdxPackage{
+			Name:                  item.Name,
+			SPDXID:                fmt.Sprintf("SPDXRef-Package-%d", i+1),
+			VersionInfo:           item.Version,
+			Supplier:              spdxNoAssertion,
+			DownloadLocation:      spdxNoAssertion,
+			LicenseConcluded:      spdxNoAssertion,
+			LicenseDeclared:       spdxNoAssertion,
+			CopyrightText:         spdxNoAssertion,
+			PrimaryPackagePurpose: "APPLICATION",
+			Comment:               "source=" + item.Source,
+		}
+		if item.Vendor != "" {
+			pkg.Supplier = "Organization: " + item.Vendor
+		}
+		if isOSPackage(item) {
+			pkg.PrimaryPackagePurpose = "LIBRARY"
+		}
+		if item.InstallDate != "" {
+			pkg.Comment += " install_date=" + item.InstallDate
+		}
+		if item.Purl != "" {
+			pkg.ExternalRefs = []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  item.Purl,
+			}}
+		}
+
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      deviceID,
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: pkg.SPDXID,
+		})
+	}
+	return doc
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"scanx/internal/config"
+)
+
+// quietWindow is a parsed config.QuietWindow with minutes since midnight
+type quietWindow struct {
+	start int
+	end   int
+	days  map[time.Weekday]bool
+}
+
+// weekdayNames are indexed by time.Weekday
+var weekdayNames = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// parseWeekday accepts a day name or any prefix of it of at least three letters,
+// matching the config validation
+func parseWeekday(day string) (time.Weekday, bool) {
+	day = strings.ToLower(day)
+	if len(day) < 3 {
+		return 0, false
+	}
+	for i, name := range weekdayNames {
+		if strings.HasPrefix(name, day) {
+			return time.Weekday(i), true
+		}
+	}
+	return 0, false
+}
+
+// parseQuietWindows validates and converts the configured quiet hours
+func parseQuietWindows(windows []config.QuietWindow) ([]quietWindow, error) {
+	parsed := make([]quietWindow, 0, len(windows))
+	for i, window := range windows {
+		start, err := parseClock(window.Start)
+		if err != nil {
+			return nil, fmt.Errorf("quiet_hours[%d].start: %w", i, err)
+		}
+		end, err := parseClock(window.End)
+		if err != nil {
+			return nil, fmt.Errorf("quiet_hours[%d].end: %w", i, err)
+		}
+
+		qw := quietWindow{start: start, end: end}
+		if len(window.Days) > 0 {
+			qw.days = make(map[time.Weekday]bool, len(window.Days))
+			for _, day := range window.Days {
+				weekday, ok := parseWeekday(day)
+				if !ok {
+					return nil, fmt.Errorf("quiet_hours[%d].days: unknown day '%s'", i, day)
+				}
+				qw.days[weekday] = true
+			}
+		}
+		parsed = append(parsed, qw)
+	}
+	return parsed, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s', expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inQuietHours reports whether t falls in any window, using t's location
+func inQuietHours(windows []quietWindow, t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range windows {
+		if window.start <= window.end {
+			if minute >= window.start && minute < window.end && window.activeOn(t.Weekday()) {
+				return true
+			}
+			continue
+		}
+
+		// Window wraps past midnight: the late part belongs to today, the early part to yesterday
+		if minute >= window.start && window.activeOn(t.Weekday()) {
+			return true
+		}
+		if minute < window.end && window.activeOn((t.Weekday()+6)%7) {
+			return true
+		}
+	}
+	return false
+}
+
+// activeOn reports whether the window applies on the given weekday
+func (w quietWindow) activeOn(day time.Weekday) bool {
+	return w.days == nil || w.days[day]
+}
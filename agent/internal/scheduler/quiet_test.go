@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"scanx/internal/config"
+)
+
+func TestParseWeekday(t *testing.T) {
+	tests := []struct {
+		day  string
+		want time.Weekday
+		ok   bool
+	}{
+		{day: "Mon", want: time.Monday, ok: true},
+		{day: "saturday", want: time.Saturday, ok: true},
+		{day: "THURS", want: time.Thursday, ok: true},
+		{day: "su", ok: false},
+		{day: "Mondays", ok: false},
+		{day: "Funday", ok: false},
+		// Multi-byte input must neither panic nor match by a split rune
+		{day: "Mö", ok: false},
+		{day: "Món", ok: false},
+		{day: "日曜日", ok: false},
+		{day: "", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := parseWeekday(tt.day)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("parseWeekday(%q) = %v, %v; want %v, %v", tt.day, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseQuietWindowsAgreesWithValidation(t *testing.T) {
+	for _, day := range []string{"Mon", "tues", "Wednesday", "Mö", "日曜日", "Sundays"} {
+		window := config.QuietWindow{Start: "22:00", End: "06:00", Days: []string{day}}
+		_, err := parseQuietWindows([]config.QuietWindow{window})
+		issues := (&config.AgentConfig{QuietHours: []config.QuietWindow{window}}).Validate()
+		if (err == nil) != !config.HasErrors(issues) {
+			t.Errorf("day %q: scheduler error %v, validation issues %v", day, err, issues)
+		}
+	}
+}
+
+func TestInQuietHoursWrapsMidnight(t *testing.T) {
+	windows, err := parseQuietWindows([]config.QuietWindow{{Start: "22:00", End: "06:00", Days: []string{"Fri"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	friday := time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)
+	if !inQuietHours(windows, friday) {
+		t.Error("Friday 23:00 not quiet")
+	}
+	if !inQuietHours(windows, friday.Add(6*time.Hour)) {
+		t.Error("Saturday 05:00 not quiet, although the Friday window runs until 06:00")
+	}
+	if inQuietHours(windows, friday.Add(24*time.Hour)) {
+		t.Error("Saturday 23:00 quiet, although only Friday is configured")
+	}
+}
@@ -2,82 +2,294 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	"scanx/internal/collector"
 	"scanx/internal/config"
+	"scanx/internal/instance"
 	"scanx/internal/sender"
 	"scanx/internal/utils"
 )
 
+// ShutdownGracePeriod is how long Stop lets an in-flight cycle finish before aborting it
+const ShutdownGracePeriod = 20 * time.Second
+
+// abortWaitPeriod is how long Stop waits for an aborted cycle to unwind
+const abortWaitPeriod = 10 * time.Second
+
+// Suspend/resume and clock-jump detection settings
+const (
+	// clockCheckPeriod is how often wall and monotonic time are compared
+	clockCheckPeriod = 30 * time.Second
+	// clockJumpThreshold is the drift between wall and monotonic elapsed time treated as a jump
+	clockJumpThreshold = time.Minute
+	// networkWaitTimeout bounds how long a catch-up run waits for the backend after wake
+	networkWaitTimeout = 5 * time.Minute
+	// networkRetryPeriod is the delay between backend reachability checks after wake
+	networkRetryPeriod = 15 * time.Second
+)
+
 // Scheduler handles periodic data collection and transmission
 type Scheduler struct {
 	config    *config.Config
 	collector *collector.Collector
-	sender    *sender.BackendSender
+	sinks     *sender.Dispatcher
 	interval  time.Duration
+	stateDir  string
+	cron      *CronSchedule
+	splay     time.Duration
+	quiet     []quietWindow
+
+	// ctx ends the scheduling loop; runCtx is passed to in-flight work and
+	// is only cancelled once the shutdown grace period runs out
 	ctx       context.Context
 	cancel    context.CancelFunc
+	runCtx    context.Context
+	runCancel context.CancelFunc
+	done      chan struct{}
+	// started is set by whichever of Start or Stop runs first; Start is a
+	// no-op once the scheduler has been stopped
+	started atomic.Bool
 }
 
 // NewScheduler creates a new scheduler with specified interval
 func NewScheduler(cfg *config.Config, collectorInstance *collector.Collector, interval time.Duration) *Scheduler {
 	ctx, cancel := context.WithCancel(context.Background())
+	runCtx, runCancel := context.WithCancel(context.Background())
 
-	// Initialize backend sender
-	backendURL := sender.GetBackendURLFromConfig(cfg)
-	backendSender := sender.NewBackendSender(backendURL)
+	// Initialize output sinks (REST backend unless others are configured)
+	sinks := sender.NewDispatcherFromConfig(cfg)
 
-	return &Scheduler{
+	s := &Scheduler{
 		config:    cfg,
 		collector: collectorInstance,
-		sender:    backendSender,
+		sinks:     sinks,
 		interval:  interval,
 		ctx:       ctx,
 		cancel:    cancel,
+		runCtx:    runCtx,
+		runCancel: runCancel,
+		done:      make(chan struct{}),
 	}
+
+	// Cron schedule takes precedence over the fixed interval
+	if cfg.Agent.Schedule != "" {
+		schedule, err := ParseCron(cfg.Agent.Schedule)
+		if err == nil && schedule.Next(time.Now()).IsZero() {
+			err = fmt.Errorf("cron expression '%s' never fires", cfg.Agent.Schedule)
+		}
+		if err != nil {
+			utils.Warning("Invalid schedule, falling back to %v interval: %v", interval, err)
+		} else {
+			s.cron = schedule
+		}
+	}
+
+	// Splay is derived from the device ID so each device keeps the same slot
+	maxSplay := cfg.GetSplay()
+	if s.cron == nil && maxSplay > interval {
+		maxSplay = interval
+	}
+	s.splay = SplayOffset(collectorInstance.GetSystemInfo().DeviceID, maxSplay)
+
+	// Collection cycles coordinate with one-shot runs through the collection lock
+	stateDir, err := utils.EnsureStateDir()
+	if err != nil {
+		utils.Warning("Collection lock unavailable: %v", err)
+	}
+	s.stateDir = stateDir
+
+	quiet, err := parseQuietWindows(cfg.Agent.QuietHours)
+	if err != nil {
+		utils.Warning("Ignoring invalid quiet hours: %v", err)
+	} else {
+		s.quiet = quiet
+	}
+
+	return s
 }
 
 // Start begins periodic data collection and transmission
 func (s *Scheduler) Start() {
+	if !s.started.CompareAndSwap(false, true) {
+		return
+	}
+	defer close(s.done)
 	utils.Info("Starting data collection scheduler with %v interval", s.interval)
 
 	// Test backend connection first
-	if err := s.sender.TestConnection(); err != nil {
+	if err := s.sinks.TestConnection(); err != nil {
 		utils.Warning("Backend connection test failed: %v", err)
 		utils.Warning("Will continue and retry with each data collection...")
 	}
 
-	// Run initial collection immediately
-	s.runCollection()
+	if s.cron != nil {
+		utils.Info("Using cron schedule '%s' with %v device splay", s.cron, s.splay)
+	} else {
+		utils.Info("First collection in %v (device splay)", s.splay)
+	}
+
+	// logind tells us about suspend/resume directly on Linux; clock checks cover the rest
+	sleepEvents, err := watchSleepSignals(s.ctx)
+	if err != nil {
+		utils.Debug("Sleep notifications unavailable, relying on clock-jump detection: %v", err)
+	}
 
-	// Start periodic timer
-	ticker := time.NewTicker(s.interval)
-	defer ticker.Stop()
+	clockCheck := time.NewTicker(clockCheckPeriod)
+	defer clockCheck.Stop()
+	lastCheck := time.Now()
 
+	// Times are kept as wall-clock readings (Round(0) strips the monotonic part) so that
+	// time spent suspended counts towards the next run. The first run is anchored to
+	// the start so that clock checks and sleep events do not keep pushing it back.
+	started := time.Now().Round(0)
+	var lastRun time.Time
 	for {
+		next := s.nextRun(time.Now().Round(0), started, lastRun)
+		utils.Debug("Next data collection at %v", next)
+
+		timer := time.NewTimer(time.Until(next))
+		wake := false
 		select {
-		case <-ticker.C:
+		case <-timer.C:
+			lastRun = time.Now().Round(0)
 			s.runCollection()
+			timer.Stop()
+			continue
+		case <-clockCheck.C:
+			now := time.Now()
+			drift := now.Round(0).Sub(lastCheck.Round(0)) - now.Sub(lastCheck)
+			lastCheck = now
+			if drift > clockJumpThreshold || drift < -clockJumpThreshold {
+				utils.Info("Wall clock moved %v relative to monotonic time (suspend/resume or clock change)", drift.Round(time.Second))
+				wake = true
+			}
+		case sleeping, ok := <-sleepEvents:
+			if !ok {
+				sleepEvents = nil
+				break
+			}
+			if sleeping {
+				utils.Info("System is preparing to sleep")
+			} else {
+				utils.Info("System resumed from sleep")
+				lastCheck = time.Now()
+				wake = true
+			}
 		case <-s.ctx.Done():
+			timer.Stop()
 			utils.Info("Scheduler stopped")
 			return
 		}
+		timer.Stop()
+
+		// After wake, run once to catch up if a scheduled run was missed. When the
+		// backend stays unreachable the missed run is given up and the schedule
+		// continues from now instead of firing straight away.
+		if catchUpDue(wake, time.Now(), next) {
+			if waitForBackend(s.ctx, s.sinks.TestConnection, networkWaitTimeout, networkRetryPeriod) {
+				lastRun = time.Now().Round(0)
+				s.runCollection()
+			} else if s.ctx.Err() == nil {
+				lastRun = time.Now().Round(0)
+			}
+		}
 	}
 }
 
-// Stop stops the scheduler
+// catchUpDue reports whether a wake at now missed the run scheduled for next
+func catchUpDue(wake bool, now time.Time, next time.Time) bool {
+	return wake && !now.Before(next)
+}
+
+// waitForBackend polls check until it succeeds, timeout passes or ctx ends. It
+// returns true only once the backend is reachable.
+func waitForBackend(ctx context.Context, check func() error, timeout time.Duration, retry time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := check()
+		if err == nil {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			utils.Warning("Backend still unreachable %v after wake, skipping catch-up collection: %v", timeout, err)
+			return false
+		}
+
+		utils.Info("Waiting for network before catch-up collection: %v", err)
+		select {
+		case <-time.After(retry):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// nextRun returns when the next collection should start, given when the scheduler
+// started and when it last ran (zero before the first run)
+func (s *Scheduler) nextRun(now time.Time, started time.Time, lastRun time.Time) time.Time {
+	if s.cron != nil {
+		// Look back by the splay so a slot whose splayed time is still ahead is not skipped
+		return s.cron.Next(now.Add(-s.splay)).Add(s.splay)
+	}
+
+	if lastRun.IsZero() {
+		return started.Add(s.splay)
+	}
+	return lastRun.Add(s.interval)
+}
+
+// Stop stops the scheduler, waiting up to ShutdownGracePeriod for an in-flight
+// cycle to finish before cancelling it and killing its osquery processes
 func (s *Scheduler) Stop() {
 	utils.Info("Stopping scheduler...")
 	s.cancel()
+
+	// Nothing is running if Start was never called
+	if s.started.CompareAndSwap(false, true) {
+		s.runCancel()
+		close(s.done)
+		return
+	}
+
+	select {
+	case <-s.done:
+		s.runCancel()
+		return
+	case <-time.After(ShutdownGracePeriod):
+		utils.Warning("Collection still running after %v, aborting", ShutdownGracePeriod)
+	}
+
+	s.runCancel()
+	select {
+	case <-s.done:
+	case <-time.After(abortWaitPeriod):
+		utils.Error("Collection did not stop within %v after abort", abortWaitPeriod)
+	}
 }
 
 // runCollection performs a single data collection cycle
 func (s *Scheduler) runCollection() {
 	utils.Info("Starting data collection at %v", utils.GetCurrentISTString())
 
-	// Collect data
-	data, err := s.collector.CollectData()
+	// Wait for a manual -test or one-shot run to finish instead of running alongside it
+	if s.stateDir != "" {
+		lock, err := instance.Acquire(s.runCtx, s.stateDir, instance.CollectionLockFile, "daemon", s.config.GetCollectionTimeout()+time.Minute)
+		if err != nil {
+			utils.Error("Skipping collection, could not acquire collection lock: %v", err)
+			return
+		}
+		defer lock.Release()
+	}
+
+	// Collect data, suppressing heavy queries during quiet hours
+	opts := collector.CollectOptions{SkipHeavy: inQuietHours(s.quiet, time.Now())}
+	if opts.SkipHeavy {
+		utils.Info("Within quiet hours, heavy queries will be skipped")
+	}
+	data, err := s.collector.CollectDataWithOptions(s.runCtx, opts)
 	if err != nil {
 		utils.Error("Error collecting data: %v", err)
 		return
@@ -89,19 +301,21 @@ func (s *Scheduler) runCollection() {
 	utils.Info("  OS Type: %s", data.OSType)
 	utils.Info("  OS Version: %s", data.OSVersion)
 	utils.Info("  Serial No: %s", data.SerialNo)
+	utils.Info("  Device ID: %s", data.DeviceID)
 	utils.Info("  Timestamp: %s", data.Timestamp)
 	utils.Info("  Queries executed: %d", len(data.Data))
 
 	for queryName, results := range data.Data {
-		utils.Info("    %s: %d records", queryName, len(results))
+		utils.Info("    %s: %d records (%s)", queryName, len(results), data.QueryMeta[queryName].Status)
 	}
 
-	// Send data to backend server
-	utils.Info("📡 Sending data to backend...")
-	if err := s.sender.SendAgentData(data); err != nil {
-		utils.Error("❌ Failed to send data to backend: %v", err)
-		utils.Error("   Data will be lost. Check backend connectivity.")
+	// Deliver to every sink; a failing sink does not affect the others
+	utils.Info("📡 Sending data to %d sink(s)...", len(s.sinks.Sinks()))
+	if err := s.sinks.Send(s.runCtx, data); err != nil {
+		utils.Error("❌ Failed to deliver data to some sinks: %v", err)
+		utils.Error("   Data for those sinks will be lost. Check their connectivity.")
 	} else {
+		s.collector.AcknowledgeHardwareChange()
 		utils.Info("🎯 Data collection and transmission cycle completed successfully")
 	}
 }
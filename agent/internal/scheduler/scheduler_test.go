@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newStoppableScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	runCtx, runCancel := context.WithCancel(context.Background())
+	return &Scheduler{ctx: ctx, cancel: cancel, runCtx: runCtx, runCancel: runCancel, done: make(chan struct{})}
+}
+
+func TestStopWithoutStartReturns(t *testing.T) {
+	s := newStoppableScheduler()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked although Start was never called")
+	}
+
+	if s.runCtx.Err() == nil {
+		t.Error("run context not cancelled")
+	}
+	// A Start racing with the Stop must not run or close done twice
+	s.Start()
+}
+
+func TestCatchUpDue(t *testing.T) {
+	next := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		wake bool
+		now  time.Time
+		want bool
+	}{
+		{name: "woke after the missed run", wake: true, now: next.Add(8 * time.Hour), want: true},
+		{name: "woke exactly at the run", wake: true, now: next, want: true},
+		{name: "woke before the run", wake: true, now: next.Add(-time.Minute), want: false},
+		{name: "no wake", wake: false, now: next.Add(time.Hour), want: false},
+	}
+	for _, tt := range tests {
+		if got := catchUpDue(tt.wake, tt.now, next); got != tt.want {
+			t.Errorf("%s: catchUpDue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWaitForBackend(t *testing.T) {
+	unreachable := errors.New("network is unreachable")
+
+	calls := 0
+	check := func() error {
+		calls++
+		if calls < 3 {
+			return unreachable
+		}
+		return nil
+	}
+	if !waitForBackend(context.Background(), check, time.Second, time.Millisecond) {
+		t.Error("waitForBackend gave up although the backend came back")
+	}
+	if calls != 3 {
+		t.Errorf("%d checks, want 3", calls)
+	}
+
+	// A backend that never comes back must not trigger the catch-up run
+	if waitForBackend(context.Background(), func() error { return unreachable }, 20*time.Millisecond, time.Millisecond) {
+		t.Error("waitForBackend reported success after timing out")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if waitForBackend(ctx, func() error { return unreachable }, time.Minute, time.Minute) {
+		t.Error("waitForBackend reported success after the scheduler stopped")
+	}
+}
+
+func TestNextRunKeepsFirstRunAcrossClockChecks(t *testing.T) {
+	s := &Scheduler{interval: time.Hour, splay: 5 * time.Minute}
+	started := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	want := started.Add(s.splay)
+
+	// The loop recomputes the deadline after every clock check and sleep event
+	for now := started; now.Before(want); now = now.Add(clockCheckPeriod) {
+		if got := s.nextRun(now, started, time.Time{}); !got.Equal(want) {
+			t.Fatalf("at %v next run = %v, want %v", now.Sub(started), got, want)
+		}
+	}
+
+	lastRun := want.Add(time.Second)
+	if got := s.nextRun(lastRun.Add(clockCheckPeriod), started, lastRun); !got.Equal(lastRun.Add(s.interval)) {
+		t.Errorf("next run after the first = %v, want %v", got, lastRun.Add(s.interval))
+	}
+}
+
+func TestNextRunCronIgnoresClockChecks(t *testing.T) {
+	schedule, err := ParseCron("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Scheduler{interval: time.Hour, splay: 5 * time.Minute, cron: schedule}
+	started := time.Date(2026, 10, 18, 9, 58, 0, 0, time.UTC)
+	want := time.Date(2026, 10, 18, 10, 5, 0, 0, time.UTC)
+
+	for now := started; now.Before(want); now = now.Add(clockCheckPeriod) {
+		if got := s.nextRun(now, started, time.Time{}); !got.Equal(want) {
+			t.Fatalf("at %v next run = %v, want %v", now, got, want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+//go:build linux
+
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"scanx/internal/utils"
+)
+
+// Minimal D-Bus client, just enough to subscribe to logind's PrepareForSleep signal
+// on the system bus without pulling in a D-Bus library.
+
+const (
+	dbusMessageMethodCall = 1
+	dbusMessageSignal     = 4
+
+	dbusFieldPath        = 1
+	dbusFieldInterface   = 2
+	dbusFieldMember      = 3
+	dbusFieldDestination = 6
+	dbusFieldSignature   = 8
+
+	prepareForSleepMatch = "type='signal',sender='org.freedesktop.login1',interface='org.freedesktop.login1.Manager',member='PrepareForSleep',path='/org/freedesktop/login1'"
+)
+
+// watchSleepSignals subscribes to logind PrepareForSleep. The channel receives
+// true before the system suspends and false after it resumes; it is closed when
+// ctx ends or the bus connection drops.
+func watchSleepSignals(ctx context.Context) (<-chan bool, error) {
+	conn, err := net.Dial("unix", systemBusPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	if err := dbusAuthenticate(conn, reader); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// Hello must be the first call on a new connection; AddMatch subscribes to the signal
+	if err := dbusCall(conn, 1, "Hello", ""); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := dbusCall(conn, 2, "AddMatch", prepareForSleepMatch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	events := make(chan bool, 4)
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go func() {
+		defer close(events)
+		for {
+			msgType, fields, body, order, err := dbusReadMessage(reader)
+			if err != nil {
+				if ctx.Err() == nil {
+					utils.Warning("D-Bus sleep watcher stopped: %v", err)
+				}
+				return
+			}
+			sleeping, ok := parseSleepSignal(msgType, fields, body, order)
+			if !ok {
+				continue
+			}
+			select {
+			case events <- sleeping:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// parseSleepSignal extracts the boolean argument of a PrepareForSleep signal
+func parseSleepSignal(msgType byte, fields map[byte]string, body []byte, order binary.ByteOrder) (bool, bool) {
+	if msgType != dbusMessageSignal || fields[dbusFieldInterface] != "org.freedesktop.login1.Manager" ||
+		fields[dbusFieldMember] != "PrepareForSleep" || len(body) < 4 {
+		return false, false
+	}
+	return order.Uint32(body[:4]) != 0, true
+}
+
+// systemBusPath returns the system bus socket path, honouring DBUS_SYSTEM_BUS_ADDRESS
+func systemBusPath() string {
+	if address := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); strings.HasPrefix(address, "unix:path=") {
+		path, _, _ := strings.Cut(strings.TrimPrefix(address, "unix:path="), ",")
+		return path
+	}
+	return "/run/dbus/system_bus_socket"
+}
+
+// dbusAuthenticate performs SASL EXTERNAL authentication with our uid
+func dbusAuthenticate(conn net.Conn, reader *bufio.Reader) error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return fmt.Errorf("failed to send D-Bus auth: %w", err)
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read D-Bus auth reply: %w", err)
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("D-Bus authentication rejected: %s", strings.TrimSpace(line))
+	}
+
+	if _, err := conn.Write([]byte("BEGIN\r\n")); err != nil {
+		return fmt.Errorf("failed to begin D-Bus session: %w", err)
+	}
+	return nil
+}
+
+// dbusCall sends a method call to the bus daemon with an optional single string argument
+func dbusCall(w io.Writer, serial uint32, member string, arg string) error {
+	var body dbusBuffer
+	if arg != "" {
+		body.putString(arg)
+	}
+
+	var msg dbusBuffer
+	msg.buf.Write([]byte{'l', dbusMessageMethodCall, 0, 1})
+	msg.putUint32(uint32(body.buf.Len()))
+	msg.putUint32(serial)
+
+	var fields dbusBuffer
+	fields.offset = 16 // array contents start after the length word at offset 12
+	fields.putField(dbusFieldPath, "o", "/org/freedesktop/DBus")
+	fields.putField(dbusFieldInterface, "s", "org.freedesktop.DBus")
+	fields.putField(dbusFieldMember, "s", member)
+	fields.putField(dbusFieldDestination, "s", "org.freedesktop.DBus")
+	if arg != "" {
+		fields.putField(dbusFieldSignature, "g", "s")
+	}
+
+	msg.putUint32(uint32(fields.buf.Len()))
+	msg.buf.Write(fields.buf.Bytes())
+	msg.align(8)
+	msg.buf.Write(body.buf.Bytes())
+
+	if _, err := w.Write(msg.buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to send D-Bus %s: %w", member, err)
+	}
+	return nil
+}
+
+// dbusReadMessage reads one message and returns its type, string header fields and raw body
+func dbusReadMessage(r io.Reader) (byte, map[byte]string, []byte, binary.ByteOrder, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return 0, nil, nil, nil, err
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if fixed[0] == 'B' {
+		order = binary.BigEndian
+	}
+	msgType := fixed[1]
+	bodyLen := order.Uint32(fixed[4:8])
+	fieldsLen := order.Uint32(fixed[12:16])
+	if bodyLen > 1<<24 || fieldsLen > 1<<20 {
+		return 0, nil, nil, nil, fmt.Errorf("oversized D-Bus message")
+	}
+
+	// Header fields are padded so the body starts on an 8-byte boundary
+	headerRest := int(fieldsLen)
+	if pad := (16 + headerRest) % 8; pad != 0 {
+		headerRest += 8 - pad
+	}
+	rest := make([]byte, headerRest+int(bodyLen))
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, nil, nil, nil, err
+	}
+
+	fields := parseDBusFields(rest[:fieldsLen], order)
+	return msgType, fields, rest[headerRest:], order, nil
+}
+
+// parseDBusFields decodes header fields whose values are strings, object paths or signatures
+func parseDBusFields(data []byte, order binary.ByteOrder) map[byte]string {
+	fields := make(map[byte]string)
+	pos := 0
+	// Field structs are 8-byte aligned relative to the message start, which is offset 16 here
+	for pos < len(data) {
+		if pad := (16 + pos) % 8; pad != 0 {
+			pos += 8 - pad
+		}
+		if pos+2 > len(data) {
+			break
+		}
+		code := data[pos]
+		sigLen := int(data[pos+1])
+		pos += 2
+		if pos+sigLen+1 > len(data) {
+			break
+		}
+		sig := string(data[pos : pos+sigLen])
+		pos += sigLen + 1
+
+		switch sig {
+		case "s", "o":
+			if pad := (16 + pos) % 4; pad != 0 {
+				pos += 4 - pad
+			}
+			if pos+4 > len(data) {
+				return fields
+			}
+			n := int(order.Uint32(data[pos : pos+4]))
+			pos += 4
+			if pos+n+1 > len(data) {
+				return fields
+			}
+			fields[code] = string(data[pos : pos+n])
+			pos += n + 1
+		case "g":
+			if pos >= len(data) {
+				return fields
+			}
+			n := int(data[pos])
+			if pos+1+n+1 > len(data) {
+				return fields
+			}
+			fields[code] = string(data[pos+1 : pos+1+n])
+			pos += n + 2
+		case "u":
+			if pad := (16 + pos) % 4; pad != 0 {
+				pos += 4 - pad
+			}
+			pos += 4
+		default:
+			// Unknown field type; stop parsing rather than misread the rest
+			return fields
+		}
+	}
+	return fields
+}
+
+// dbusBuffer marshals little-endian D-Bus values while tracking alignment
+type dbusBuffer struct {
+	buf    bytes.Buffer
+	offset int // absolute offset of buf's start within the message
+}
+
+func (b *dbusBuffer) align(n int) {
+	for (b.offset+b.buf.Len())%n != 0 {
+		b.buf.WriteByte(0)
+	}
+}
+
+func (b *dbusBuffer) putUint32(v uint32) {
+	b.align(4)
+	binary.Write(&b.buf, binary.LittleEndian, v)
+}
+
+func (b *dbusBuffer) putString(s string) {
+	b.putUint32(uint32(len(s)))
+	b.buf.WriteString(s)
+	b.buf.WriteByte(0)
+}
+
+func (b *dbusBuffer) putSignature(s string) {
+	b.buf.WriteByte(byte(len(s)))
+	b.buf.WriteString(s)
+	b.buf.WriteByte(0)
+}
+
+// putField writes a (byte, variant) header field struct
+func (b *dbusBuffer) putField(code byte, sig string, value string) {
+	b.align(8)
+	b.buf.WriteByte(code)
+	b.putSignature(sig)
+	if sig == "g" {
+		b.putSignature(value)
+	} else {
+		b.putString(value)
+	}
+}
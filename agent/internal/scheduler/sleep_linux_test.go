@@ -0,0 +1,119 @@
+//go:build linux
+
+package scheduler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// sleepSignal marshals a little-endian PrepareForSleep signal as logind sends it
+func sleepSignal(member string, sleeping bool) []byte {
+	var body dbusBuffer
+	if sleeping {
+		body.putUint32(1)
+	} else {
+		body.putUint32(0)
+	}
+
+	var msg dbusBuffer
+	msg.buf.Write([]byte{'l', dbusMessageSignal, 0, 1})
+	msg.putUint32(uint32(body.buf.Len()))
+	msg.putUint32(7)
+
+	var fields dbusBuffer
+	fields.offset = 16
+	fields.putField(dbusFieldPath, "o", "/org/freedesktop/login1")
+	fields.putField(dbusFieldInterface, "s", "org.freedesktop.login1.Manager")
+	fields.putField(dbusFieldMember, "s", member)
+	fields.putField(dbusFieldSignature, "g", "b")
+
+	msg.putUint32(uint32(fields.buf.Len()))
+	msg.buf.Write(fields.buf.Bytes())
+	msg.align(8)
+	msg.buf.Write(body.buf.Bytes())
+	return msg.buf.Bytes()
+}
+
+func TestDBusCallRoundTrip(t *testing.T) {
+	var wire bytes.Buffer
+	if err := dbusCall(&wire, 2, "AddMatch", prepareForSleepMatch); err != nil {
+		t.Fatal(err)
+	}
+
+	msgType, fields, body, order, err := dbusReadMessage(&wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgType != dbusMessageMethodCall {
+		t.Errorf("type = %d, want method call", msgType)
+	}
+	want := map[byte]string{
+		dbusFieldPath:        "/org/freedesktop/DBus",
+		dbusFieldInterface:   "org.freedesktop.DBus",
+		dbusFieldMember:      "AddMatch",
+		dbusFieldDestination: "org.freedesktop.DBus",
+		dbusFieldSignature:   "s",
+	}
+	for code, value := range want {
+		if fields[code] != value {
+			t.Errorf("field %d = %q, want %q", code, fields[code], value)
+		}
+	}
+	if n := order.Uint32(body[:4]); string(body[4:4+n]) != prepareForSleepMatch {
+		t.Errorf("body = %q, want the match rule", body)
+	}
+	if wire.Len() != 0 {
+		t.Errorf("%d bytes left after the message", wire.Len())
+	}
+}
+
+func TestParseSleepSignal(t *testing.T) {
+	for _, sleeping := range []bool{true, false} {
+		msgType, fields, body, order, err := dbusReadMessage(bytes.NewReader(sleepSignal("PrepareForSleep", sleeping)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := parseSleepSignal(msgType, fields, body, order)
+		if !ok || got != sleeping {
+			t.Errorf("parseSleepSignal = %v, %v; want %v, true", got, ok, sleeping)
+		}
+	}
+
+	msgType, fields, body, order, err := dbusReadMessage(bytes.NewReader(sleepSignal("PrepareForShutdown", true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := parseSleepSignal(msgType, fields, body, order); ok {
+		t.Error("PrepareForShutdown taken for a sleep signal")
+	}
+	if _, ok := parseSleepSignal(dbusMessageSignal, map[byte]string{
+		dbusFieldInterface: "org.freedesktop.login1.Manager",
+		dbusFieldMember:    "PrepareForSleep",
+	}, nil, binary.LittleEndian); ok {
+		t.Error("signal without a body accepted")
+	}
+}
+
+func TestParseDBusFieldsStopsOnTruncation(t *testing.T) {
+	message := sleepSignal("PrepareForSleep", true)
+	fieldsLen := int(binary.LittleEndian.Uint32(message[12:16]))
+	fields := message[16 : 16+fieldsLen]
+
+	// Every prefix must parse without panicking and only yield complete fields
+	for n := 0; n <= len(fields); n++ {
+		parsed := parseDBusFields(fields[:n], binary.LittleEndian)
+		if member, ok := parsed[dbusFieldMember]; ok && member != "PrepareForSleep" {
+			t.Errorf("prefix %d: member = %q", n, member)
+		}
+	}
+}
+
+func TestDBusReadMessageRejectsOversizedBody(t *testing.T) {
+	message := sleepSignal("PrepareForSleep", true)
+	binary.LittleEndian.PutUint32(message[4:8], 1<<25)
+	if _, _, _, _, err := dbusReadMessage(bytes.NewReader(message)); err == nil {
+		t.Error("oversized message accepted")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+)
+
+// watchSleepSignals is only implemented for logind; other platforms rely on clock-jump detection
+func watchSleepSignals(ctx context.Context) (<-chan bool, error) {
+	return nil, fmt.Errorf("sleep notifications not supported on this platform")
+}
@@ -0,0 +1,13 @@
+package securestore
+
+import "syscall"
+
+// machineBinding returns the kernel's hardware UUID. Any local user can read it;
+// it identifies the machine, it is not a secret.
+func machineBinding() string {
+	uuid, err := syscall.Sysctl("kern.uuid")
+	if err != nil {
+		return ""
+	}
+	return uuid
+}
@@ -0,0 +1,19 @@
+package securestore
+
+import (
+	"os"
+	"strings"
+)
+
+// machineBinding returns the systemd/dbus machine ID. It is world-readable and
+// identifies the machine, it is not a secret.
+func machineBinding() string {
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(path); err == nil {
+			if id := strings.TrimSpace(string(data)); id != "" {
+				return id
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package securestore
+
+// machineBinding has no machine identity to bind to; the keyring relies on file permissions
+func machineBinding() string {
+	return ""
+}
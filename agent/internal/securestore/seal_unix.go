@@ -0,0 +1,89 @@
+//go:build !windows
+
+package securestore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+
+	"scanx/internal/utils"
+)
+
+// sealMagic prefixes a keyring sealed with the machine-bound key
+var sealMagic = []byte("SCXK1")
+
+// seal encrypts the keyring with a key derived from the machine's identity. That
+// identity is readable by any local user, so sealing only stops a copy of the
+// keyring from being unsealed on another machine (e.g. from a backup of the state
+// directory alone). On this machine the keyring is protected by nothing more than
+// its root-only permissions.
+func seal(plain []byte) ([]byte, error) {
+	aead, err := machineAEAD()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(append([]byte{}, sealMagic...), nonce...)
+	return aead.Seal(out, nonce, plain, sealMagic), nil
+}
+
+// unseal reverses seal
+func unseal(sealed []byte) ([]byte, error) {
+	aead, err := machineAEAD()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < len(sealMagic)+aead.NonceSize() || string(sealed[:len(sealMagic)]) != string(sealMagic) {
+		return nil, fmt.Errorf("not a sealed keyring")
+	}
+	nonce := sealed[len(sealMagic) : len(sealMagic)+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, sealed[len(sealMagic)+aead.NonceSize():], sealMagic)
+	if err != nil {
+		return nil, fmt.Errorf("keyring sealed on another machine or tampered with")
+	}
+	return plain, nil
+}
+
+// unboundWarning is logged once per process when there is no machine identity
+var unboundWarning sync.Once
+
+// machineAEAD derives the sealing cipher from the machine binding
+func machineAEAD() (cipher.AEAD, error) {
+	binding := machineBinding()
+	if binding == "" {
+		// The KEK is then a constant shipped in every binary: sealing adds nothing
+		unboundWarning.Do(func() {
+			utils.Warning("No machine ID found (e.g. /etc/machine-id is missing or empty): the state keyring is NOT bound to this machine and is protected only by file permissions")
+		})
+	}
+	kek := sha256.Sum256([]byte("scanx-state-kek-v1|" + binding))
+	block, err := aes.NewCipher(kek[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// checkKeyFileOwnership rejects a keyring readable by others or owned by another user
+func checkKeyFileOwnership(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Geteuid() {
+		return fmt.Errorf("keyring %s is owned by uid %d, expected %d", path, stat.Uid, os.Geteuid())
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("keyring %s has permissions %v, expected 0600", path, info.Mode().Perm())
+	}
+	return nil
+}
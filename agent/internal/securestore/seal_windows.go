@@ -0,0 +1,84 @@
+//go:build windows
+
+package securestore
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// DPAPI flags: never show UI, and protect for the machine so the service and
+// administrators running one-shot commands can both unseal the keyring
+const (
+	cryptProtectUIForbidden  = 0x1
+	cryptProtectLocalMachine = 0x4
+)
+
+var (
+	crypt32                = syscall.NewLazyDLL("crypt32.dll")
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+	procLocalFree          = kernel32.NewProc("LocalFree")
+
+	sealEntropy = []byte("scanx-state-kek-v1")
+)
+
+type dataBlob struct {
+	size uint32
+	data *byte
+}
+
+func newBlob(b []byte) *dataBlob {
+	if len(b) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{size: uint32(len(b)), data: &b[0]}
+}
+
+func (b *dataBlob) bytes() []byte {
+	out := make([]byte, b.size)
+	copy(out, unsafe.Slice(b.data, b.size))
+	return out
+}
+
+// seal protects the keyring with machine-scoped DPAPI so it cannot be read on another
+// machine. Any process on this machine can unseal it; locally the keyring is protected
+// by the state directory's ACL only.
+func seal(plain []byte) ([]byte, error) {
+	var out dataBlob
+	r, _, err := procCryptProtectData.Call(
+		uintptr(unsafe.Pointer(newBlob(plain))), 0,
+		uintptr(unsafe.Pointer(newBlob(sealEntropy))), 0, 0,
+		cryptProtectUIForbidden|cryptProtectLocalMachine,
+		uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, fmt.Errorf("CryptProtectData failed: %w", err)
+	}
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(out.data)))
+	return out.bytes(), nil
+}
+
+// unseal reverses seal
+func unseal(sealed []byte) ([]byte, error) {
+	var out dataBlob
+	r, _, err := procCryptUnprotectData.Call(
+		uintptr(unsafe.Pointer(newBlob(sealed))), 0,
+		uintptr(unsafe.Pointer(newBlob(sealEntropy))), 0, 0,
+		cryptProtectUIForbidden,
+		uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, fmt.Errorf("CryptUnprotectData failed: %w", err)
+	}
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(out.data)))
+	return out.bytes(), nil
+}
+
+// checkKeyFileOwnership only checks the keyring exists; access on Windows is
+// governed by the ProgramData ACLs and DPAPI
+func checkKeyFileOwnership(path string) error {
+	_, err := os.Stat(path)
+	return err
+}
@@ -0,0 +1,534 @@
+package securestore
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"scanx/internal/instance"
+	"scanx/internal/utils"
+)
+
+// keyringFileName is the sealed keyring under the state directory
+const keyringFileName = "state.key"
+
+// stateLockFile serializes read-modify-write updates across processes
+const stateLockFile = "state.lock"
+
+// stateLockTimeout bounds how long an update waits for another process
+const stateLockTimeout = 30 * time.Second
+
+// Encrypted file layout: magic | version | key id | nonce | AES-256-GCM ciphertext.
+// The header and the file's base name are authenticated as additional data, so a
+// file cannot be modified, truncated or swapped with another state file unnoticed.
+var fileMagic = []byte("SCXE")
+
+const (
+	fileVersion   = 1
+	keyIDSize     = 8
+	keySize       = 32
+	nonceSize     = 12
+	headerSize    = 4 + 1 + keyIDSize + nonceSize
+	retainRetired = 2 // retired keys kept for files outside the state directory
+)
+
+// Store encrypts agent state with a per-device keyring. The keyring is sealed to the
+// machine (see seal), which keeps copied state files unreadable elsewhere but adds
+// nothing against a local user who can already read the root-only state directory.
+type Store struct {
+	dir         string
+	keyringPath string
+
+	mu   sync.Mutex
+	ring *keyring
+}
+
+// keyring holds the active key and retired keys still accepted for reading, oldest first
+type keyring struct {
+	Active string       `json:"active"`
+	Keys   []keyringKey `json:"keys"`
+}
+
+type keyringKey struct {
+	ID        string `json:"id"`
+	Key       string `json:"key"`
+	CreatedAt string `json:"created_at"`
+}
+
+var (
+	storesMu sync.Mutex
+	stores   = make(map[string]*Store)
+)
+
+// Open returns the store for a state directory, loading or creating its keyring.
+// Stores are cached so every component in the process shares one keyring.
+func Open(stateDir string) (*Store, error) {
+	if stateDir == "" {
+		return nil, fmt.Errorf("no state directory")
+	}
+	dir := filepath.Clean(stateDir)
+
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	if store, ok := stores[dir]; ok {
+		return store, nil
+	}
+
+	store := &Store{dir: dir, keyringPath: filepath.Join(dir, keyringFileName)}
+	if err := store.loadKeyring(); err != nil {
+		return nil, err
+	}
+	stores[dir] = store
+	return store, nil
+}
+
+// ReadFile decrypts a state file. Missing files and files that cannot be
+// authenticated (tampered, or encrypted with a lost key) both return an error
+// satisfying errors.Is(err, fs.ErrNotExist); unreadable files are removed.
+// Plaintext files written before encryption existed are encrypted in place.
+func (s *Store) ReadFile(path string) ([]byte, error) {
+	return s.readFile(path, false)
+}
+
+// readFile is ReadFile; stateLocked tells it the caller already holds the state lock
+func (s *Store) readFile(path string, stateLocked bool) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, fileMagic) {
+		return s.migrate(path, stateLocked)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plaintext, err := s.decryptLocked(path, data)
+	if errors.Is(err, errUnknownKey) {
+		// Another process may have rotated the key since we loaded the keyring
+		if reloadErr := s.loadKeyringLocked(); reloadErr == nil {
+			plaintext, err = s.decryptLocked(path, data)
+		}
+	}
+	if err != nil {
+		return nil, s.discard(path, err)
+	}
+	return plaintext, nil
+}
+
+// migrate encrypts a plaintext state file in place and returns its contents. It
+// runs under the state lock so it never overwrites a concurrent migration or update.
+func (s *Store) migrate(path string, stateLocked bool) ([]byte, error) {
+	if !stateLocked {
+		lock, err := s.lockState()
+		if err != nil {
+			return nil, err
+		}
+		defer lock.Release()
+	}
+
+	// Another process may have migrated or replaced the file while we waited
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, fileMagic) {
+		return s.readFile(path, true)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writeLocked(path, data); err != nil {
+		utils.Warning("Failed to encrypt existing state file %s: %v", path, err)
+	} else {
+		utils.Info("Encrypted existing state file %s", path)
+	}
+	return data, nil
+}
+
+// lockState takes the inter-process lock on the state directory
+func (s *Store) lockState() (*instance.Lock, error) {
+	lock, err := instance.Acquire(context.Background(), s.dir, stateLockFile, "state", stateLockTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock state directory: %w", err)
+	}
+	return lock, nil
+}
+
+// WriteFile encrypts data with the active key and atomically replaces path
+func (s *Store) WriteFile(path string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeLocked(path, data)
+}
+
+// Update replaces a state file with fn's result while holding the state directory
+// lock, so processes updating the same file never lose each other's writes. fn
+// receives the decrypted contents, or nil if the file does not exist or was discarded.
+func (s *Store) Update(path string, fn func(current []byte) ([]byte, error)) error {
+	lock, err := s.lockState()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	current, err := s.readFile(path, true)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	next, err := fn(current)
+	if err != nil {
+		return err
+	}
+	return s.WriteFile(path, next)
+}
+
+// CreateFile is WriteFile that fails with fs.ErrExist if path already exists
+func (s *Store) CreateFile(path string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sealed, err := s.encryptLocked(path, data)
+	if err != nil {
+		return err
+	}
+	tmpPath, err := writeTemp(path, sealed)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	// Link fails if the target exists, making creation exclusive and atomic
+	return os.Link(tmpPath, path)
+}
+
+// KeyInfo returns the active key ID, its creation time and the number of retired keys
+func (s *Store) KeyInfo() (string, time.Time, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.ring.Keys {
+		if key.ID == s.ring.Active {
+			created, _ := time.Parse(time.RFC3339, key.CreatedAt)
+			return key.ID, created, len(s.ring.Keys) - 1
+		}
+	}
+	return s.ring.Active, time.Time{}, len(s.ring.Keys) - 1
+}
+
+// Rotate generates a new active key and re-encrypts every encrypted file in the
+// state directory with it. Older keys are retired; only the most recent are kept.
+// The state lock is held throughout so no other process rotates or updates meanwhile.
+func (s *Store) Rotate() error {
+	lock, err := s.lockState()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Start from the current keyring in case another process rotated since we loaded it
+	if err := s.loadKeyringLocked(); err != nil {
+		return fmt.Errorf("failed to reload keyring: %w", err)
+	}
+
+	newKey, err := generateKey()
+	if err != nil {
+		return err
+	}
+
+	// Persist the new key before re-encrypting so a crash never strands a file
+	s.ring.Keys = append(s.ring.Keys, newKey)
+	s.ring.Active = newKey.ID
+	if err := s.saveKeyringLocked(); err != nil {
+		return err
+	}
+
+	var failed int
+	walkErr := filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path == s.keyringPath {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || !bytes.HasPrefix(data, fileMagic) {
+			return nil
+		}
+		plaintext, err := s.decryptLocked(path, data)
+		if err != nil {
+			failed++
+			utils.Warning("Not re-encrypting unreadable state file %s: %v", path, err)
+			return nil
+		}
+		if err := s.writeLocked(path, plaintext); err != nil {
+			failed++
+			utils.Warning("Failed to re-encrypt %s: %v", path, err)
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return fmt.Errorf("failed to walk state directory: %w", walkErr)
+	}
+
+	// Keep a few retired keys for state stored outside the directory (e.g. custom dead-letter dirs)
+	if failed == 0 && len(s.ring.Keys) > retainRetired+1 {
+		s.ring.Keys = s.ring.Keys[len(s.ring.Keys)-retainRetired-1:]
+		if err := s.saveKeyringLocked(); err != nil {
+			return err
+		}
+	}
+
+	utils.Info("Rotated state encryption key to %s", newKey.ID)
+	return nil
+}
+
+// discard removes an unreadable state file so the owner starts from scratch
+func (s *Store) discard(path string, cause error) error {
+	utils.Warning("Discarding unreadable state file %s: %v", path, cause)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		utils.Warning("Failed to remove %s: %v", path, err)
+	}
+	return fmt.Errorf("state file %s discarded (%v): %w", path, cause, fs.ErrNotExist)
+}
+
+var errUnknownKey = errors.New("encrypted with an unknown key")
+
+// decryptLocked authenticates and decrypts an encrypted file
+func (s *Store) decryptLocked(path string, data []byte) ([]byte, error) {
+	if len(data) < headerSize || data[4] != fileVersion {
+		return nil, fmt.Errorf("unsupported or truncated encrypted file")
+	}
+
+	keyID := hex.EncodeToString(data[5 : 5+keyIDSize])
+	aead, err := s.aeadFor(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	header := data[:headerSize]
+	nonce := data[5+keyIDSize : headerSize]
+	plaintext, err := aead.Open(nil, nonce, data[headerSize:], additionalData(header, path))
+	if err != nil {
+		return nil, fmt.Errorf("authentication failed (file tampered or corrupt)")
+	}
+	return plaintext, nil
+}
+
+// encryptLocked seals data for path with the active key
+func (s *Store) encryptLocked(path string, data []byte) ([]byte, error) {
+	aead, err := s.aeadFor(s.ring.Active)
+	if err != nil {
+		return nil, err
+	}
+	keyID, _ := hex.DecodeString(s.ring.Active)
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, fileMagic...)
+	header = append(header, fileVersion)
+	header = append(header, keyID...)
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	header = append(header, nonce...)
+
+	return aead.Seal(header, nonce, data, additionalData(header, path)), nil
+}
+
+// writeLocked encrypts and atomically writes a state file
+func (s *Store) writeLocked(path string, data []byte) error {
+	sealed, err := s.encryptLocked(path, data)
+	if err != nil {
+		return err
+	}
+	tmpPath, err := writeTemp(path, sealed)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// aeadFor returns the AES-GCM cipher for a key ID in the keyring
+func (s *Store) aeadFor(keyID string) (cipher.AEAD, error) {
+	for _, key := range s.ring.Keys {
+		if key.ID != keyID {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(key.Key)
+		if err != nil || len(raw) != keySize {
+			return nil, fmt.Errorf("keyring entry %s is corrupt", keyID)
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	}
+	return nil, errUnknownKey
+}
+
+// loadKeyring loads the sealed keyring, creating a new one if it is missing or unusable
+func (s *Store) loadKeyring() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadKeyringLocked()
+}
+
+func (s *Store) loadKeyringLocked() error {
+	ring, err := readKeyring(s.keyringPath)
+	if err == nil {
+		s.ring = ring
+		return nil
+	}
+	if s.ring != nil {
+		// Keep the keyring we have rather than replacing it mid-run
+		return err
+	}
+	missing := os.IsNotExist(err)
+	if !missing {
+		utils.Warning("State encryption key unusable, existing encrypted state will be discarded: %v", err)
+	}
+
+	key, err := generateKey()
+	if err != nil {
+		return err
+	}
+	s.ring = &keyring{Active: key.ID, Keys: []keyringKey{key}}
+	if !missing {
+		return s.saveKeyringLocked()
+	}
+
+	// Create exclusively so two processes starting together agree on one keyring
+	if err := s.createKeyringLocked(); err != nil {
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		ring, readErr := readKeyring(s.keyringPath)
+		if readErr != nil {
+			return fmt.Errorf("failed to read keyring created by another process: %w", readErr)
+		}
+		s.ring = ring
+	}
+	return nil
+}
+
+// saveKeyringLocked seals and atomically replaces the keyring
+func (s *Store) saveKeyringLocked() error {
+	tmpPath, err := s.writeKeyringTemp()
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.keyringPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	return nil
+}
+
+// createKeyringLocked writes the keyring only if none exists yet
+func (s *Store) createKeyringLocked() error {
+	tmpPath, err := s.writeKeyringTemp()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	return os.Link(tmpPath, s.keyringPath)
+}
+
+// writeKeyringTemp seals the keyring into a temporary file next to the keyring path
+func (s *Store) writeKeyringTemp() (string, error) {
+	plain, err := json.Marshal(s.ring)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal keyring: %w", err)
+	}
+	sealed, err := seal(plain)
+	if err != nil {
+		return "", fmt.Errorf("failed to seal keyring: %w", err)
+	}
+	tmpPath, err := writeTemp(s.keyringPath, sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to write keyring: %w", err)
+	}
+	return tmpPath, nil
+}
+
+// readKeyring reads, checks and unseals the keyring file
+func readKeyring(path string) (*keyring, error) {
+	if err := checkKeyFileOwnership(path); err != nil {
+		return nil, err
+	}
+	sealed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := unseal(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal keyring: %w", err)
+	}
+
+	var ring keyring
+	if err := json.Unmarshal(plain, &ring); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring: %w", err)
+	}
+	if ring.Active == "" || len(ring.Keys) == 0 {
+		return nil, fmt.Errorf("keyring has no keys")
+	}
+	return &ring, nil
+}
+
+// generateKey creates a random AES-256 key with a random ID
+func generateKey() (keyringKey, error) {
+	raw := make([]byte, keySize+keyIDSize)
+	if _, err := rand.Read(raw); err != nil {
+		return keyringKey{}, fmt.Errorf("failed to generate state key: %w", err)
+	}
+	return keyringKey{
+		ID:        hex.EncodeToString(raw[keySize:]),
+		Key:       base64.StdEncoding.EncodeToString(raw[:keySize]),
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}, nil
+}
+
+// additionalData binds the ciphertext to its header and file name
+func additionalData(header []byte, path string) []byte {
+	return append(append([]byte{}, header...), filepath.Base(path)...)
+}
+
+// writeTemp writes data to a synced, owner-only temporary file next to path
+func writeTemp(path string, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+
+	_, writeErr := tmp.Write(data)
+	syncErr := tmp.Sync()
+	closeErr := tmp.Close()
+	if err := errors.Join(writeErr, syncErr, closeErr); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return tmpPath, nil
+}
@@ -0,0 +1,104 @@
+package securestore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openProcess opens a store bypassing the per-process cache, as another process would
+func openProcess(t *testing.T, dir string) *Store {
+	t.Helper()
+	store := &Store{dir: dir, keyringPath: filepath.Join(dir, keyringFileName)}
+	if err := store.loadKeyring(); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestPlaintextStateMigratedByAnyProcess(t *testing.T) {
+	dir := t.TempDir()
+	openProcess(t, dir) // creates the keyring
+
+	path := filepath.Join(dir, "legacy.json")
+	if err := os.WriteFile(path, []byte(`{"old":true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A process that did not create the keyring must still keep the old state
+	data, err := openProcess(t, dir).ReadFile(path)
+	if err != nil {
+		t.Fatalf("plaintext state discarded: %v", err)
+	}
+	if string(data) != `{"old":true}` {
+		t.Fatalf("got %s", data)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(raw, fileMagic) {
+		t.Fatal("plaintext state was not encrypted in place")
+	}
+	if data, err := openProcess(t, dir).ReadFile(path); err != nil || string(data) != `{"old":true}` {
+		t.Fatalf("migrated state unreadable: %s, %v", data, err)
+	}
+}
+
+func TestUpdateMigratesPlaintext(t *testing.T) {
+	dir := t.TempDir()
+	store := openProcess(t, dir)
+	path := filepath.Join(dir, "counter")
+	if err := os.WriteFile(path, []byte("41"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Update holds the state lock; migration must not try to take it again
+	err := store.Update(path, func(current []byte) ([]byte, error) {
+		if string(current) != "41" {
+			t.Errorf("current = %q, want 41", current)
+		}
+		return []byte("42"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := store.ReadFile(path); err != nil || string(data) != "42" {
+		t.Fatalf("got %s, %v", data, err)
+	}
+}
+
+func TestRotateKeepsStateReadableByOtherProcesses(t *testing.T) {
+	dir := t.TempDir()
+	first := openProcess(t, dir)
+	other := openProcess(t, dir)
+
+	path := filepath.Join(dir, "state.json")
+	if err := first.WriteFile(path, []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	oldKey, _, _ := first.KeyInfo()
+
+	if err := first.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	newKey, _, _ := first.KeyInfo()
+	if newKey == oldKey {
+		t.Fatal("active key did not change")
+	}
+
+	// other still has the old keyring and must pick up the rotated one
+	if data, err := other.ReadFile(path); err != nil || string(data) != "v1" {
+		t.Fatalf("after rotation: %s, %v", data, err)
+	}
+
+	// Rotating from a stale keyring must not drop the key the first rotation added
+	if err := other.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := first.ReadFile(path); err != nil || string(data) != "v1" {
+		t.Fatalf("after second rotation: %s, %v", data, err)
+	}
+}
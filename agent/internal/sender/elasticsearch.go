@@ -0,0 +1,157 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"scanx/internal/collector"
+	"scanx/internal/config"
+)
+
+const (
+	defaultElasticsearchIndex = "scanx-posture"
+	// maxBulkBatchBytes keeps bulk requests near the recommended 5-15 MB range
+	maxBulkBatchBytes = 5 * 1024 * 1024
+)
+
+// ElasticsearchSink indexes one document per result row through the _bulk API.
+// Document IDs are derived from the report so retried batches do not duplicate data.
+type ElasticsearchSink struct {
+	name       string
+	url        string
+	token      string
+	index      string
+	httpClient *http.Client
+}
+
+// bulkResponse is the subset of the _bulk response used to detect item failures
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// NewElasticsearchSink creates an Elasticsearch/OpenSearch bulk sink. Token, if set,
+// is sent as an API key; credentials in the URL are used for basic auth.
+func NewElasticsearchSink(sinkConfig config.SinkConfig) (*ElasticsearchSink, error) {
+	if sinkConfig.URL == "" {
+		return nil, fmt.Errorf("elasticsearch sink requires a url")
+	}
+
+	index := sinkConfig.Index
+	if index == "" {
+		index = defaultElasticsearchIndex
+	}
+
+	return &ElasticsearchSink{
+		name:       sinkConfig.GetName(),
+		url:        strings.TrimRight(sinkConfig.URL, "/") + "/_bulk",
+		token:      sinkConfig.Token,
+		index:      index,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// Name returns the sink name
+func (s *ElasticsearchSink) Name() string {
+	return s.name
+}
+
+// Send indexes the report's events in bulk batches
+func (s *ElasticsearchSink) Send(ctx context.Context, data *collector.CollectedData) error {
+	timestamp := reportTime(data)
+
+	var batch bytes.Buffer
+	for i, event := range reportEvents(data) {
+		event["@timestamp"] = timestamp.UTC().Format("2006-01-02T15:04:05.000Z")
+
+		action, err := json.Marshal(map[string]interface{}{
+			"index": map[string]string{"_index": s.index, "_id": documentID(data, event["query"], i)},
+		})
+		if err != nil {
+			return permanent(fmt.Errorf("failed to marshal bulk action: %w", err))
+		}
+		doc, err := json.Marshal(event)
+		if err != nil {
+			return permanent(fmt.Errorf("failed to marshal bulk document: %w", err))
+		}
+
+		if batch.Len() > 0 && batch.Len()+len(action)+len(doc)+2 > maxBulkBatchBytes {
+			if err := s.post(ctx, batch.Bytes()); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		batch.Write(action)
+		batch.WriteByte('\n')
+		batch.Write(doc)
+		batch.WriteByte('\n')
+	}
+
+	if batch.Len() == 0 {
+		return nil
+	}
+	return s.post(ctx, batch.Bytes())
+}
+
+// post sends one bulk batch and fails if any item was rejected
+func (s *ElasticsearchSink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.token != "" {
+		req.Header.Set("Authorization", "ApiKey "+s.token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send to Elasticsearch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError("Elasticsearch", resp.StatusCode)
+	}
+
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse bulk response: %w", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+
+	failed := 0
+	var firstReason string
+	for _, item := range result.Items {
+		for _, outcome := range item {
+			if outcome.Status >= 300 {
+				failed++
+				if firstReason == "" {
+					firstReason = fmt.Sprintf("%s: %s", outcome.Error.Type, outcome.Error.Reason)
+				}
+			}
+		}
+	}
+	// Retrying is safe: successful items are overwritten in place thanks to stable IDs
+	return fmt.Errorf("elasticsearch rejected %d of %d documents (%s)", failed, len(result.Items), firstReason)
+}
+
+// documentID derives a stable ID for a row of a specific report
+func documentID(data *collector.CollectedData, query interface{}, position int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%v|%d", data.DeviceID, data.Timestamp, query, position)))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,76 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Enrollment pre-check failures, matching the backend's report rejections
+var (
+	ErrUserNotFound     = errors.New("email not found in the company directory")
+	ErrEmailInUse       = errors.New("email is already associated with another device")
+	ErrServiceAccount   = errors.New("email belongs to a service account, not a user")
+	ErrCheckUnsupported = errors.New("backend does not support enrollment checks")
+	ErrCheckRateLimited = errors.New("too many enrollment checks, try again later")
+)
+
+// EnrollmentCheck is the backend's answer to an enrollment pre-check
+type EnrollmentCheck struct {
+	Message string `json:"message"`
+	// Name is the user's display name from the directory, only returned when the
+	// device is already enrolled to that user
+	Name string `json:"name,omitempty"`
+	// Registered is true when the backend already knows this device
+	Registered bool `json:"registered"`
+}
+
+// CheckEnrollment asks the backend whether a report for email and serialNo would
+// be accepted, without registering anything
+func (s *BackendSender) CheckEnrollment(ctx context.Context, email, serialNo string) (*EnrollmentCheck, error) {
+	payload, err := json.Marshal(map[string]string{"user_email": email, "serial_no": serialNo})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal enrollment check: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/devices/agent/enroll/check", s.baseURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.userAgent)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to backend: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var check EnrollmentCheck
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	json.Unmarshal(body, &check)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return &check, nil
+	case http.StatusNotFound:
+		// Backends without the endpoint answer with their generic 404
+		if check.Message == "Endpoint not found" {
+			return nil, ErrCheckUnsupported
+		}
+		return nil, ErrUserNotFound
+	case http.StatusConflict:
+		return nil, ErrEmailInUse
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, ErrServiceAccount
+	case http.StatusTooManyRequests:
+		return nil, ErrCheckRateLimited
+	default:
+		return nil, fmt.Errorf("enrollment check failed with status %d: %s", resp.StatusCode, check.Message)
+	}
+}
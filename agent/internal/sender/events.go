@@ -0,0 +1,75 @@
+package sender
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"scanx/internal/collector"
+)
+
+// eventSource identifies scanx events in SIEM sinks
+const eventSource = "scanx"
+
+// reportEvents flattens a report into one event per result row, which is the
+// shape SIEMs index best. Each event carries the device context it came from.
+func reportEvents(data *collector.CollectedData) []map[string]interface{} {
+	queryNames := make([]string, 0, len(data.Data))
+	for queryName := range data.Data {
+		queryNames = append(queryNames, queryName)
+	}
+	sort.Strings(queryNames)
+
+	var events []map[string]interface{}
+	for _, queryName := range queryNames {
+		for _, row := range data.Data[queryName] {
+			events = append(events, deviceEvent(data, queryName, row))
+		}
+	}
+	// Installed software and findings are indexed like rows of queries of those names
+	for _, item := range data.Software {
+		events = append(events, deviceEvent(data, "software", item))
+	}
+	for _, finding := range data.Vulnerabilities {
+		events = append(events, deviceEvent(data, "vulnerabilities", finding))
+	}
+	for _, finding := range data.ExtensionFindings {
+		events = append(events, deviceEvent(data, "extension_findings", finding))
+	}
+	return events
+}
+
+// deviceEvent wraps one row with the device context it came from
+func deviceEvent(data *collector.CollectedData, queryName string, row interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"device_id":     data.DeviceID,
+		"user":          data.User,
+		"computer_name": data.ComputerName,
+		"os_type":       data.OSType,
+		"os_version":    data.OSVersion,
+		"serial_no":     data.SerialNo,
+		"agent_version": data.Version,
+		"collected_at":  data.Timestamp,
+		"query":         queryName,
+		"row":           row,
+	}
+}
+
+// reportTime returns the collection time of a report, or now if it cannot be parsed
+func reportTime(data *collector.CollectedData) time.Time {
+	if t, err := time.Parse(time.RFC3339, data.Timestamp); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+// statusError describes a non-success HTTP status; client errors other than
+// 408 and 429 are permanent because resending the same payload cannot succeed
+func statusError(target string, statusCode int) error {
+	err := fmt.Errorf("%s returned error status: %d", target, statusCode)
+	if statusCode >= 400 && statusCode < 500 && statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests {
+		return permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"scanx/internal/collector"
+	"scanx/internal/config"
+)
+
+// FileSink appends each report as one JSON line to a local file, rotating it
+// to path.1 .. path.N once it reaches the configured size
+type FileSink struct {
+	name     string
+	path     string
+	maxSize  int64
+	maxFiles int
+	mu       sync.Mutex
+}
+
+// NewFileSink creates a rotating NDJSON file sink
+func NewFileSink(sinkConfig config.SinkConfig) (*FileSink, error) {
+	if sinkConfig.Path == "" {
+		return nil, fmt.Errorf("file sink requires a path")
+	}
+	if err := os.MkdirAll(filepath.Dir(sinkConfig.Path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", sinkConfig.Path, err)
+	}
+
+	return &FileSink{
+		name:     sinkConfig.GetName(),
+		path:     sinkConfig.Path,
+		maxSize:  sinkConfig.GetMaxSizeBytes(),
+		maxFiles: sinkConfig.GetMaxFiles(),
+	}, nil
+}
+
+// Name returns the sink name
+func (s *FileSink) Name() string {
+	return s.name
+}
+
+// Send appends the report as a single line
+func (s *FileSink) Send(ctx context.Context, data *collector.CollectedData) error {
+	line, err := json.Marshal(data)
+	if err != nil {
+		return permanent(fmt.Errorf("failed to marshal report: %w", err))
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if info, err := os.Stat(s.path); err == nil && info.Size() > 0 && info.Size()+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate %s: %w", s.path, err)
+		}
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", s.path, err)
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+	return file.Close()
+}
+
+// rotate shifts path.N-1 -> path.N, ..., path -> path.1, dropping the oldest file
+func (s *FileSink) rotate() error {
+	oldest := fmt.Sprintf("%s.%d", s.path, s.maxFiles)
+	if err := os.Remove(oldest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for i := s.maxFiles - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", s.path, i)
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", s.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Rename(s.path, s.path+".1")
+}
@@ -2,6 +2,7 @@ package sender
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,14 +10,22 @@ import (
 
 	"scanx/internal/collector"
 	"scanx/internal/config"
+	"scanx/internal/signing"
 	"scanx/internal/utils"
 )
 
+// HeaderReportSignature carries the base64-encoded detached signature of a report
+// imported with `scanx send --file`, so the backend can check where it came from
+const HeaderReportSignature = "X-Scanx-Report-Signature"
+
 // BackendSender handles sending data to the MDM backend
 type BackendSender struct {
-	baseURL    string
-	httpClient *http.Client
-	userAgent  string
+	name            string
+	baseURL         string
+	httpClient      *http.Client
+	userAgent       string
+	signer          *signing.Signer
+	reportSignature string
 }
 
 // SendResponse represents the backend response
@@ -29,6 +38,7 @@ type SendResponse struct {
 // NewBackendSender creates a new backend sender
 func NewBackendSender(baseURL string) *BackendSender {
 	return &BackendSender{
+		name:    config.SinkTypeBackend,
 		baseURL: baseURL,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
@@ -37,24 +47,57 @@ func NewBackendSender(baseURL string) *BackendSender {
 	}
 }
 
+// SetSigner enables ed25519 payload signing for every report sent
+func (s *BackendSender) SetSigner(signer *signing.Signer) {
+	s.signer = signer
+}
+
+// SetReportSignature forwards a report's detached signature with every report sent
+func (s *BackendSender) SetReportSignature(encoded string) {
+	s.reportSignature = encoded
+}
+
+// Name returns the sink name of the backend
+func (s *BackendSender) Name() string {
+	return s.name
+}
+
+// Send delivers a report to the backend, implementing Sink
+func (s *BackendSender) Send(ctx context.Context, data *collector.CollectedData) error {
+	return s.SendAgentData(ctx, data)
+}
+
 // SendAgentData sends collected data to the backend
-func (s *BackendSender) SendAgentData(data *collector.CollectedData) error {
+func (s *BackendSender) SendAgentData(ctx context.Context, data *collector.CollectedData) error {
 	// Prepare the payload
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("failed to marshal agent data: %w", err)
 	}
 
+	// Sign the canonical payload so the backend can detect forgeries and replays
+	header := make(http.Header)
+	if s.signer != nil {
+		jsonData, err = s.signer.SignRequest(header, jsonData)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Create the request
 	url := fmt.Sprintf("%s/api/devices/agent/report", s.baseURL)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header = header
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", s.userAgent)
+	if s.reportSignature != "" {
+		req.Header.Set(HeaderReportSignature, s.reportSignature)
+	}
 
 	utils.Debug("Sending agent data to backend: %s", url)
 	utils.Debug("Payload size: %d bytes", len(jsonData))
@@ -68,7 +111,7 @@ func (s *BackendSender) SendAgentData(data *collector.CollectedData) error {
 
 	// Check response status
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("backend returned error status: %d", resp.StatusCode)
+		return statusError("backend", resp.StatusCode)
 	}
 
 	// Parse response
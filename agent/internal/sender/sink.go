@@ -0,0 +1,245 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"scanx/internal/collector"
+	"scanx/internal/config"
+	"scanx/internal/signing"
+	"scanx/internal/utils"
+)
+
+// Sink is an output that collected reports are delivered to
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, data *collector.CollectedData) error
+}
+
+// ConnectionTester is implemented by sinks that can check reachability without sending data
+type ConnectionTester interface {
+	TestConnection() error
+}
+
+// DeadLetterer is implemented by sinks that keep deliveries which failed all retries
+type DeadLetterer interface {
+	DeadLetter(data *collector.CollectedData, cause error)
+}
+
+// ReportSignatureForwarder is implemented by sinks that can pass on the detached
+// signature of an imported report
+type ReportSignatureForwarder interface {
+	SetReportSignature(encoded string)
+}
+
+// permanentError marks a delivery failure that retrying cannot fix (e.g. HTTP 400)
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent wraps err so the dispatcher does not retry it
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// dispatchTarget is a sink together with its delivery policy
+type dispatchTarget struct {
+	sink    Sink
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+}
+
+// Dispatcher delivers each report to every configured sink. Sinks are isolated:
+// each has its own timeout and retries, and one failing sink never blocks the others.
+type Dispatcher struct {
+	targets []dispatchTarget
+}
+
+// NewDispatcherFromConfig builds the sinks configured in agent.conf. Without any
+// sinks configured the REST backend is used, preserving the original behaviour.
+func NewDispatcherFromConfig(cfg *config.Config) *Dispatcher {
+	sinkConfigs := cfg.Agent.Sinks
+	if len(sinkConfigs) == 0 {
+		sinkConfigs = []config.SinkConfig{{Type: config.SinkTypeBackend}}
+	}
+
+	// Payloads are signed with the device key; without it they are sent unsigned
+	var signer *signing.Signer
+	if stateDir, err := utils.EnsureStateDir(); err != nil {
+		utils.Warning("Payload signing disabled: %v", err)
+	} else if signer, err = signing.NewSigner(stateDir); err != nil {
+		utils.Warning("Payload signing disabled: %v", err)
+	}
+
+	d := &Dispatcher{}
+	for _, sinkConfig := range sinkConfigs {
+		sink, err := newSink(cfg, sinkConfig, signer)
+		if err != nil {
+			utils.Warning("Skipping sink '%s': %v", sinkConfig.GetName(), err)
+			continue
+		}
+		d.targets = append(d.targets, dispatchTarget{
+			sink:    sink,
+			timeout: sinkConfig.GetTimeout(),
+			retries: sinkConfig.GetRetries(),
+			backoff: sinkConfig.GetRetryBackoff(),
+		})
+		utils.Info("Configured %s sink '%s'", sinkConfig.Type, sink.Name())
+	}
+
+	if len(d.targets) == 0 {
+		utils.Warning("No valid sinks configured, falling back to the REST backend")
+		backend := config.SinkConfig{Type: config.SinkTypeBackend}
+		sink, _ := newSink(cfg, backend, signer)
+		d.targets = append(d.targets, dispatchTarget{
+			sink:    sink,
+			timeout: backend.GetTimeout(),
+			retries: backend.GetRetries(),
+			backoff: backend.GetRetryBackoff(),
+		})
+	}
+
+	return d
+}
+
+// ForwardReportSignature hands a report's encoded detached signature to every sink
+// that can pass it on, and returns how many sinks will
+func (d *Dispatcher) ForwardReportSignature(encoded string) int {
+	forwarded := 0
+	for _, target := range d.targets {
+		if forwarder, ok := target.sink.(ReportSignatureForwarder); ok {
+			forwarder.SetReportSignature(encoded)
+			forwarded++
+		}
+	}
+	return forwarded
+}
+
+// newSink constructs a sink from its configuration
+func newSink(cfg *config.Config, sinkConfig config.SinkConfig, signer *signing.Signer) (Sink, error) {
+	hostname, _ := os.Hostname()
+
+	switch sinkConfig.Type {
+	case config.SinkTypeBackend:
+		url := sinkConfig.URL
+		if url == "" {
+			url = GetBackendURLFromConfig(cfg)
+		}
+		backend := NewBackendSender(url)
+		backend.name = sinkConfig.GetName()
+		backend.SetSigner(signer)
+		return backend, nil
+	case config.SinkTypeFile:
+		return NewFileSink(sinkConfig)
+	case config.SinkTypeSyslog:
+		return NewSyslogSink(sinkConfig, hostname)
+	case config.SinkTypeSplunkHEC:
+		return NewSplunkHECSink(sinkConfig, hostname)
+	case config.SinkTypeElasticsearch:
+		return NewElasticsearchSink(sinkConfig)
+	case config.SinkTypeWebhook:
+		return NewWebhookSink(sinkConfig, signer, complianceQueries(cfg))
+	case "":
+		return nil, fmt.Errorf("sink type is required")
+	default:
+		return nil, fmt.Errorf("unknown sink type '%s'", sinkConfig.Type)
+	}
+}
+
+// Sinks returns the names of the configured sinks
+func (d *Dispatcher) Sinks() []string {
+	names := make([]string, 0, len(d.targets))
+	for _, target := range d.targets {
+		names = append(names, target.sink.Name())
+	}
+	return names
+}
+
+// Send delivers the report to all sinks concurrently and returns an error
+// naming every sink that still failed after its retries
+func (d *Dispatcher) Send(ctx context.Context, data *collector.CollectedData) error {
+	errs := make([]error, len(d.targets))
+
+	var wg sync.WaitGroup
+	for i, target := range d.targets {
+		wg.Add(1)
+		go func(i int, target dispatchTarget) {
+			defer wg.Done()
+			if err := target.deliver(ctx, data); err != nil {
+				utils.Error("❌ Sink '%s' failed: %v", target.sink.Name(), err)
+				errs[i] = fmt.Errorf("sink '%s': %w", target.sink.Name(), err)
+				if deadLetterer, ok := target.sink.(DeadLetterer); ok {
+					deadLetterer.DeadLetter(data, err)
+				}
+				return
+			}
+			utils.Info("✅ Delivered report to sink '%s'", target.sink.Name())
+		}(i, target)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// TestConnection succeeds if any sink that supports connection tests is reachable,
+// or if no sink supports them
+func (d *Dispatcher) TestConnection() error {
+	var errs []error
+	for _, target := range d.targets {
+		tester, ok := target.sink.(ConnectionTester)
+		if !ok {
+			continue
+		}
+		err := tester.TestConnection()
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("sink '%s': %w", target.sink.Name(), err))
+	}
+	return errors.Join(errs...)
+}
+
+// deliver sends to one sink, retrying transient failures with exponential back-off
+func (t dispatchTarget) deliver(ctx context.Context, data *collector.CollectedData) (err error) {
+	backoff := t.backoff
+	for attempt := 0; ; attempt++ {
+		err = t.attempt(ctx, data)
+		if err == nil {
+			return nil
+		}
+
+		var permanentErr *permanentError
+		if errors.As(err, &permanentErr) || attempt >= t.retries || ctx.Err() != nil {
+			return err
+		}
+
+		utils.Warning("Sink '%s' attempt %d failed, retrying in %v: %v", t.sink.Name(), attempt+1, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// attempt runs a single delivery under the sink's timeout, converting panics to errors
+func (t dispatchTarget) attempt(ctx context.Context, data *collector.CollectedData) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = permanent(fmt.Errorf("sink panicked: %v", r))
+		}
+	}()
+
+	attemptCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.sink.Send(attemptCtx, data)
+}
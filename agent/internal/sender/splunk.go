@@ -0,0 +1,125 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"scanx/internal/collector"
+	"scanx/internal/config"
+)
+
+// maxHECBatchBytes keeps each HEC request well under Splunk's default 1 MB limit
+const maxHECBatchBytes = 800 * 1024
+
+// SplunkHECSink posts one event per result row to a Splunk HTTP Event Collector
+type SplunkHECSink struct {
+	name       string
+	url        string
+	token      string
+	index      string
+	sourceType string
+	host       string
+	httpClient *http.Client
+}
+
+// NewSplunkHECSink creates a Splunk HEC sink. A bare base URL gets the
+// standard /services/collector/event endpoint appended.
+func NewSplunkHECSink(sinkConfig config.SinkConfig, hostname string) (*SplunkHECSink, error) {
+	if sinkConfig.URL == "" {
+		return nil, fmt.Errorf("splunk_hec sink requires a url")
+	}
+	if sinkConfig.Token == "" {
+		return nil, fmt.Errorf("splunk_hec sink requires a token")
+	}
+
+	url := strings.TrimRight(sinkConfig.URL, "/")
+	if !strings.Contains(url, "/services/collector") {
+		url += "/services/collector/event"
+	}
+
+	sourceType := sinkConfig.SourceType
+	if sourceType == "" {
+		sourceType = "scanx:posture"
+	}
+
+	return &SplunkHECSink{
+		name:       sinkConfig.GetName(),
+		url:        url,
+		token:      sinkConfig.Token,
+		index:      sinkConfig.Index,
+		sourceType: sourceType,
+		host:       hostname,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+// Name returns the sink name
+func (s *SplunkHECSink) Name() string {
+	return s.name
+}
+
+// Send posts the report's events in batches
+func (s *SplunkHECSink) Send(ctx context.Context, data *collector.CollectedData) error {
+	eventTime := float64(reportTime(data).UnixMilli()) / 1000
+
+	var batch bytes.Buffer
+	for _, event := range reportEvents(data) {
+		envelope := map[string]interface{}{
+			"time":       eventTime,
+			"source":     eventSource,
+			"sourcetype": s.sourceType,
+			"event":      event,
+		}
+		if s.host != "" {
+			envelope["host"] = s.host
+		}
+		if s.index != "" {
+			envelope["index"] = s.index
+		}
+
+		line, err := json.Marshal(envelope)
+		if err != nil {
+			return permanent(fmt.Errorf("failed to marshal HEC event: %w", err))
+		}
+		if batch.Len() > 0 && batch.Len()+len(line) > maxHECBatchBytes {
+			if err := s.post(ctx, batch.Bytes()); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		batch.Write(line)
+		batch.WriteByte('\n')
+	}
+
+	if batch.Len() == 0 {
+		return nil
+	}
+	return s.post(ctx, batch.Bytes())
+}
+
+// post sends one batch of concatenated HEC events
+func (s *SplunkHECSink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Authorization", "Splunk "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send to Splunk HEC: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError("Splunk HEC", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"scanx/internal/collector"
+	"scanx/internal/config"
+	"scanx/internal/utils"
+)
+
+// RFC 5424 constants: facility local0 (16), severity informational (6)
+const (
+	syslogPriority = 16*8 + 6
+	syslogAppName  = "scanx"
+	syslogMsgID    = "posture"
+	// syslogSDID uses the documentation enterprise number reserved by RFC 5612
+	syslogSDID = "scanx@32473"
+	// maxUDPMessage keeps datagrams under the usual 64 KiB UDP payload limit
+	maxUDPMessage = 65000
+)
+
+// SyslogSink sends one RFC 5424 message per result row over TCP or UDP.
+// TCP uses octet-counting framing (RFC 6587) so messages may contain newlines.
+type SyslogSink struct {
+	name     string
+	network  string
+	address  string
+	hostname string
+}
+
+// NewSyslogSink creates a syslog sink; network defaults to udp
+func NewSyslogSink(sinkConfig config.SinkConfig, hostname string) (*SyslogSink, error) {
+	network := strings.ToLower(sinkConfig.Network)
+	if network == "" {
+		network = "udp"
+	}
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("syslog network must be tcp or udp, got '%s'", sinkConfig.Network)
+	}
+	if _, _, err := net.SplitHostPort(sinkConfig.Address); err != nil {
+		return nil, fmt.Errorf("syslog sink requires address host:port: %w", err)
+	}
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	return &SyslogSink{
+		name:     sinkConfig.GetName(),
+		network:  network,
+		address:  sinkConfig.Address,
+		hostname: hostname,
+	}, nil
+}
+
+// Name returns the sink name
+func (s *SyslogSink) Name() string {
+	return s.name
+}
+
+// Send writes every result row of the report as a syslog message
+func (s *SyslogSink) Send(ctx context.Context, data *collector.CollectedData) error {
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog %s: %w", s.address, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	timestamp := reportTime(data)
+	for _, event := range reportEvents(data) {
+		msg, err := s.format(timestamp, data.DeviceID, event)
+		if err != nil {
+			return permanent(err)
+		}
+
+		if s.network == "udp" {
+			if len(msg) > maxUDPMessage {
+				utils.Warning("Dropping %d byte syslog message for query '%s': too large for UDP", len(msg), event["query"])
+				continue
+			}
+		} else {
+			msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+		}
+
+		if _, err := conn.Write(msg); err != nil {
+			return fmt.Errorf("failed to write to syslog %s: %w", s.address, err)
+		}
+	}
+	return nil
+}
+
+// format renders an RFC 5424 message with the event as JSON in the MSG part
+func (s *SyslogSink) format(timestamp time.Time, deviceID string, event map[string]interface{}) ([]byte, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal syslog event: %w", err)
+	}
+
+	structured := fmt.Sprintf(`[%s device_id="%s" query="%s"]`, syslogSDID, escapeSDParam(deviceID), escapeSDParam(fmt.Sprint(event["query"])))
+	header := fmt.Sprintf("<%d>1 %s %s %s - %s %s ",
+		syslogPriority,
+		timestamp.Format(time.RFC3339),
+		syslogHeaderField(s.hostname, 255),
+		syslogAppName,
+		syslogMsgID,
+		structured,
+	)
+	return append([]byte(header), body...), nil
+}
+
+// escapeSDParam escapes the characters RFC 5424 reserves in structured data values
+func escapeSDParam(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// syslogHeaderField restricts a header field to printable ASCII without spaces
+func syslogHeaderField(value string, maxLen int) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r > 32 && r < 127 {
+			b.WriteRune(r)
+		}
+	}
+	field := b.String()
+	if field == "" {
+		return "-"
+	}
+	if len(field) > maxLen {
+		field = field[:maxLen]
+	}
+	return field
+}
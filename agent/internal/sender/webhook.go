@@ -0,0 +1,499 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"scanx/internal/collector"
+	"scanx/internal/config"
+	"scanx/internal/extensions"
+	"scanx/internal/securestore"
+	"scanx/internal/signing"
+	"scanx/internal/utils"
+)
+
+// Webhook delivery headers. The signature is HMAC-SHA256 over "<timestamp>.<body>"
+// so receivers can reject replays by checking the timestamp.
+const (
+	WebhookSignatureHeader      = "X-Scanx-Signature"
+	WebhookTimestampHeader      = "X-Scanx-Timestamp"
+	WebhookIdempotencyKeyHeader = "Idempotency-Key"
+	WebhookEventHeader          = "X-Scanx-Event"
+)
+
+// maxDeadLetters bounds the dead-letter directory; the oldest entries are dropped first
+const maxDeadLetters = 500
+
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// WebhookSink POSTs signed reports, or only changed compliance results, to an arbitrary URL
+type WebhookSink struct {
+	name          string
+	url           string
+	secret        []byte
+	mode          string
+	deadLetterDir string
+	store         *securestore.Store
+	statePath     string
+	httpClient    *http.Client
+	signer        *signing.Signer
+	compliance    map[string]bool // queries whose changes are delivered (changes mode)
+
+	mu     sync.Mutex
+	hashes map[string]string // query name -> result hash last delivered (changes mode)
+}
+
+// webhookDelivery is a prepared payload; its idempotency key is stable across retries
+type webhookDelivery struct {
+	idempotencyKey string
+	body           []byte
+	hashes         map[string]string
+}
+
+// deadLetter is a delivery that failed all retries, stored for later redelivery
+type deadLetter struct {
+	URL            string          `json:"url"`
+	Event          string          `json:"event"`
+	IdempotencyKey string          `json:"idempotency_key"`
+	FailedAt       string          `json:"failed_at"`
+	Error          string          `json:"error"`
+	Body           json.RawMessage `json:"body"`
+}
+
+// queryChange is one changed query in a changes-mode payload
+type queryChange struct {
+	Query        string                   `json:"query"`
+	Status       string                   `json:"status"`
+	PreviousHash string                   `json:"previous_hash,omitempty"`
+	CurrentHash  string                   `json:"current_hash"`
+	Rows         []map[string]interface{} `json:"rows"`
+}
+
+// NewWebhookSink creates a webhook sink. Mode defaults to full reports and
+// dead letters default to a per-sink directory under the state directory. In
+// changes mode only the compliance queries and the findings are compared.
+func NewWebhookSink(sinkConfig config.SinkConfig, signer *signing.Signer, compliance map[string]bool) (*WebhookSink, error) {
+	if sinkConfig.URL == "" {
+		return nil, fmt.Errorf("webhook sink requires a url")
+	}
+	if sinkConfig.Secret == "" {
+		return nil, fmt.Errorf("webhook sink requires a secret for HMAC signing")
+	}
+
+	mode := sinkConfig.Mode
+	if mode == "" {
+		mode = config.WebhookModeReport
+	}
+	if mode != config.WebhookModeReport && mode != config.WebhookModeChanges {
+		return nil, fmt.Errorf("webhook mode must be '%s' or '%s', got '%s'", config.WebhookModeReport, config.WebhookModeChanges, mode)
+	}
+
+	stateDir, err := utils.EnsureStateDir()
+	if err != nil {
+		return nil, err
+	}
+	store, err := securestore.Open(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	safeName := unsafeNameChars.ReplaceAllString(sinkConfig.GetName(), "_")
+
+	deadLetterDir := sinkConfig.DeadLetterDir
+	if deadLetterDir == "" {
+		deadLetterDir = filepath.Join(stateDir, "deadletter", safeName)
+	}
+	if err := os.MkdirAll(deadLetterDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+
+	s := &WebhookSink{
+		name:          sinkConfig.GetName(),
+		url:           sinkConfig.URL,
+		secret:        []byte(sinkConfig.Secret),
+		mode:          mode,
+		deadLetterDir: deadLetterDir,
+		store:         store,
+		statePath:     filepath.Join(stateDir, "webhook-"+safeName+".json"),
+		httpClient:    &http.Client{},
+		signer:        signer,
+		compliance:    compliance,
+		hashes:        make(map[string]string),
+	}
+
+	if data, err := store.ReadFile(s.statePath); err == nil {
+		if err := json.Unmarshal(data, &s.hashes); err != nil {
+			utils.Warning("Ignoring unreadable webhook state %s: %v", s.statePath, err)
+			s.hashes = make(map[string]string)
+		}
+	}
+
+	return s, nil
+}
+
+// Name returns the sink name
+func (s *WebhookSink) Name() string {
+	return s.name
+}
+
+// Send redelivers any dead letters, oldest first, and then the report, so the
+// receiver sees deliveries in the order they were collected
+func (s *WebhookSink) Send(ctx context.Context, data *collector.CollectedData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.redeliverDeadLetters(ctx); err != nil {
+		return fmt.Errorf("dead-letter backlog still undeliverable: %w", err)
+	}
+
+	delivery, err := s.prepare(data)
+	if err != nil {
+		return permanent(err)
+	}
+	if delivery == nil {
+		utils.Debug("Webhook '%s': no query results changed, nothing to deliver", s.name)
+		return nil
+	}
+
+	if err := s.post(ctx, s.mode, delivery.idempotencyKey, delivery.body); err != nil {
+		return err
+	}
+	s.commit(delivery)
+	return nil
+}
+
+// DeadLetter stores a delivery that failed all retries
+func (s *WebhookSink) DeadLetter(data *collector.CollectedData, cause error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivery, err := s.prepare(data)
+	if err != nil || delivery == nil {
+		return
+	}
+
+	letter := deadLetter{
+		URL:            s.url,
+		Event:          s.mode,
+		IdempotencyKey: delivery.idempotencyKey,
+		FailedAt:       time.Now().UTC().Format(time.RFC3339),
+		Error:          cause.Error(),
+		Body:           delivery.body,
+	}
+	content, err := json.MarshalIndent(letter, "", "  ")
+	if err != nil {
+		return
+	}
+
+	path := filepath.Join(s.deadLetterDir, fmt.Sprintf("%d-%s.json", time.Now().UnixNano(), delivery.idempotencyKey[:16]))
+	if err := s.store.WriteFile(path, content); err != nil {
+		utils.Error("Failed to write dead letter for webhook '%s': %v", s.name, err)
+		return
+	}
+	utils.Warning("Webhook '%s' delivery saved to dead-letter directory: %s", s.name, path)
+
+	// The dead letter now owns these changes; do not report them again next cycle
+	s.commit(delivery)
+	s.pruneDeadLetters()
+}
+
+// prepare builds the payload for the configured mode; nil means nothing to send
+func (s *WebhookSink) prepare(data *collector.CollectedData) (*webhookDelivery, error) {
+	if s.mode == config.WebhookModeReport {
+		body, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal report: %w", err)
+		}
+		return &webhookDelivery{idempotencyKey: idempotencyKey(s.name, data.DeviceID, data.Timestamp, ""), body: body}, nil
+	}
+
+	// Inventory and telemetry rows change every cycle; only compliance state is compared
+	queryNames := make([]string, 0, len(s.compliance))
+	for queryName := range data.Data {
+		if s.compliance[queryName] {
+			queryNames = append(queryNames, queryName)
+		}
+	}
+	sort.Strings(queryNames)
+
+	hashes := make(map[string]string)
+	var changes []queryChange
+	var hashList []string
+	addChange := func(queryName, status string, rows []map[string]interface{}) error {
+		encoded, err := json.Marshal(rows)
+		if err != nil {
+			return fmt.Errorf("failed to hash results of query '%s': %w", queryName, err)
+		}
+		sum := sha256.Sum256(encoded)
+		hash := hex.EncodeToString(sum[:])
+		hashes[queryName] = hash
+
+		if s.hashes[queryName] == hash {
+			return nil
+		}
+		changes = append(changes, queryChange{
+			Query:        queryName,
+			Status:       status,
+			PreviousHash: s.hashes[queryName],
+			CurrentHash:  hash,
+			Rows:         rows,
+		})
+		hashList = append(hashList, queryName+"="+hash)
+		return nil
+	}
+
+	for _, queryName := range queryNames {
+		// Failed or skipped queries say nothing about the device's state
+		status := data.QueryMeta[queryName].Status
+		if status != collector.QueryStatusOK && status != collector.QueryStatusEmpty {
+			continue
+		}
+		if err := addChange(queryName, status, data.Data[queryName]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Findings change when software or the database does, and are delivered like a query
+	if data.VulnerabilityDB != nil && data.VulnerabilityDB.Status == collector.VulnDBStatusOK {
+		rows, err := findingRows(data.Vulnerabilities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode vulnerability findings: %w", err)
+		}
+		status := collector.QueryStatusOK
+		if len(rows) == 0 {
+			status = collector.QueryStatusEmpty
+		}
+		if err := addChange("vulnerabilities", status, rows); err != nil {
+			return nil, err
+		}
+	}
+
+	// Extension findings are only meaningful when the inventory itself was collected
+	if extensionsCollected(data) {
+		rows, err := findingRows(data.ExtensionFindings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode extension findings: %w", err)
+		}
+		status := collector.QueryStatusOK
+		if len(rows) == 0 {
+			status = collector.QueryStatusEmpty
+		}
+		if err := addChange("extension_findings", status, rows); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"event":         config.WebhookModeChanges,
+		"device_id":     data.DeviceID,
+		"computer_name": data.ComputerName,
+		"user":          data.User,
+		"timestamp":     data.Timestamp,
+		"changes":       changes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal changes: %w", err)
+	}
+
+	return &webhookDelivery{
+		idempotencyKey: idempotencyKey(s.name, data.DeviceID, data.Timestamp, strings.Join(hashList, ",")),
+		body:           body,
+		hashes:         hashes,
+	}, nil
+}
+
+// findingRows converts findings to the row shape of query results
+func findingRows(findings interface{}) ([]map[string]interface{}, error) {
+	rows := []map[string]interface{}{}
+	encoded, err := json.Marshal(findings)
+	if err != nil {
+		return nil, err
+	}
+	if string(encoded) == "null" {
+		return rows, nil
+	}
+	if err := json.Unmarshal(encoded, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// complianceQueries returns the names of this platform's compliance checks
+func complianceQueries(cfg *config.Config) map[string]bool {
+	compliance := make(map[string]bool)
+	queries, err := cfg.GetPlatformQueries()
+	if err != nil {
+		return compliance
+	}
+	for queryName, queryConfig := range queries {
+		if queryConfig.Compliance {
+			compliance[queryName] = true
+		}
+	}
+	return compliance
+}
+
+// extensionsCollected reports whether any browser extension query succeeded
+func extensionsCollected(data *collector.CollectedData) bool {
+	for _, queryName := range []string{extensions.ChromeQuery, extensions.FirefoxQuery} {
+		status := data.QueryMeta[queryName].Status
+		if status == collector.QueryStatusOK || status == collector.QueryStatusEmpty {
+			return true
+		}
+	}
+	return false
+}
+
+// commit records the delivered result hashes (changes mode only)
+func (s *WebhookSink) commit(delivery *webhookDelivery) {
+	if delivery.hashes == nil {
+		return
+	}
+	for queryName, hash := range delivery.hashes {
+		s.hashes[queryName] = hash
+	}
+
+	content, err := json.MarshalIndent(s.hashes, "", "    ")
+	if err != nil {
+		return
+	}
+	if err := s.store.WriteFile(s.statePath, content); err != nil {
+		utils.Warning("Failed to persist webhook state: %v", err)
+	}
+}
+
+// post sends one signed delivery of the given event type
+func (s *WebhookSink) post(ctx context.Context, event, key string, body []byte) error {
+	// The device signature is added first because it canonicalizes the body the HMAC covers
+	header := make(http.Header)
+	if s.signer != nil {
+		var err error
+		if body, err = s.signer.SignRequest(header, body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header = header
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "scanx/1.0")
+	req.Header.Set(WebhookEventHeader, event)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookIdempotencyKeyHeader, key)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(s.secret, timestamp, body))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	// Any 2xx is accepted; receivers commonly answer 202 or 204
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return statusError("webhook", resp.StatusCode)
+	}
+	return nil
+}
+
+// redeliverDeadLetters retries every dead letter, oldest first, stopping at the
+// first one that could still succeed later. Letters the receiver rejects
+// permanently are dropped so they cannot block the backlog.
+func (s *WebhookSink) redeliverDeadLetters(ctx context.Context) error {
+	for _, path := range s.deadLetterPaths() {
+		// Unreadable dead letters are discarded by the store
+		content, err := s.store.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var letter deadLetter
+		if err := json.Unmarshal(content, &letter); err != nil {
+			utils.Warning("Removing unreadable dead letter %s: %v", path, err)
+			os.Remove(path)
+			continue
+		}
+
+		// Letters keep the event type they were created with, even if the mode changed since
+		event := letter.Event
+		if event == "" {
+			event = s.mode
+		}
+		if err := s.post(ctx, event, letter.IdempotencyKey, letter.Body); err != nil {
+			var permanentErr *permanentError
+			if !errors.As(err, &permanentErr) {
+				return err
+			}
+			utils.Warning("Dropping dead letter %s rejected by webhook '%s': %v", filepath.Base(path), s.name, err)
+		} else {
+			utils.Info("Redelivered dead letter %s to webhook '%s'", filepath.Base(path), s.name)
+		}
+		os.Remove(path)
+	}
+	return nil
+}
+
+// pruneDeadLetters drops the oldest dead letters beyond maxDeadLetters
+func (s *WebhookSink) pruneDeadLetters() {
+	paths := s.deadLetterPaths()
+	for len(paths) > maxDeadLetters {
+		utils.Warning("Dead-letter directory full, dropping %s", paths[0])
+		os.Remove(paths[0])
+		paths = paths[1:]
+	}
+}
+
+// deadLetterPaths lists dead letters oldest first (names start with a nanosecond timestamp)
+func (s *WebhookSink) deadLetterPaths() []string {
+	paths, _ := filepath.Glob(filepath.Join(s.deadLetterDir, "*.json"))
+	sort.Slice(paths, func(i, j int) bool {
+		return deadLetterSeq(paths[i]) < deadLetterSeq(paths[j])
+	})
+	return paths
+}
+
+// deadLetterSeq extracts the timestamp prefix of a dead-letter file name
+func deadLetterSeq(path string) int64 {
+	prefix, _, _ := strings.Cut(filepath.Base(path), "-")
+	seq, _ := strconv.ParseInt(prefix, 10, 64)
+	return seq
+}
+
+// idempotencyKey derives a key that is identical for every retry of the same delivery
+func idempotencyKey(sinkName, deviceID, timestamp, content string) string {
+	sum := sha256.Sum256([]byte(sinkName + "|" + deviceID + "|" + timestamp + "|" + content))
+	return hex.EncodeToString(sum[:])
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>". Receivers
+// recompute it with the shared secret and compare using hmac.Equal.
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,179 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"scanx/internal/collector"
+	"scanx/internal/config"
+	"scanx/internal/securestore"
+	"scanx/internal/software"
+)
+
+// webhookReceiver records deliveries and answers with a per-call status
+type webhookReceiver struct {
+	mu       sync.Mutex
+	events   []string
+	keys     []string
+	statuses []int // consumed in order; 200 once exhausted
+}
+
+func (w *webhookReceiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.events = append(w.events, req.Header.Get(WebhookEventHeader))
+	w.keys = append(w.keys, req.Header.Get(WebhookIdempotencyKeyHeader))
+	status := http.StatusOK
+	if len(w.statuses) > 0 {
+		status, w.statuses = w.statuses[0], w.statuses[1:]
+	}
+	rw.WriteHeader(status)
+}
+
+func newTestWebhook(t *testing.T, url, mode string) *WebhookSink {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := securestore.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "deadletter"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return &WebhookSink{
+		name:          "test",
+		url:           url,
+		secret:        []byte("secret"),
+		mode:          mode,
+		deadLetterDir: filepath.Join(dir, "deadletter"),
+		store:         store,
+		statePath:     filepath.Join(dir, "webhook-test.json"),
+		httpClient:    &http.Client{},
+		compliance:    map[string]bool{"disk_encryption_info": true},
+		hashes:        make(map[string]string),
+	}
+}
+
+func testWebhookReport(timestamp, value string) *collector.CollectedData {
+	return &collector.CollectedData{
+		DeviceID:  "scanx-device",
+		Timestamp: timestamp,
+		Data:      map[string][]map[string]interface{}{"disk_encryption_info": {{"disk_encryption": value}}},
+		QueryMeta: map[string]collector.QueryMeta{"disk_encryption_info": {Status: collector.QueryStatusOK}},
+	}
+}
+
+func TestWebhookRedeliversBacklogBeforeNewPayload(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	sink := newTestWebhook(t, server.URL, config.WebhookModeReport)
+
+	old := testWebhookReport("2026-01-01T00:00:00Z", "true")
+	sink.DeadLetter(old, errors.New("offline"))
+	oldKey := idempotencyKey(sink.name, old.DeviceID, old.Timestamp, "")
+
+	// The sink was switched to changes mode after the report was dead-lettered
+	sink.mode = config.WebhookModeChanges
+	if err := sink.Send(context.Background(), testWebhookReport("2026-01-01T01:00:00Z", "false")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(receiver.keys) != 2 || receiver.keys[0] != oldKey {
+		t.Fatalf("deliveries %v, want the dead letter %s first", receiver.keys, oldKey)
+	}
+	if receiver.events[0] != config.WebhookModeReport || receiver.events[1] != config.WebhookModeChanges {
+		t.Errorf("events %v, want [report changes]", receiver.events)
+	}
+	if paths := sink.deadLetterPaths(); len(paths) != 0 {
+		t.Errorf("dead letters left after redelivery: %v", paths)
+	}
+}
+
+func TestWebhookHoldsNewPayloadWhileBacklogFails(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	sink := newTestWebhook(t, server.URL, config.WebhookModeReport)
+
+	sink.DeadLetter(testWebhookReport("2026-01-01T00:00:00Z", "true"), errors.New("offline"))
+	if err := sink.Send(context.Background(), testWebhookReport("2026-01-01T01:00:00Z", "false")); err == nil {
+		t.Fatal("Send succeeded although the backlog could not be delivered")
+	}
+	if len(receiver.keys) != 1 {
+		t.Errorf("%d deliveries, want only the failed dead letter", len(receiver.keys))
+	}
+	if paths := sink.deadLetterPaths(); len(paths) != 1 {
+		t.Errorf("dead letters = %v, want the undelivered one kept", paths)
+	}
+}
+
+func TestWebhookDropsPermanentlyRejectedDeadLetter(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	sink := newTestWebhook(t, server.URL, config.WebhookModeReport)
+
+	sink.DeadLetter(testWebhookReport("2026-01-01T00:00:00Z", "true"), errors.New("offline"))
+	if err := sink.Send(context.Background(), testWebhookReport("2026-01-01T01:00:00Z", "false")); err != nil {
+		t.Fatalf("rejected dead letter blocked the new payload: %v", err)
+	}
+	if len(receiver.keys) != 2 {
+		t.Errorf("%d deliveries, want the rejected letter and the new report", len(receiver.keys))
+	}
+	if paths := sink.deadLetterPaths(); len(paths) != 0 {
+		t.Errorf("rejected dead letter kept: %v", paths)
+	}
+}
+
+func TestWebhookChangesOnlyCoverCompliance(t *testing.T) {
+	sink := newTestWebhook(t, "http://127.0.0.1", config.WebhookModeChanges)
+	report := func(timestamp, encryption, uptime string) *collector.CollectedData {
+		return &collector.CollectedData{
+			DeviceID:  "scanx-device",
+			Timestamp: timestamp,
+			Data: map[string][]map[string]interface{}{
+				"disk_encryption_info": {{"disk_encryption": encryption}},
+				"system_info":          {{"uptime": uptime}},
+			},
+			QueryMeta: map[string]collector.QueryMeta{
+				"disk_encryption_info": {Status: collector.QueryStatusOK},
+				"system_info":          {Status: collector.QueryStatusOK},
+			},
+			Software: []software.Item{{Name: "Firefox", Version: timestamp, Source: software.SourceMacOSApp}},
+		}
+	}
+
+	delivery, err := sink.prepare(report("2026-01-01T00:00:00Z", "true", "100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery == nil {
+		t.Fatal("first report delivered nothing")
+	}
+	var payload struct {
+		Changes []queryChange `json:"changes"`
+	}
+	if err := json.Unmarshal(delivery.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.Changes) != 1 || payload.Changes[0].Query != "disk_encryption_info" {
+		t.Errorf("changes = %+v, want only the compliance query", payload.Changes)
+	}
+	sink.commit(delivery)
+
+	// Telemetry and inventory changes alone are not notified
+	if delivery, err := sink.prepare(report("2026-01-01T01:00:00Z", "true", "3700")); err != nil || delivery != nil {
+		t.Errorf("prepare = %v, %v; want nothing to deliver", delivery, err)
+	}
+	if delivery, err := sink.prepare(report("2026-01-01T02:00:00Z", "false", "7300")); err != nil || delivery == nil {
+		t.Errorf("prepare = %v, %v; want the compliance change delivered", delivery, err)
+	}
+}
@@ -0,0 +1,62 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"scanx/internal/securestore"
+	"scanx/internal/utils"
+	"scanx/pkg/payloadsig"
+)
+
+// keyFileName is the file under the state directory holding the device signing key seed
+const keyFileName = "signing.key"
+
+// LoadOrCreateKey returns the device's ed25519 signing key, generating and
+// persisting a new one on first use
+func LoadOrCreateKey(stateDir string) (ed25519.PrivateKey, error) {
+	store, err := securestore.Open(stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state store: %w", err)
+	}
+	key, _, err := loadOrCreateKey(store, stateDir)
+	return key, err
+}
+
+// loadOrCreateKey also reports whether the key was generated by this call
+func loadOrCreateKey(store *securestore.Store, stateDir string) (ed25519.PrivateKey, bool, error) {
+	keyPath := filepath.Join(stateDir, keyFileName)
+
+	if data, err := store.ReadFile(keyPath); err == nil {
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err == nil && len(seed) == ed25519.SeedSize {
+			return ed25519.NewKeyFromSeed(seed), false, nil
+		}
+		return nil, false, fmt.Errorf("signing key %s is corrupt; remove it to generate a new key", keyPath)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(key.Seed()) + "\n"
+	// Exclusive create so two processes starting at once cannot overwrite each other's key
+	if err := store.CreateFile(keyPath, []byte(encoded)); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return loadOrCreateKey(store, stateDir)
+		}
+		return nil, false, fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	utils.Info("Generated new device signing key (fingerprint %s)", payloadsig.Fingerprint(key.Public().(ed25519.PublicKey)))
+	return key, true, nil
+}
@@ -0,0 +1,128 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"scanx/internal/securestore"
+	"scanx/internal/utils"
+	"scanx/pkg/payloadsig"
+)
+
+// sequenceFileName persists the last payload sequence number used
+const sequenceFileName = "signing.seq"
+
+// Signer signs outgoing payloads with the device key and a monotonic sequence number
+type Signer struct {
+	mu          sync.Mutex
+	key         ed25519.PrivateKey
+	fingerprint string
+	store       *securestore.Store
+	seqPath     string
+	sequence    uint64
+}
+
+// NewSigner loads (or creates) the device key and the persisted sequence counter
+func NewSigner(stateDir string) (*Signer, error) {
+	store, err := securestore.Open(stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state store: %w", err)
+	}
+	key, created, err := loadOrCreateKey(store, stateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Signer{
+		key:         key,
+		fingerprint: payloadsig.Fingerprint(key.Public().(ed25519.PublicKey)),
+		store:       store,
+		seqPath:     filepath.Join(stateDir, sequenceFileName),
+	}
+
+	data, err := store.ReadFile(s.seqPath)
+	switch {
+	case err == nil:
+		sequence, parseErr := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if parseErr != nil {
+			sequence = restartSequence()
+			utils.Warning("Signing sequence file %s is corrupt, continuing from %d", s.seqPath, sequence)
+		}
+		s.sequence = sequence
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("failed to read signing sequence: %w", err)
+	case !created:
+		// The key survived but its counter did not; never reuse numbers the server has seen
+		s.sequence = restartSequence()
+		utils.Warning("Signing sequence lost, continuing from %d", s.sequence)
+	}
+
+	return s, nil
+}
+
+// restartSequence returns a value above any sequence this device could plausibly have used
+func restartSequence() uint64 {
+	return uint64(time.Now().UnixMilli())
+}
+
+// Fingerprint returns the hex SHA-256 of the device public key
+func (s *Signer) Fingerprint() string {
+	return s.fingerprint
+}
+
+// SignRequest signs body and sets the signature headers. It returns the
+// canonical JSON that must be sent as the request body.
+func (s *Signer) SignRequest(header http.Header, body []byte) ([]byte, error) {
+	sequence, err := s.nextSequence()
+	if err != nil {
+		return nil, err
+	}
+
+	canonical, sig, err := payloadsig.Sign(s.key, sequence, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign payload: %w", err)
+	}
+	sig.Apply(header)
+	return canonical, nil
+}
+
+// nextSequence increments the counter and persists it before it is used, so a
+// crash can never cause the same sequence number to be sent twice. The persisted
+// value is re-read under the state lock on every call: the daemon and one-shot
+// commands sign with the same key, and each must continue after the other's numbers.
+func (s *Signer) nextSequence() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next uint64
+	err := s.store.Update(s.seqPath, func(current []byte) ([]byte, error) {
+		next = s.sequence
+		if current != nil {
+			stored, err := strconv.ParseUint(strings.TrimSpace(string(current)), 10, 64)
+			if err != nil {
+				stored = restartSequence()
+				utils.Warning("Signing sequence file %s is corrupt, continuing from %d", s.seqPath, stored)
+			}
+			// Never go back below a number this process has already used
+			if stored > next {
+				next = stored
+			}
+		}
+		next++
+		return []byte(strconv.FormatUint(next, 10) + "\n"), nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to persist signing sequence: %w", err)
+	}
+
+	s.sequence = next
+	return next, nil
+}
@@ -0,0 +1,72 @@
+package signing
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"scanx/pkg/payloadsig"
+)
+
+func TestSignersSharingStateNeverReuseSequences(t *testing.T) {
+	stateDir := t.TempDir()
+
+	// The daemon builds its signer once at startup
+	daemon, err := NewSigner(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A one-shot command started later loads the same persisted counter
+	oneShot, err := NewSigner(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[uint64]string)
+	var last uint64
+	for i, signer := range []*Signer{daemon, oneShot, oneShot, daemon, oneShot, daemon, daemon} {
+		sequence, err := signer.nextSequence()
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := "daemon"
+		if signer == oneShot {
+			name = "one-shot"
+		}
+		if previous, dup := seen[sequence]; dup {
+			t.Fatalf("call %d: %s reused sequence %d already used by %s", i, name, sequence, previous)
+		}
+		if sequence <= last {
+			t.Fatalf("call %d: %s signed sequence %d after %d", i, name, sequence, last)
+		}
+		seen[sequence] = name
+		last = sequence
+	}
+}
+
+func TestSignedRequestsFromBothProcessesPassReplayCheck(t *testing.T) {
+	stateDir := t.TempDir()
+	daemon, err := NewSigner(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oneShot, err := NewSigner(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := &payloadsig.Verifier{
+		TrustOnFirstUse: true,
+		Replay:          payloadsig.NewMemoryReplayStore(0),
+	}
+	for i, signer := range []*Signer{oneShot, daemon, oneShot, daemon} {
+		header := make(http.Header)
+		body, err := signer.SignRequest(header, []byte(`{"report":`+time.Now().Format(`"15:04:05.000000000"`)+`}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifier.Verify(header, body); err != nil {
+			t.Fatalf("request %d rejected: %v", i, err)
+		}
+	}
+}
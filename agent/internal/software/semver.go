@@ -0,0 +1,96 @@
+package software
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Semver is a version reduced to semantic versioning components
+type Semver struct {
+	Major      int    `json:"major"`
+	Minor      int    `json:"minor"`
+	Patch      int    `json:"patch"`
+	Prerelease string `json:"prerelease,omitempty"`
+}
+
+// String formats the version as major.minor.patch[-prerelease]
+func (v Semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// ParseSemver leniently reads the leading numeric components of a version such as
+// "120.0.6099.129", "v2.1", "1:2.36-9+deb12u4" or "1.0rc1". Components beyond the
+// third are dropped, missing ones are zero, and a textual suffix directly after the
+// numbers becomes the prerelease. Package revisions and epochs are ignored. It
+// returns nil when the version does not start with a number.
+func ParseSemver(version string) *Semver {
+	version = strings.TrimSpace(version)
+	version = strings.TrimPrefix(strings.TrimPrefix(version, "v"), "V")
+	if epoch, rest, ok := strings.Cut(version, ":"); ok && isDigits(epoch) {
+		version = rest
+	}
+
+	var parts []int
+	i := 0
+	for len(parts) < 3 {
+		start := i
+		for i < len(version) && version[i] >= '0' && version[i] <= '9' {
+			i++
+		}
+		if i == start {
+			break
+		}
+		n, err := strconv.Atoi(version[start:i])
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, n)
+		if i+1 < len(version) && version[i] == '.' && unicode.IsDigit(rune(version[i+1])) {
+			i++
+			continue
+		}
+		break
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	for len(parts) < 3 {
+		parts = append(parts, 0)
+	}
+
+	v := &Semver{Major: parts[0], Minor: parts[1], Patch: parts[2]}
+
+	// Skip components beyond patch, e.g. the build number of 120.0.6099.129
+	for i < len(version) && (version[i] == '.' || unicode.IsDigit(rune(version[i]))) {
+		i++
+	}
+	rest := strings.TrimLeft(version[i:], "-~_ ")
+	if rest != "" && unicode.IsLetter(rune(rest[0])) {
+		end := strings.IndexFunc(rest, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+		})
+		if end < 0 {
+			end = len(rest)
+		}
+		v.Prerelease = strings.ToLower(strings.TrimRight(rest[:end], "."))
+	}
+	return v
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
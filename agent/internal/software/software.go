@@ -0,0 +1,260 @@
+package software
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Sources identify which platform inventory an item came from
+const (
+	SourceMacOSApp       = "macos_app"
+	SourceWindowsProgram = "windows_program"
+	SourceDeb            = "deb"
+	SourceRPM            = "rpm"
+)
+
+// InventoryQueries are the queries whose rows describe installed software
+var InventoryQueries = []string{"apps_info", "deb_packages_info", "rpm_packages_info"}
+
+// Item is one piece of installed software in the platform-independent schema
+type Item struct {
+	Name    string `json:"name"`
+	Vendor  string `json:"vendor,omitempty"`
+	Version string `json:"version"`
+	// Semver is Version reduced to major.minor.patch for comparison across platforms
+	Semver *Semver `json:"semver,omitempty"`
+	Source string  `json:"source"`
+	// InstallDate is YYYY-MM-DD when the platform records it
+	InstallDate string `json:"install_date,omitempty"`
+	// Identifier is the platform's own ID: bundle identifier, MSI product code or package name
+	Identifier string `json:"identifier,omitempty"`
+	Arch       string `json:"arch,omitempty"`
+	// Purl is the package URL of OS packages
+	Purl string `json:"purl,omitempty"`
+}
+
+// Distro identifies the Linux distribution OS packages belong to, from /etc/os-release
+type Distro struct {
+	ID        string
+	VersionID string
+}
+
+// Normalize maps the rows of the inventory queries in data to items, sorted by
+// name and version with exact duplicates removed
+func Normalize(data map[string][]map[string]interface{}, distro Distro) []Item {
+	var items []Item
+	seen := make(map[Item]bool)
+	for _, queryName := range InventoryQueries {
+		for _, row := range data[queryName] {
+			var item Item
+			switch {
+			case queryName == "deb_packages_info":
+				item = debItem(row, distro)
+			case queryName == "rpm_packages_info":
+				item = rpmItem(row, distro)
+			case column(row, "bundle_identifier") != "" || column(row, "bundle_name") != "":
+				item = macOSItem(row)
+			case queryName == "apps_info":
+				item = windowsItem(row)
+			}
+			if item.Name == "" {
+				continue
+			}
+			item.Semver = ParseSemver(item.Version)
+
+			// Windows lists 32- and 64-bit registrations of one program separately
+			key := item
+			key.Semver = nil
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			items = append(items, item)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if a, b := strings.ToLower(items[i].Name), strings.ToLower(items[j].Name); a != b {
+			return a < b
+		}
+		return items[i].Version < items[j].Version
+	})
+	return items
+}
+
+// macOSItem normalizes an apps row; bundles have no vendor column, so the vendor
+// is taken from the reverse-DNS bundle identifier
+func macOSItem(row map[string]interface{}) Item {
+	item := Item{
+		Name:       firstColumn(row, "display_name", "bundle_name"),
+		Version:    firstColumn(row, "bundle_short_version", "bundle_version"),
+		Source:     SourceMacOSApp,
+		Identifier: column(row, "bundle_identifier"),
+	}
+	if item.Name == "" {
+		item.Name = item.Identifier
+	}
+	item.Vendor = vendorFromBundleID(item.Identifier)
+	return item
+}
+
+// windowsItem normalizes a programs row
+func windowsItem(row map[string]interface{}) Item {
+	version := column(row, "version")
+	return Item{
+		Name:        cleanWindowsName(column(row, "name"), version),
+		Vendor:      CleanVendor(column(row, "publisher")),
+		Version:     version,
+		Source:      SourceWindowsProgram,
+		InstallDate: parseCompactDate(column(row, "install_date")),
+		Identifier:  firstColumn(row, "identifying_number", "package_family_name"),
+	}
+}
+
+// debItem normalizes a deb_packages row; the vendor is the maintainer without address
+func debItem(row map[string]interface{}, distro Distro) Item {
+	maintainer, _, _ := strings.Cut(column(row, "maintainer"), "<")
+	item := Item{
+		Name:       column(row, "name"),
+		Vendor:     strings.TrimSpace(maintainer),
+		Version:    column(row, "version"),
+		Source:     SourceDeb,
+		Identifier: column(row, "name"),
+		Arch:       column(row, "arch"),
+	}
+	item.Purl = packageURL("deb", distro, item.Name, item.Version, item.Arch)
+	return item
+}
+
+// rpmItem normalizes an rpm_packages row into an [epoch:]version-release version
+func rpmItem(row map[string]interface{}, distro Distro) Item {
+	version := column(row, "version")
+	if release := column(row, "release"); release != "" {
+		version += "-" + release
+	}
+	if epoch := column(row, "epoch"); epoch != "" && epoch != "0" {
+		version = epoch + ":" + version
+	}
+
+	item := Item{
+		Name:       column(row, "name"),
+		Vendor:     CleanVendor(column(row, "vendor")),
+		Version:    version,
+		Source:     SourceRPM,
+		Identifier: column(row, "name"),
+		Arch:       column(row, "arch"),
+	}
+	if installTime, err := strconv.ParseInt(column(row, "install_time"), 10, 64); err == nil && installTime > 0 {
+		item.InstallDate = time.Unix(installTime, 0).UTC().Format("2006-01-02")
+	}
+	item.Purl = packageURL("rpm", distro, item.Name, item.Version, item.Arch)
+	return item
+}
+
+// packageURL builds a purl such as pkg:deb/debian/curl@7.88.1-10?arch=amd64&distro=debian-12
+func packageURL(purlType string, distro Distro, name, version, arch string) string {
+	if distro.ID == "" || name == "" {
+		return ""
+	}
+
+	// The purl spec names Red Hat's namespace "redhat"
+	namespace := distro.ID
+	if namespace == "rhel" {
+		namespace = "redhat"
+	}
+
+	purl := fmt.Sprintf("pkg:%s/%s/%s", purlType, url.PathEscape(namespace), url.PathEscape(name))
+	if version != "" {
+		purl += "@" + url.PathEscape(version)
+	}
+
+	var qualifiers []string
+	if arch != "" {
+		qualifiers = append(qualifiers, "arch="+url.QueryEscape(arch))
+	}
+	if distro.VersionID != "" {
+		qualifiers = append(qualifiers, "distro="+url.QueryEscape(distro.ID+"-"+distro.VersionID))
+	}
+	if len(qualifiers) > 0 {
+		purl += "?" + strings.Join(qualifiers, "&")
+	}
+	return purl
+}
+
+// vendorSuffixes are legal-entity suffixes dropped so "Google LLC" and "Google Inc." compare equal
+var vendorSuffixes = regexp.MustCompile(`(?i)[,.]?\s+(inc|incorporated|llc|l\.l\.c|ltd|limited|corp|corporation|co|company|gmbh|ag|s\.?a|b\.?v|plc|pty)\.?$`)
+
+// CleanVendor normalizes a publisher or vendor name
+func CleanVendor(vendor string) string {
+	vendor = strings.Join(strings.Fields(vendor), " ")
+	for {
+		trimmed := strings.TrimRight(vendorSuffixes.ReplaceAllString(vendor, ""), " ,.")
+		if trimmed == vendor || trimmed == "" {
+			return vendor
+		}
+		vendor = trimmed
+	}
+}
+
+// vendorFromBundleID returns "Google" for "com.google.Chrome"
+func vendorFromBundleID(bundleID string) string {
+	parts := strings.Split(bundleID, ".")
+	if len(parts) < 3 || parts[1] == "" {
+		return ""
+	}
+	org := []rune(parts[1])
+	org[0] = unicode.ToUpper(org[0])
+	return string(org)
+}
+
+// windowsNameSuffix matches the architecture and locale notes Windows installers
+// append to program names, e.g. "Mozilla Firefox (x64 en-US)"
+var windowsNameSuffix = regexp.MustCompile(`(?i)\s*\((x64|x86|x86_64|amd64|arm64|64-bit|32-bit|64 bit|32 bit)[^)]*\)$`)
+
+// cleanWindowsName drops architecture notes and a trailing copy of the version,
+// so "7-Zip 23.01 (x64)" becomes "7-Zip"
+func cleanWindowsName(name, version string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	name = windowsNameSuffix.ReplaceAllString(name, "")
+	if i := strings.LastIndex(name, " "); i > 0 {
+		last := strings.TrimPrefix(strings.ToLower(name[i+1:]), "v")
+		if version != "" && last != "" && unicode.IsDigit(rune(last[0])) && strings.HasPrefix(version, last) {
+			name = name[:i]
+		}
+	}
+	return name
+}
+
+// parseCompactDate converts the YYYYMMDD install dates of Windows to YYYY-MM-DD
+func parseCompactDate(value string) string {
+	parsed, err := time.Parse("20060102", value)
+	if err != nil {
+		return ""
+	}
+	return parsed.Format("2006-01-02")
+}
+
+// column returns a row value as a trimmed string
+func column(row map[string]interface{}, name string) string {
+	value, ok := row[name]
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+// firstColumn returns the first non-empty value among the named columns
+func firstColumn(row map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		if value := column(row, name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -31,6 +31,20 @@ type Logger struct {
 
 var GlobalLogger *Logger
 
+// consoleOutput receives console copies of log lines and other progress output
+var consoleOutput io.Writer = os.Stdout
+
+// SetConsoleOutput sends console output to w instead of stdout, e.g. for commands that
+// write a document to stdout. It applies to loggers created afterwards.
+func SetConsoleOutput(w io.Writer) {
+	consoleOutput = w
+}
+
+// ConsoleOutput returns where console output is written
+func ConsoleOutput() io.Writer {
+	return consoleOutput
+}
+
 // InitLogger initializes the global logger with system paths
 func InitLogger() error {
 	logger, err := NewLogger("info")
@@ -53,6 +67,13 @@ func InitLoggerWithLevel(levelStr string) error {
 	return nil
 }
 
+// SetLogLevel changes the global logger's level, e.g. after a configuration reload
+func SetLogLevel(levelStr string) {
+	if GlobalLogger != nil {
+		GlobalLogger.level = parseLogLevel(levelStr)
+	}
+}
+
 // parseLogLevel converts string to LogLevel
 func parseLogLevel(levelStr string) LogLevel {
 	switch levelStr {
@@ -78,7 +99,7 @@ func NewLogger(levelStr string) (*Logger, error) {
 	if err := ensureLogDir(logPath); err != nil {
 		// Fallback to current directory if system path fails
 		logPath = "./scanx.log"
-		fmt.Printf("Warning: Could not create system log directory, using fallback: %s\n", logPath)
+		fmt.Fprintf(consoleOutput, "Warning: Could not create system log directory, using fallback: %s\n", logPath)
 	}
 
 	// Open log file
@@ -90,11 +111,11 @@ func NewLogger(levelStr string) (*Logger, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to open log file: %w", err)
 		}
-		fmt.Printf("Warning: Using fallback log file: %s\n", logPath)
+		fmt.Fprintf(consoleOutput, "Warning: Using fallback log file: %s\n", logPath)
 	}
 
 	// Create multi-writer for both file and console
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
+	multiWriter := io.MultiWriter(consoleOutput, logFile)
 
 	logger := &Logger{
 		debugLogger:   log.New(multiWriter, "DEBUG: ", 0), // We'll add timestamp manually
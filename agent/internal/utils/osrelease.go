@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// OSReleasePath is where Linux distributions describe themselves
+const OSReleasePath = "/etc/os-release"
+
+// ReadOSRelease parses an os-release file into its KEY=value fields, unquoting values
+func ReadOSRelease(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			fields[key] = strings.Trim(value, `"'`)
+		}
+	}
+	return fields, scanner.Err()
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// GetStateDir returns the platform-specific directory for persistent agent state
+func GetStateDir() string {
+	switch runtime.GOOS {
+	case "windows":
+		return `C:\ProgramData\scanx\state`
+	case "darwin":
+		return "/Library/Application Support/scanx"
+	case "linux":
+		return "/var/lib/scanx"
+	default:
+		return "/var/lib/scanx"
+	}
+}
+
+// EnsureStateDir creates the state directory if needed and returns its path. There
+// is no fallback: state in a directory relative to wherever the agent happened to
+// start would split the device identity, keys and locks between locations.
+func EnsureStateDir() (string, error) {
+	stateDir := GetStateDir()
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create state directory %s: %w", stateDir, err)
+	}
+	return stateDir, nil
+}
@@ -0,0 +1,109 @@
+package vulndb
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// cvss3Weights are the CVSS v3.x base metric values from the specification
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3BaseScore computes the base score of a CVSS v3.0 or v3.1 vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"
+func cvss3BaseScore(vector string) (float64, error) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3") {
+		return 0, fmt.Errorf("not a CVSS v3 vector: %s", vector)
+	}
+
+	metrics := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		if key, value, ok := strings.Cut(part, ":"); ok {
+			metrics[key] = value
+		}
+	}
+
+	values := make(map[string]float64)
+	for metric, weights := range cvss3Weights {
+		value, ok := weights[metrics[metric]]
+		if !ok {
+			return 0, fmt.Errorf("missing or invalid %s in CVSS vector: %s", metric, vector)
+		}
+		values[metric] = value
+	}
+
+	scope := metrics["S"]
+	if scope != "U" && scope != "C" {
+		return 0, fmt.Errorf("missing or invalid S in CVSS vector: %s", vector)
+	}
+
+	// Privileges required weigh more when the scope changes
+	var privileges float64
+	switch metrics["PR"] {
+	case "N":
+		privileges = 0.85
+	case "L":
+		privileges = 0.62
+		if scope == "C" {
+			privileges = 0.68
+		}
+	case "H":
+		privileges = 0.27
+		if scope == "C" {
+			privileges = 0.5
+		}
+	default:
+		return 0, fmt.Errorf("missing or invalid PR in CVSS vector: %s", vector)
+	}
+
+	iss := 1 - (1-values["C"])*(1-values["I"])*(1-values["A"])
+	var impact float64
+	if scope == "U" {
+		impact = 6.42 * iss
+	} else {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+
+	exploitability := 8.22 * values["AV"] * values["AC"] * privileges * values["UI"]
+	if scope == "U" {
+		return roundUp(math.Min(impact+exploitability, 10)), nil
+	}
+	return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+}
+
+// roundUp rounds up to one decimal as defined in CVSS v3.1 Appendix A, avoiding
+// floating point artifacts such as 4.000000001 becoming 4.1
+func roundUp(value float64) float64 {
+	scaled := int64(math.Round(value * 100000))
+	if scaled%10000 == 0 {
+		return float64(scaled) / 100000
+	}
+	return float64(scaled/10000+1) / 10
+}
+
+// ratingForScore maps a CVSS score to its qualitative rating
+func ratingForScore(score float64) string {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	default:
+		return SeverityNone
+	}
+}
@@ -0,0 +1,241 @@
+package vulndb
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxDownloadSize bounds a downloaded database
+const MaxDownloadSize = 1 << 30
+
+// maxSignatureSize bounds a downloaded signature file
+const maxSignatureSize = 4 << 10
+
+// SignatureSuffix is appended to the database URL path to fetch its signature
+const SignatureSuffix = ".sig"
+
+// Download file names used when the database path is a directory; the data is
+// saved as .zip or .json according to its content
+const (
+	downloadBaseName = "downloaded"
+	etagFileName     = ".downloaded.etag"
+)
+
+// ErrBadSignature is returned when a downloaded database does not match its signature
+var ErrBadSignature = errors.New("vulnerability database signature does not verify")
+
+// Refresh downloads the database from url into path when it changed since the last
+// download, using the ETag the server sent then. path is either a directory, in
+// which the download is stored next to any files dropped there, or a single
+// database file that is replaced.
+//
+// The download is streamed to a temporary file, its SHA-256 digest must carry a
+// valid Ed25519 signature by key (fetched from url with SignatureSuffix), and it
+// must parse before it replaces anything. On success the database at path is
+// returned so callers need not parse it again; it is nil when the server reported
+// no change. On any error the existing files are left untouched.
+func Refresh(ctx context.Context, client *http.Client, url string, path string, key ed25519.PublicKey) (*Database, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("no valid key to verify the vulnerability database download")
+	}
+	target, etagPath := downloadTarget(path)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if etag, err := os.ReadFile(etagPath); err == nil && target != "" {
+		if _, err := os.Stat(target); err == nil {
+			req.Header.Set("If-None-Match", strings.TrimSpace(string(etag)))
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download vulnerability database: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("vulnerability database download failed with status %d", resp.StatusCode)
+	}
+
+	dir := path
+	if target != "" {
+		dir = filepath.Dir(target)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	// The hidden name keeps a partial download out of directory loads
+	tmp, err := os.CreateTemp(dir, "."+downloadBaseName+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write vulnerability database: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	digest := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, digest), io.LimitReader(resp.Body, MaxDownloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download vulnerability database: %w", err)
+	}
+	if written > MaxDownloadSize {
+		return nil, fmt.Errorf("vulnerability database is larger than %d MB", MaxDownloadSize>>20)
+	}
+	if err := tmp.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to write vulnerability database: %w", err)
+	}
+
+	signature, err := fetchSignature(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(key, digest.Sum(nil), signature) {
+		return nil, ErrBadSignature
+	}
+
+	// Refuse data that would leave the agent without a usable database
+	downloaded := &Database{index: make(map[string]map[string][]affectedRef)}
+	if err := downloaded.loadFile(tmp.Name()); err != nil {
+		return nil, fmt.Errorf("downloaded vulnerability database is invalid: %w", err)
+	}
+
+	if target == "" {
+		isZip, err := hasZipMagic(tmp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read downloaded vulnerability database: %w", err)
+		}
+		stale := filepath.Join(path, downloadBaseName+".zip")
+		target = filepath.Join(path, downloadBaseName+".json")
+		if isZip {
+			stale, target = target, stale
+		}
+		os.Remove(stale)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write vulnerability database: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write vulnerability database: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return nil, fmt.Errorf("failed to replace %s: %w", target, err)
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		os.WriteFile(etagPath, []byte(etag), 0644)
+	} else {
+		os.Remove(etagPath)
+	}
+
+	if target == path {
+		downloaded.Path = path
+		return downloaded, nil
+	}
+	// Files dropped next to the download are part of the database too
+	if err := downloaded.loadOthers(path, target); err != nil {
+		return nil, err
+	}
+	downloaded.Path = path
+	return downloaded, nil
+}
+
+// fetchSignature downloads the base64 Ed25519 signature published next to the database
+func fetchSignature(ctx context.Context, client *http.Client, databaseURL string) ([]byte, error) {
+	sigURL, err := signatureURL(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", sigURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download vulnerability database signature: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vulnerability database signature download failed with status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download vulnerability database signature: %w", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("vulnerability database signature is not a base64 Ed25519 signature")
+	}
+	return signature, nil
+}
+
+// signatureURL appends SignatureSuffix to the path of the database URL
+func signatureURL(databaseURL string) (string, error) {
+	parsed, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid vulnerability database url: %w", err)
+	}
+	parsed.Path += SignatureSuffix
+	parsed.RawPath = ""
+	return parsed.String(), nil
+}
+
+// hasZipMagic reports whether file starts with a zip local file header
+func hasZipMagic(file *os.File) (bool, error) {
+	magic := make([]byte, 4)
+	n, err := file.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return bytes.Equal(magic[:n], []byte("PK\x03\x04")), nil
+}
+
+// loadOthers adds every database file under dir except skip
+func (db *Database) loadOthers(dir string, skip string) error {
+	files, err := databaseFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file == skip {
+			continue
+		}
+		if err := db.loadFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadTarget returns the file a download replaces and where its ETag is kept.
+// For a directory the target is empty until the content type is known.
+func downloadTarget(path string) (string, string) {
+	if info, err := os.Stat(path); (err == nil && info.IsDir()) || (err != nil && filepath.Ext(path) == "") {
+		for _, ext := range []string{".zip", ".json"} {
+			candidate := filepath.Join(path, downloadBaseName+ext)
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate, filepath.Join(path, etagFileName)
+			}
+		}
+		return "", filepath.Join(path, etagFileName)
+	}
+	return path, path + ".etag"
+}
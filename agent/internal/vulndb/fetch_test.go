@@ -0,0 +1,151 @@
+package vulndb
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testRecord = `{"id":"OSV-2026-1","modified":"2026-01-01T00:00:00Z","affected":[{"package":{"ecosystem":"Debian:12","name":"openssl"},"versions":["3.0.0"]}]}`
+
+// testPublisher serves a database and its signature like the backend does
+type testPublisher struct {
+	body      []byte
+	signature string
+	requests  int
+}
+
+func newTestPublisher(t *testing.T, body []byte) (*testPublisher, ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testPublisher{body: body}
+	p.sign(private)
+	return p, public, private
+}
+
+func (p *testPublisher) sign(key ed25519.PrivateKey) {
+	digest := sha256.Sum256(p.body)
+	p.signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, digest[:]))
+}
+
+func (p *testPublisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/vulndb":
+		p.requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(p.body)
+	case "/vulndb" + SignatureSuffix:
+		w.Write([]byte(p.signature + "\n"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRefreshVerifiesAndReturnsDatabase(t *testing.T) {
+	publisher, key, _ := newTestPublisher(t, []byte(testRecord))
+	server := httptest.NewServer(publisher)
+	defer server.Close()
+
+	dir := t.TempDir()
+	// A file dropped next to the download is part of the returned database
+	dropped := `{"id":"OSV-2026-2","modified":"2026-02-01T00:00:00Z","affected":[]}`
+	if err := os.WriteFile(filepath.Join(dir, "local.json"), []byte(dropped), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Refresh(context.Background(), server.Client(), server.URL+"/vulndb", dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db == nil || db.Entries != 2 || db.Path != dir {
+		t.Fatalf("Refresh returned %+v, want both records from %s", db, dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, downloadBaseName+".json")); err != nil {
+		t.Errorf("download not saved: %v", err)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".*.tmp")); len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+
+	// The ETag makes the next refresh a no-op
+	db, err = Refresh(context.Background(), server.Client(), server.URL+"/vulndb", dir, key)
+	if err != nil || db != nil {
+		t.Errorf("unchanged refresh = %v, %v; want nil, nil", db, err)
+	}
+}
+
+func TestRefreshRejectsBadSignature(t *testing.T) {
+	publisher, key, _ := newTestPublisher(t, []byte(testRecord))
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher.sign(otherKey)
+	server := httptest.NewServer(publisher)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "osv.json")
+	if err := os.WriteFile(path, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Refresh(context.Background(), server.Client(), server.URL+"/vulndb", path, key)
+	if !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("Refresh error = %v, want ErrBadSignature", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "[]" {
+		t.Errorf("existing database replaced by an unverified download: %s", data)
+	}
+}
+
+func TestRefreshRejectsInvalidDatabase(t *testing.T) {
+	publisher, key, _ := newTestPublisher(t, []byte("not json"))
+	server := httptest.NewServer(publisher)
+	defer server.Close()
+
+	dir := t.TempDir()
+	if _, err := Refresh(context.Background(), server.Client(), server.URL+"/vulndb", dir, key); err == nil {
+		t.Fatal("Refresh accepted a signed but unparsable database")
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("files written for a rejected download: %v", files)
+	}
+}
+
+func TestRefreshRequiresKey(t *testing.T) {
+	publisher, _, _ := newTestPublisher(t, []byte(testRecord))
+	server := httptest.NewServer(publisher)
+	defer server.Close()
+
+	if _, err := Refresh(context.Background(), server.Client(), server.URL+"/vulndb", t.TempDir(), nil); err == nil {
+		t.Fatal("Refresh downloaded without a key to verify with")
+	}
+	if publisher.requests != 0 {
+		t.Errorf("%d downloads without a key", publisher.requests)
+	}
+}
+
+func TestSignatureURL(t *testing.T) {
+	got, err := signatureURL("https://mdm.example.com/api/devices/agent/vulndb?channel=stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://mdm.example.com/api/devices/agent/vulndb.sig?channel=stable"; got != want {
+		t.Errorf("signatureURL = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package vulndb
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Qualitative severity ratings reported in findings
+const (
+	SeverityCritical = "CRITICAL"
+	SeverityHigh     = "HIGH"
+	SeverityMedium   = "MEDIUM"
+	SeverityLow      = "LOW"
+	SeverityNone     = "NONE"
+	SeverityUnknown  = "UNKNOWN"
+)
+
+// InstalledPackage is one piece of installed software to check
+type InstalledPackage struct {
+	// Ecosystem is the OSV ecosystem, e.g. "Debian:12" or "macOS"
+	Ecosystem string
+	Name      string
+	Version   string
+	// Aliases are further names the package may be listed under, e.g. a macOS bundle name
+	Aliases []string
+	// Source is the query the package was inventoried by
+	Source string
+}
+
+// Finding is a vulnerability affecting an installed package
+type Finding struct {
+	ID        string   `json:"id"`
+	Aliases   []string `json:"aliases,omitempty"`
+	Summary   string   `json:"summary,omitempty"`
+	Severity  string   `json:"severity"`
+	Score     float64  `json:"score,omitempty"`
+	Ecosystem string   `json:"ecosystem"`
+	Package   string   `json:"package"`
+	Version   string   `json:"version"`
+	// FixedVersion is the first version that fixes the vulnerability, when known
+	FixedVersion string `json:"fixed_version,omitempty"`
+	Source       string `json:"source"`
+}
+
+// newFinding builds the finding for a matched package
+func newFinding(ref affectedRef, pkg InstalledPackage, fixed string) Finding {
+	severity, score := ref.severity()
+	return Finding{
+		ID:           ref.vuln.ID,
+		Aliases:      ref.vuln.Aliases,
+		Summary:      ref.vuln.Summary,
+		Severity:     severity,
+		Score:        score,
+		Ecosystem:    pkg.Ecosystem,
+		Package:      pkg.Name,
+		Version:      pkg.Version,
+		FixedVersion: fixed,
+		Source:       pkg.Source,
+	}
+}
+
+// severity rates a match from the most specific data available: a CVSS v3 vector
+// on the affected package, then on the record, then the textual severity that
+// databases such as GitHub, Ubuntu and Debian publish
+func (ref affectedRef) severity() (string, float64) {
+	for _, severities := range [][]Severity{ref.affected.Severity, ref.vuln.Severity} {
+		for _, s := range severities {
+			if !strings.HasPrefix(s.Type, "CVSS_V3") {
+				continue
+			}
+			if score, err := cvss3BaseScore(s.Score); err == nil {
+				return ratingForScore(score), score
+			}
+		}
+	}
+
+	for _, severities := range [][]Severity{ref.affected.Severity, ref.vuln.Severity} {
+		for _, s := range severities {
+			if rating := normalizeRating(s.Score); rating != SeverityUnknown {
+				return rating, 0
+			}
+		}
+	}
+
+	for _, raw := range []json.RawMessage{ref.affected.EcosystemSpecific, ref.affected.DatabaseSpecific, ref.vuln.DatabaseSpecific} {
+		var specific struct {
+			Severity string `json:"severity"`
+			Urgency  string `json:"urgency"`
+		}
+		if len(raw) == 0 || json.Unmarshal(raw, &specific) != nil {
+			continue
+		}
+		for _, text := range []string{specific.Severity, specific.Urgency} {
+			if rating := normalizeRating(text); rating != SeverityUnknown {
+				return rating, 0
+			}
+		}
+	}
+
+	return SeverityUnknown, 0
+}
+
+// normalizeRating maps the textual severities used across OSV sources to a rating
+func normalizeRating(text string) string {
+	switch strings.ToUpper(strings.TrimSpace(text)) {
+	case "CRITICAL":
+		return SeverityCritical
+	case "HIGH", "IMPORTANT":
+		return SeverityHigh
+	case "MEDIUM", "MODERATE":
+		return SeverityMedium
+	case "LOW", "NEGLIGIBLE", "UNIMPORTANT":
+		return SeverityLow
+	default:
+		return SeverityUnknown
+	}
+}
+
+// severityRank orders ratings from UNKNOWN and NONE up to CRITICAL
+func severityRank(rating string) int {
+	switch rating {
+	case SeverityCritical:
+		return 4
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	case SeverityLow:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,141 @@
+package vulndb
+
+import (
+	"fmt"
+	"strings"
+
+	"scanx/internal/utils"
+)
+
+// Ecosystems the agent reports for application inventories, which OSV has no
+// public feed for; a company database can list entries under these names
+const (
+	EcosystemMacOS   = "macOS"
+	EcosystemWindows = "Windows"
+)
+
+// osReleaseEcosystems maps /etc/os-release IDs to OSV ecosystem names and whether
+// the release is the major version only, as with the RPM based distributions
+var osReleaseEcosystems = map[string]struct {
+	name      string
+	majorOnly bool
+}{
+	"debian":    {"Debian", false},
+	"ubuntu":    {"Ubuntu", false},
+	"rhel":      {"Red Hat", true},
+	"rocky":     {"Rocky Linux", true},
+	"almalinux": {"AlmaLinux", true},
+}
+
+// PackagesFromRows converts the rows of an inventory query into installed packages.
+// apps_info rows carry macOS bundles or Windows programs depending on their columns,
+// deb_packages_info and rpm_packages_info rows are OS packages of linuxEcosystem.
+func PackagesFromRows(queryName string, rows []map[string]interface{}, linuxEcosystem string) []InstalledPackage {
+	var packages []InstalledPackage
+	for _, row := range rows {
+		var pkg InstalledPackage
+		switch {
+		case queryName == "deb_packages_info":
+			pkg = debPackage(row, linuxEcosystem)
+		case queryName == "rpm_packages_info":
+			pkg = rpmPackage(row, linuxEcosystem)
+		case column(row, "bundle_identifier") != "" || column(row, "bundle_name") != "":
+			pkg = macOSPackage(row)
+		case queryName == "apps_info":
+			pkg = InstalledPackage{Ecosystem: EcosystemWindows, Name: column(row, "name"), Version: column(row, "version")}
+		}
+		if pkg.Name == "" || pkg.Version == "" || pkg.Ecosystem == "" {
+			continue
+		}
+		pkg.Source = queryName
+		packages = append(packages, pkg)
+	}
+	return packages
+}
+
+// macOSPackage is listed by bundle identifier, with the bundle name as an alias
+func macOSPackage(row map[string]interface{}) InstalledPackage {
+	pkg := InstalledPackage{
+		Ecosystem: EcosystemMacOS,
+		Name:      column(row, "bundle_identifier"),
+		Version:   column(row, "bundle_short_version"),
+	}
+	if pkg.Version == "" {
+		pkg.Version = column(row, "bundle_version")
+	}
+
+	name := column(row, "bundle_name")
+	if pkg.Name == "" {
+		pkg.Name = name
+	} else if name != "" && name != pkg.Name {
+		pkg.Aliases = append(pkg.Aliases, name)
+	}
+	return pkg
+}
+
+// debPackage is listed under its source package, as Debian and Ubuntu advisories are
+func debPackage(row map[string]interface{}, ecosystem string) InstalledPackage {
+	pkg := InstalledPackage{Ecosystem: ecosystem, Name: column(row, "name"), Version: column(row, "version")}
+
+	// dpkg reports "src" or "src (srcversion)" when the binary is versioned differently
+	if source := column(row, "source"); source != "" {
+		sourceName, sourceVersion, hasVersion := strings.Cut(source, " (")
+		if sourceName != pkg.Name {
+			pkg.Aliases = append(pkg.Aliases, pkg.Name)
+			pkg.Name = sourceName
+		}
+		if hasVersion {
+			pkg.Version = strings.TrimSuffix(sourceVersion, ")")
+		}
+	}
+	return pkg
+}
+
+// rpmPackage is listed by name with an [epoch:]version-release version
+func rpmPackage(row map[string]interface{}, ecosystem string) InstalledPackage {
+	version := column(row, "version")
+	if release := column(row, "release"); release != "" {
+		version += "-" + release
+	}
+	if epoch := column(row, "epoch"); epoch != "" && epoch != "0" {
+		version = epoch + ":" + version
+	}
+	return InstalledPackage{Ecosystem: ecosystem, Name: column(row, "name"), Version: version}
+}
+
+// column returns a row value as a trimmed string
+func column(row map[string]interface{}, name string) string {
+	value, ok := row[name]
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+// LinuxEcosystem returns the OSV ecosystem of the running distribution, e.g.
+// "Debian:12", from /etc/os-release; it is empty for unknown distributions
+func LinuxEcosystem(osReleasePath string) string {
+	fields, err := utils.ReadOSRelease(osReleasePath)
+	if err != nil {
+		return ""
+	}
+
+	// ID_LIKE lets RPM derivatives such as CentOS Stream fall back to their parent;
+	// Debian derivatives number their releases differently, so they cannot
+	candidates := append([]string{fields["ID"]}, strings.Fields(fields["ID_LIKE"])...)
+	for i, id := range candidates {
+		ecosystem, ok := osReleaseEcosystems[strings.ToLower(id)]
+		if !ok || (i > 0 && !ecosystem.majorOnly) {
+			continue
+		}
+		release := fields["VERSION_ID"]
+		if ecosystem.majorOnly {
+			release, _, _ = strings.Cut(release, ".")
+		}
+		if release == "" {
+			return ecosystem.name
+		}
+		return ecosystem.name + ":" + release
+	}
+	return ""
+}
@@ -0,0 +1,426 @@
+package vulndb
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OSV range types (https://ossf.github.io/osv-schema/#affectedrangestype-field)
+const (
+	RangeSemver    = "SEMVER"
+	RangeEcosystem = "ECOSYSTEM"
+	RangeGit       = "GIT"
+)
+
+// maxEntrySize bounds a single JSON document read from the database
+const maxEntrySize = 64 << 20
+
+// Vulnerability is the subset of an OSV record the agent needs for matching;
+// long free-text fields such as details are not kept in memory
+type Vulnerability struct {
+	ID               string          `json:"id"`
+	Modified         time.Time       `json:"modified"`
+	Withdrawn        *time.Time      `json:"withdrawn,omitempty"`
+	Aliases          []string        `json:"aliases,omitempty"`
+	Summary          string          `json:"summary,omitempty"`
+	Severity         []Severity      `json:"severity,omitempty"`
+	Affected         []Affected      `json:"affected"`
+	DatabaseSpecific json.RawMessage `json:"database_specific,omitempty"`
+}
+
+// Severity is one OSV severity score, e.g. a CVSS vector
+type Severity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// Affected lists the affected versions of one package
+type Affected struct {
+	Package           Package         `json:"package"`
+	Severity          []Severity      `json:"severity,omitempty"`
+	Ranges            []Range         `json:"ranges,omitempty"`
+	Versions          []string        `json:"versions,omitempty"`
+	EcosystemSpecific json.RawMessage `json:"ecosystem_specific,omitempty"`
+	DatabaseSpecific  json.RawMessage `json:"database_specific,omitempty"`
+}
+
+// Package identifies a package within an OSV ecosystem
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Purl      string `json:"purl,omitempty"`
+}
+
+// Range is an ordered list of events delimiting affected versions
+type Range struct {
+	Type   string  `json:"type"`
+	Events []Event `json:"events"`
+}
+
+// Event is a range boundary; exactly one field is set
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// Database is an in-memory OSV database indexed for lookup by package
+type Database struct {
+	// Path is where the database was loaded from
+	Path string
+	// Entries is the number of vulnerability records loaded
+	Entries int
+	// Modified is the newest modification time of any record
+	Modified time.Time
+
+	// index maps ecosystem base and lower-case package name to affected entries
+	index map[string]map[string][]affectedRef
+}
+
+// affectedRef points at one affected entry of a vulnerability
+type affectedRef struct {
+	vuln     *Vulnerability
+	affected *Affected
+}
+
+// Load reads an OSV database from a directory of JSON and zip files, a zip
+// archive such as the osv.dev ecosystem exports, or a single JSON file holding
+// one record or an array of records
+func Load(path string) (*Database, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &Database{Path: path, index: make(map[string]map[string][]affectedRef)}
+	if !info.IsDir() {
+		if err := db.loadFile(path); err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+
+	files, err := databaseFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := db.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// Fingerprint summarizes the names, sizes and modification times of the database
+// files so callers can tell when it needs reloading
+func Fingerprint(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = databaseFiles(path); err != nil {
+			return "", err
+		}
+	}
+
+	var b strings.Builder
+	for _, file := range files {
+		fileInfo, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", file, fileInfo.Size(), fileInfo.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// databaseFiles lists the .json and .zip files under dir in lexical order,
+// skipping hidden files such as the download metadata
+func databaseFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && path != dir {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if entry.Type().IsRegular() && (ext == ".json" || ext == ".zip") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vulnerability database %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// loadFile adds the records of one JSON or zip file, detected by content
+func (db *Database) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	n, _ := io.ReadFull(file, magic)
+	if bytes.Equal(magic[:n], []byte("PK\x03\x04")) {
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		return db.loadZip(file, info.Size(), path)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxEntrySize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(data) > maxEntrySize {
+		return fmt.Errorf("%s is larger than %d MB", path, maxEntrySize>>20)
+	}
+	return db.addDocument(data, path)
+}
+
+// loadZip adds the records of every JSON file in a zip archive
+func (db *Database) loadZip(r io.ReaderAt, size int64, path string) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(entry.Name), ".json") {
+			continue
+		}
+		if entry.UncompressedSize64 > maxEntrySize {
+			return fmt.Errorf("%s: %s is larger than %d MB", path, entry.Name, maxEntrySize>>20)
+		}
+
+		reader, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %s: %w", path, entry.Name, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(reader, maxEntrySize))
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %s: %w", path, entry.Name, err)
+		}
+		if err := db.addDocument(data, path+": "+entry.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDocument indexes one JSON document holding a record or an array of records
+func (db *Database) addDocument(data []byte, source string) error {
+	data = bytes.TrimSpace(data)
+	var vulns []*Vulnerability
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &vulns); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", source, err)
+		}
+	} else {
+		vuln := &Vulnerability{}
+		if err := json.Unmarshal(data, vuln); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", source, err)
+		}
+		vulns = append(vulns, vuln)
+	}
+
+	for _, vuln := range vulns {
+		if vuln == nil || vuln.ID == "" || vuln.Withdrawn != nil {
+			continue
+		}
+		db.Entries++
+		if vuln.Modified.After(db.Modified) {
+			db.Modified = vuln.Modified
+		}
+
+		for i := range vuln.Affected {
+			affected := &vuln.Affected[i]
+			base := ecosystemBase(affected.Package.Ecosystem)
+			if base == "" || affected.Package.Name == "" {
+				continue
+			}
+			if db.index[base] == nil {
+				db.index[base] = make(map[string][]affectedRef)
+			}
+			name := strings.ToLower(affected.Package.Name)
+			db.index[base][name] = append(db.index[base][name], affectedRef{vuln: vuln, affected: affected})
+		}
+	}
+	return nil
+}
+
+// Match returns the vulnerabilities affecting the given installed packages,
+// most severe first
+func (db *Database) Match(packages []InstalledPackage) []Finding {
+	var findings []Finding
+	// Binaries built from one source package report the same finding once
+	seen := make(map[string]bool)
+	for _, pkg := range packages {
+		if pkg.Version == "" {
+			continue
+		}
+		byName := db.index[ecosystemBase(pkg.Ecosystem)]
+		if byName == nil {
+			continue
+		}
+
+		for _, name := range append([]string{pkg.Name}, pkg.Aliases...) {
+			for _, ref := range byName[strings.ToLower(name)] {
+				key := strings.Join([]string{ref.vuln.ID, pkg.Ecosystem, pkg.Name, pkg.Version}, "\x00")
+				if seen[key] || !ecosystemMatches(ref.affected.Package.Ecosystem, pkg.Ecosystem) {
+					continue
+				}
+				fixed, affected := ref.affected.affects(pkg.Version)
+				if !affected {
+					continue
+				}
+				seen[key] = true
+				findings = append(findings, newFinding(ref, pkg, fixed))
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if rankI, rankJ := severityRank(findings[i].Severity), severityRank(findings[j].Severity); rankI != rankJ {
+			return rankI > rankJ
+		}
+		if findings[i].Score != findings[j].Score {
+			return findings[i].Score > findings[j].Score
+		}
+		if findings[i].ID != findings[j].ID {
+			return findings[i].ID < findings[j].ID
+		}
+		return findings[i].Package < findings[j].Package
+	})
+	return findings
+}
+
+// affects reports whether version is affected and, if so, the first fixed version
+// of the range that matched
+func (a *Affected) affects(version string) (string, bool) {
+	for _, listed := range a.Versions {
+		if listed == version {
+			return "", true
+		}
+	}
+
+	for _, r := range a.Ranges {
+		if r.Type != RangeSemver && r.Type != RangeEcosystem {
+			// GIT ranges name commits, which installed packages do not have
+			continue
+		}
+		if fixed, ok := r.affects(version, comparatorFor(a.Package.Ecosystem, r.Type)); ok {
+			return fixed, true
+		}
+	}
+	return "", false
+}
+
+// affects evaluates the range events in version order as the OSV schema prescribes
+func (r Range) affects(version string, compare versionComparator) (string, bool) {
+	type boundary struct {
+		version string
+		kind    string
+	}
+
+	var boundaries []boundary
+	for _, event := range r.Events {
+		switch {
+		case event.Introduced != "":
+			boundaries = append(boundaries, boundary{event.Introduced, "introduced"})
+		case event.Fixed != "":
+			boundaries = append(boundaries, boundary{event.Fixed, "fixed"})
+		case event.LastAffected != "":
+			boundaries = append(boundaries, boundary{event.LastAffected, "last_affected"})
+		}
+	}
+	sort.SliceStable(boundaries, func(i, j int) bool {
+		// "0" means before every version
+		if boundaries[i].version == "0" || boundaries[j].version == "0" {
+			return boundaries[i].version == "0" && boundaries[j].version != "0"
+		}
+		return compare(boundaries[i].version, boundaries[j].version) < 0
+	})
+
+	affected := false
+	fixed := ""
+	for _, b := range boundaries {
+		switch b.kind {
+		case "introduced":
+			if b.version == "0" || compare(version, b.version) >= 0 {
+				affected = true
+				fixed = ""
+			}
+		case "fixed":
+			if compare(version, b.version) >= 0 {
+				affected = false
+			} else if affected && fixed == "" {
+				fixed = b.version
+			}
+		case "last_affected":
+			if compare(version, b.version) > 0 {
+				affected = false
+			}
+		}
+	}
+	if !affected {
+		return "", false
+	}
+	return fixed, true
+}
+
+// ecosystemBase returns the lower-case ecosystem name without its release suffix,
+// e.g. "debian" for "Debian:12"
+func ecosystemBase(ecosystem string) string {
+	base, _, _ := strings.Cut(ecosystem, ":")
+	return strings.ToLower(strings.TrimSpace(base))
+}
+
+// ecosystemMatches reports whether an OSV ecosystem covers the installed package's
+// ecosystem. A record without a release applies to every release; otherwise one
+// of its release segments must equal the package's release, so "Ubuntu:22.04"
+// matches "Ubuntu:22.04:LTS" and "Red Hat:9" matches "Red Hat:enterprise_linux:9::appstream".
+func ecosystemMatches(recordEcosystem, packageEcosystem string) bool {
+	if ecosystemBase(recordEcosystem) != ecosystemBase(packageEcosystem) {
+		return false
+	}
+	_, recordRelease, hasRecordRelease := strings.Cut(recordEcosystem, ":")
+	_, packageRelease, hasPackageRelease := strings.Cut(packageEcosystem, ":")
+	if !hasRecordRelease || !hasPackageRelease {
+		return true
+	}
+	for _, segment := range strings.Split(recordRelease, ":") {
+		if strings.EqualFold(segment, packageRelease) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,336 @@
+package vulndb
+
+import (
+	"strings"
+	"unicode"
+)
+
+// versionComparator returns -1, 0 or 1 comparing two versions of one ecosystem
+type versionComparator func(a, b string) int
+
+// comparatorFor picks the version ordering used by an OSV ecosystem
+func comparatorFor(ecosystem string, rangeType string) versionComparator {
+	if rangeType == RangeSemver {
+		return compareSemver
+	}
+	switch ecosystemBase(ecosystem) {
+	case "debian", "ubuntu":
+		return compareDebian
+	case "red hat", "rocky linux", "almalinux", "suse", "opensuse", "fedora", "oracle linux", "amazon linux":
+		return compareRPM
+	default:
+		return compareGeneric
+	}
+}
+
+// compareDebian orders [epoch:]upstream[-revision] versions like dpkg does
+func compareDebian(a, b string) int {
+	epochA, restA := splitEpoch(a)
+	epochB, restB := splitEpoch(b)
+	if c := compareNumeric(epochA, epochB); c != 0 {
+		return c
+	}
+
+	upstreamA, revisionA := splitLast(restA, "-")
+	upstreamB, revisionB := splitLast(restB, "-")
+	if c := compareDebianPart(upstreamA, upstreamB); c != 0 {
+		return c
+	}
+	return compareDebianPart(revisionA, revisionB)
+}
+
+// compareDebianPart implements dpkg's verrevcmp: alternating non-digit and digit
+// runs, where '~' sorts before everything including the end of the string
+func compareDebianPart(a, b string) int {
+	for a != "" || b != "" {
+		var nonDigitA, nonDigitB string
+		nonDigitA, a = splitNonDigits(a)
+		nonDigitB, b = splitNonDigits(b)
+		if c := compareDebianLexical(nonDigitA, nonDigitB); c != 0 {
+			return c
+		}
+
+		var digitA, digitB string
+		digitA, a = splitRun(a, true)
+		digitB, b = splitRun(b, true)
+		if c := compareNumeric(digitA, digitB); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareDebianLexical compares non-digit runs with dpkg's character order
+func compareDebianLexical(a, b string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var orderA, orderB int
+		if i < len(a) {
+			orderA = debianOrder(a[i])
+		}
+		if i < len(b) {
+			orderB = debianOrder(b[i])
+		}
+		if orderA != orderB {
+			return sign(orderA - orderB)
+		}
+	}
+	return 0
+}
+
+// debianOrder ranks '~' lowest, then end of string, then letters, then other characters
+func debianOrder(c byte) int {
+	switch {
+	case c == '~':
+		return -1
+	case unicode.IsLetter(rune(c)):
+		return int(c)
+	default:
+		return int(c) + 256
+	}
+}
+
+// compareRPM orders [epoch:]version[-release] versions like rpmvercmp
+func compareRPM(a, b string) int {
+	epochA, restA := splitEpoch(a)
+	epochB, restB := splitEpoch(b)
+	if c := compareNumeric(epochA, epochB); c != 0 {
+		return c
+	}
+
+	versionA, releaseA := splitLast(restA, "-")
+	versionB, releaseB := splitLast(restB, "-")
+	if c := compareRPMPart(versionA, versionB); c != 0 {
+		return c
+	}
+	if releaseA == "" || releaseB == "" {
+		// A missing release matches any release, as in OSV "fixed" events without one
+		return 0
+	}
+	return compareRPMPart(releaseA, releaseB)
+}
+
+// compareRPMPart implements rpmvercmp on one version or release string
+func compareRPMPart(a, b string) int {
+	for {
+		a = strings.TrimLeftFunc(a, func(r rune) bool { return !isAlnum(r) && r != '~' && r != '^' })
+		b = strings.TrimLeftFunc(b, func(r rune) bool { return !isAlnum(r) && r != '~' && r != '^' })
+
+		// Tilde sorts before everything, caret after the end but before anything else
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			switch {
+			case a == "":
+				return -1
+			case b == "":
+				return 1
+			case !strings.HasPrefix(a, "^"):
+				return 1
+			case !strings.HasPrefix(b, "^"):
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if a == "" || b == "" {
+			return sign(len(a) - len(b))
+		}
+
+		numeric := unicode.IsDigit(rune(a[0]))
+		var segA, segB string
+		segA, a = splitRun(a, numeric)
+		segB, b = splitRun(b, numeric)
+		if segB == "" {
+			// Numeric segments are newer than alphabetic ones
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		var c int
+		if numeric {
+			c = compareNumeric(segA, segB)
+		} else {
+			c = strings.Compare(segA, segB)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+}
+
+// compareSemver orders semantic versions, with pre-releases before their release
+func compareSemver(a, b string) int {
+	a = strings.TrimPrefix(strings.TrimSpace(a), "v")
+	b = strings.TrimPrefix(strings.TrimSpace(b), "v")
+	a, _, _ = strings.Cut(a, "+")
+	b, _, _ = strings.Cut(b, "+")
+	coreA, preA, hasPreA := strings.Cut(a, "-")
+	coreB, preB, hasPreB := strings.Cut(b, "-")
+
+	partsA := strings.Split(coreA, ".")
+	partsB := strings.Split(coreB, ".")
+	for i := 0; i < 3; i++ {
+		var partA, partB string
+		if i < len(partsA) {
+			partA = partsA[i]
+		}
+		if i < len(partsB) {
+			partB = partsB[i]
+		}
+		if c := compareNumeric(partA, partB); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case hasPreA && !hasPreB:
+		return -1
+	case !hasPreA && hasPreB:
+		return 1
+	case !hasPreA && !hasPreB:
+		return 0
+	}
+
+	idsA := strings.Split(preA, ".")
+	idsB := strings.Split(preB, ".")
+	for i := 0; i < len(idsA) && i < len(idsB); i++ {
+		numA, numB := isNumeric(idsA[i]), isNumeric(idsB[i])
+		var c int
+		switch {
+		case numA && numB:
+			c = compareNumeric(idsA[i], idsB[i])
+		case numA:
+			c = -1
+		case numB:
+			c = 1
+		default:
+			c = strings.Compare(idsA[i], idsB[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return sign(len(idsA) - len(idsB))
+}
+
+// compareGeneric orders dotted versions such as application versions on macOS and
+// Windows: numeric runs compare as numbers, other runs as case-insensitive text
+func compareGeneric(a, b string) int {
+	a = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(a), "v"))
+	b = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(b), "v"))
+	for {
+		a = strings.TrimLeftFunc(a, func(r rune) bool { return !isAlnum(r) })
+		b = strings.TrimLeftFunc(b, func(r rune) bool { return !isAlnum(r) })
+		if a == "" || b == "" {
+			return sign(len(a) - len(b))
+		}
+
+		numA, numB := unicode.IsDigit(rune(a[0])), unicode.IsDigit(rune(b[0]))
+		if numA != numB {
+			// "1.0" is newer than "1.beta"
+			if numA {
+				return 1
+			}
+			return -1
+		}
+
+		var segA, segB string
+		segA, a = splitRun(a, numA)
+		segB, b = splitRun(b, numB)
+		var c int
+		if numA {
+			c = compareNumeric(segA, segB)
+		} else {
+			c = strings.Compare(segA, segB)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+}
+
+// splitEpoch separates an "N:" epoch prefix, defaulting to 0
+func splitEpoch(version string) (string, string) {
+	if epoch, rest, ok := strings.Cut(version, ":"); ok && isNumeric(epoch) {
+		return epoch, rest
+	}
+	return "0", version
+}
+
+// splitLast splits at the last separator; the second part is empty without one
+func splitLast(s, sep string) (string, string) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):]
+	}
+	return s, ""
+}
+
+// splitRun returns the leading run of digits (or letters) and the remainder
+func splitRun(s string, digits bool) (string, string) {
+	i := 0
+	for i < len(s) {
+		isDigit := s[i] >= '0' && s[i] <= '9'
+		if isDigit != digits || !isAlnum(rune(s[i])) {
+			break
+		}
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// splitNonDigits returns the leading run of non-digit characters and the remainder
+func splitNonDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && (s[i] < '0' || s[i] > '9') {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareNumeric compares digit strings of any length without overflow
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
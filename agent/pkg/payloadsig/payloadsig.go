@@ -0,0 +1,313 @@
+// Package payloadsig signs and verifies scanx agent payloads.
+//
+// Each device holds an ed25519 key. For every request the agent signs
+//
+//	"scanx-sig-v1\n" + sequence + "\n" + nonce + "\n" + signed_at + "\n" + canonical JSON body
+//
+// and sends the signature, key fingerprint, public key, sequence, nonce and
+// timestamp in headers. Servers verify with a Verifier: the signature proves the
+// payload came from the key's holder, and the sequence/nonce/timestamp checks
+// reject replays. Servers should pin each device's fingerprint at enrollment
+// instead of trusting the public key header on every request.
+package payloadsig
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Version identifies the signing input format
+const Version = "v1"
+
+// Request headers carrying the signature
+const (
+	HeaderVersion     = "X-Scanx-Signature-Version"
+	HeaderSignature   = "X-Scanx-Payload-Signature"
+	HeaderFingerprint = "X-Scanx-Key-Fingerprint"
+	HeaderPublicKey   = "X-Scanx-Public-Key"
+	HeaderSequence    = "X-Scanx-Sequence"
+	HeaderNonce       = "X-Scanx-Nonce"
+	HeaderSignedAt    = "X-Scanx-Signed-At"
+)
+
+// DefaultMaxSkew is how far signed_at may differ from the verifier's clock
+const DefaultMaxSkew = 5 * time.Minute
+
+// Verification errors; use errors.Is to distinguish them
+var (
+	ErrMissingSignature = errors.New("payload is not signed")
+	ErrMalformed        = errors.New("malformed signature headers")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrBadSignature     = errors.New("signature verification failed")
+	ErrStale            = errors.New("signature timestamp outside allowed skew")
+	ErrReplay           = errors.New("payload replayed")
+)
+
+// Signature holds the values carried in the signature headers
+type Signature struct {
+	Version     string
+	Fingerprint string
+	PublicKey   ed25519.PublicKey
+	Sequence    uint64
+	Nonce       string
+	SignedAt    time.Time
+	Value       []byte
+}
+
+// Fingerprint returns the hex SHA-256 of a public key
+func Fingerprint(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:])
+}
+
+// Canonicalize re-encodes JSON with sorted object keys, no insignificant
+// whitespace, no HTML escaping and numbers preserved exactly as written
+func Canonicalize(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON payload: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid JSON payload: trailing data")
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	// encoding/json sorts map keys, which is what makes the output canonical
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+// SigningInput returns the exact bytes that are signed
+func SigningInput(sequence uint64, nonce string, signedAt int64, canonical []byte) []byte {
+	header := fmt.Sprintf("scanx-sig-%s\n%d\n%s\n%d\n", Version, sequence, nonce, signedAt)
+	return append([]byte(header), canonical...)
+}
+
+// Sign canonicalizes body and signs it with the given sequence number and a
+// fresh nonce. The canonical bytes must be sent as the request body.
+func Sign(key ed25519.PrivateKey, sequence uint64, body []byte) ([]byte, *Signature, error) {
+	canonical, err := Canonicalize(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	publicKey := key.Public().(ed25519.PublicKey)
+	sig := &Signature{
+		Version:     Version,
+		Fingerprint: Fingerprint(publicKey),
+		PublicKey:   publicKey,
+		Sequence:    sequence,
+		Nonce:       hex.EncodeToString(nonceBytes),
+		SignedAt:    time.Unix(time.Now().Unix(), 0),
+	}
+	sig.Value = ed25519.Sign(key, SigningInput(sig.Sequence, sig.Nonce, sig.SignedAt.Unix(), canonical))
+	return canonical, sig, nil
+}
+
+// Apply sets the signature headers on a request
+func (s *Signature) Apply(header http.Header) {
+	header.Set(HeaderVersion, s.Version)
+	header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(s.Value))
+	header.Set(HeaderFingerprint, s.Fingerprint)
+	header.Set(HeaderPublicKey, base64.StdEncoding.EncodeToString(s.PublicKey))
+	header.Set(HeaderSequence, strconv.FormatUint(s.Sequence, 10))
+	header.Set(HeaderNonce, s.Nonce)
+	header.Set(HeaderSignedAt, strconv.FormatInt(s.SignedAt.Unix(), 10))
+}
+
+// ParseHeaders reads the signature headers without verifying them
+func ParseHeaders(header http.Header) (*Signature, error) {
+	if header.Get(HeaderSignature) == "" {
+		return nil, ErrMissingSignature
+	}
+	if version := header.Get(HeaderVersion); version != Version {
+		return nil, fmt.Errorf("%w: unsupported version '%s'", ErrMalformed, version)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(header.Get(HeaderSignature))
+	if err != nil || len(value) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("%w: bad signature encoding", ErrMalformed)
+	}
+	sequence, err := strconv.ParseUint(header.Get(HeaderSequence), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad sequence", ErrMalformed)
+	}
+	signedAt, err := strconv.ParseInt(header.Get(HeaderSignedAt), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad timestamp", ErrMalformed)
+	}
+	nonce := header.Get(HeaderNonce)
+	if len(nonce) < 16 {
+		return nil, fmt.Errorf("%w: nonce too short", ErrMalformed)
+	}
+
+	sig := &Signature{
+		Version:     Version,
+		Fingerprint: header.Get(HeaderFingerprint),
+		Sequence:    sequence,
+		Nonce:       nonce,
+		SignedAt:    time.Unix(signedAt, 0),
+		Value:       value,
+	}
+	if encoded := header.Get(HeaderPublicKey); encoded != "" {
+		publicKey, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: bad public key", ErrMalformed)
+		}
+		sig.PublicKey = publicKey
+	}
+	return sig, nil
+}
+
+// KeyLookup returns the pinned public key for a fingerprint, or ok=false if unknown
+type KeyLookup func(fingerprint string) (publicKey ed25519.PublicKey, ok bool)
+
+// ReplayStore remembers what each key has already sent
+type ReplayStore interface {
+	// Check records the signature and returns ErrReplay if it was seen before
+	// or its sequence is not greater than the last accepted one
+	Check(sig *Signature) error
+}
+
+// Verifier checks signed requests. Keys is required unless TrustOnFirstUse is set,
+// in which case the public key header is accepted if it matches the fingerprint.
+type Verifier struct {
+	Keys            KeyLookup
+	TrustOnFirstUse bool
+	MaxSkew         time.Duration // DefaultMaxSkew when zero
+	Replay          ReplayStore   // replay checks are skipped when nil
+	Now             func() time.Time
+}
+
+// Verify checks the signature headers against the request body and returns the
+// verified signature. body may be the raw request body; it is canonicalized first.
+func (v *Verifier) Verify(header http.Header, body []byte) (*Signature, error) {
+	sig, err := ParseHeaders(header)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := v.publicKey(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	maxSkew := v.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = DefaultMaxSkew
+	}
+	if skew := now.Sub(sig.SignedAt); skew > maxSkew || skew < -maxSkew {
+		return nil, fmt.Errorf("%w: signed %v ago", ErrStale, skew.Round(time.Second))
+	}
+
+	canonical, err := Canonicalize(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	if !ed25519.Verify(publicKey, SigningInput(sig.Sequence, sig.Nonce, sig.SignedAt.Unix(), canonical), sig.Value) {
+		return nil, ErrBadSignature
+	}
+	sig.PublicKey = publicKey
+
+	// Only record sequence/nonce after the signature is known to be genuine
+	if v.Replay != nil {
+		if err := v.Replay.Check(sig); err != nil {
+			return nil, err
+		}
+	}
+	return sig, nil
+}
+
+// publicKey resolves the key to verify with
+func (v *Verifier) publicKey(sig *Signature) (ed25519.PublicKey, error) {
+	if v.Keys != nil {
+		if publicKey, ok := v.Keys(sig.Fingerprint); ok {
+			return publicKey, nil
+		}
+		if !v.TrustOnFirstUse {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownKey, sig.Fingerprint)
+		}
+	}
+	if !v.TrustOnFirstUse {
+		return nil, fmt.Errorf("%w: no key lookup configured", ErrUnknownKey)
+	}
+	if sig.PublicKey == nil || Fingerprint(sig.PublicKey) != sig.Fingerprint {
+		return nil, fmt.Errorf("%w: public key does not match fingerprint", ErrUnknownKey)
+	}
+	return sig.PublicKey, nil
+}
+
+// MemoryReplayStore is an in-process ReplayStore. It enforces strictly increasing
+// sequence numbers per key and remembers nonces for the skew window. Servers with
+// several instances need a shared store implementing the same rules.
+type MemoryReplayStore struct {
+	mu       sync.Mutex
+	window   time.Duration
+	sequence map[string]uint64
+	nonces   map[string]time.Time
+}
+
+// NewMemoryReplayStore creates a store that remembers nonces for window
+// (DefaultMaxSkew when zero); window should be at least the verifier's MaxSkew
+func NewMemoryReplayStore(window time.Duration) *MemoryReplayStore {
+	if window == 0 {
+		window = DefaultMaxSkew
+	}
+	return &MemoryReplayStore{
+		window:   window * 2,
+		sequence: make(map[string]uint64),
+		nonces:   make(map[string]time.Time),
+	}
+}
+
+// Check implements ReplayStore
+func (m *MemoryReplayStore) Check(sig *Signature) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for nonce, seen := range m.nonces {
+		if now.Sub(seen) > m.window {
+			delete(m.nonces, nonce)
+		}
+	}
+
+	nonceKey := sig.Fingerprint + "/" + sig.Nonce
+	if _, seen := m.nonces[nonceKey]; seen {
+		return fmt.Errorf("%w: nonce already used", ErrReplay)
+	}
+	if last, ok := m.sequence[sig.Fingerprint]; ok && sig.Sequence <= last {
+		return fmt.Errorf("%w: sequence %d not after %d", ErrReplay, sig.Sequence, last)
+	}
+
+	m.nonces[nonceKey] = now
+	m.sequence[sig.Fingerprint] = sig.Sequence
+	return nil
+}
@@ -0,0 +1,221 @@
+package payloadsig
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "sorted keys", in: `{"b":1,"a":2,"c":{"z":true,"y":null}}`, want: `{"a":2,"b":1,"c":{"y":null,"z":true}}`},
+		{name: "whitespace removed", in: "{\n  \"a\" : [ 1 , 2 ],\n\t\"b\": \"x y\"\n}\n", want: `{"a":[1,2],"b":"x y"}`},
+		{name: "numbers preserved", in: `{"big":12345678901234567890,"float":1.50,"exp":1e3,"neg":-0.0}`, want: `{"big":12345678901234567890,"exp":1e3,"float":1.50,"neg":-0.0}`},
+		{name: "no HTML escaping", in: `{"q":"a<b && c>d"}`, want: `{"q":"a<b && c>d"}`},
+		{name: "unicode escapes decoded", in: `{"s":"café"}`, want: `{"s":"café"}`},
+		{name: "array order kept", in: `[3,1,2]`, want: `[3,1,2]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Canonicalize([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Canonicalize(%s) = %s, want %s", tt.in, got, tt.want)
+			}
+
+			again, err := Canonicalize(got)
+			if err != nil || string(again) != string(got) {
+				t.Errorf("canonical form is not stable: %s -> %s (%v)", got, again, err)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeRejectsInvalidJSON(t *testing.T) {
+	for _, in := range []string{``, `{"a":`, `{"a":1} {"b":2}`, `{"a":1}x`} {
+		if _, err := Canonicalize([]byte(in)); err == nil {
+			t.Errorf("Canonicalize(%q) succeeded, want error", in)
+		}
+	}
+}
+
+// signed returns the headers and body of a payload signed with key
+func signed(t *testing.T, key ed25519.PrivateKey, sequence uint64, body string) (http.Header, []byte) {
+	t.Helper()
+	canonical, sig, err := Sign(key, sequence, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := make(http.Header)
+	sig.Apply(header)
+	return header, canonical
+}
+
+func newKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestVerifyAcceptsReformattedBody(t *testing.T) {
+	key := newKey(t)
+	header, _ := signed(t, key, 1, `{"b":1,"a":"x"}`)
+
+	verifier := &Verifier{TrustOnFirstUse: true}
+	if _, err := verifier.Verify(header, []byte("{ \"a\": \"x\", \"b\": 1 }")); err != nil {
+		t.Fatalf("reformatted body rejected: %v", err)
+	}
+	if _, err := verifier.Verify(header, []byte(`{"a":"x","b":2}`)); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("modified body: err = %v, want ErrBadSignature", err)
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	key := newKey(t)
+	verifier := &Verifier{TrustOnFirstUse: true, Replay: NewMemoryReplayStore(0)}
+
+	header, body := signed(t, key, 5, `{"n":1}`)
+	if _, err := verifier.Verify(header, body); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(header, body); !errors.Is(err, ErrReplay) {
+		t.Errorf("identical request: err = %v, want ErrReplay", err)
+	}
+
+	for _, sequence := range []uint64{5, 4} {
+		header, body := signed(t, key, sequence, `{"n":2}`)
+		if _, err := verifier.Verify(header, body); !errors.Is(err, ErrReplay) {
+			t.Errorf("sequence %d after 5: err = %v, want ErrReplay", sequence, err)
+		}
+	}
+
+	header, body = signed(t, key, 6, `{"n":3}`)
+	if _, err := verifier.Verify(header, body); err != nil {
+		t.Errorf("next sequence rejected: %v", err)
+	}
+
+	// Sequences are tracked per key
+	header, body = signed(t, newKey(t), 1, `{"n":4}`)
+	if _, err := verifier.Verify(header, body); err != nil {
+		t.Errorf("first request of another key rejected: %v", err)
+	}
+}
+
+func TestVerifyRejectedSignatureDoesNotAdvanceSequence(t *testing.T) {
+	key := newKey(t)
+	verifier := &Verifier{TrustOnFirstUse: true, Replay: NewMemoryReplayStore(0)}
+
+	header, _ := signed(t, key, 100, `{"n":1}`)
+	if _, err := verifier.Verify(header, []byte(`{"n":2}`)); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("err = %v, want ErrBadSignature", err)
+	}
+	header, body := signed(t, key, 1, `{"n":1}`)
+	if _, err := verifier.Verify(header, body); err != nil {
+		t.Fatalf("forged sequence 100 blocked a genuine request: %v", err)
+	}
+}
+
+func TestVerifyStale(t *testing.T) {
+	key := newKey(t)
+	header, body := signed(t, key, 1, `{}`)
+
+	for _, offset := range []time.Duration{10 * time.Minute, -10 * time.Minute} {
+		verifier := &Verifier{TrustOnFirstUse: true, Now: func() time.Time { return time.Now().Add(offset) }}
+		if _, err := verifier.Verify(header, body); !errors.Is(err, ErrStale) {
+			t.Errorf("clock offset %v: err = %v, want ErrStale", offset, err)
+		}
+	}
+}
+
+func TestVerifyKeyTrust(t *testing.T) {
+	pinned := newKey(t)
+	pinnedPublic := pinned.Public().(ed25519.PublicKey)
+	lookup := func(fingerprint string) (ed25519.PublicKey, bool) {
+		if fingerprint == Fingerprint(pinnedPublic) {
+			return pinnedPublic, true
+		}
+		return nil, false
+	}
+	stranger := newKey(t)
+
+	t.Run("pinned key accepted", func(t *testing.T) {
+		header, body := signed(t, pinned, 1, `{}`)
+		if _, err := (&Verifier{Keys: lookup}).Verify(header, body); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("unknown key rejected without TOFU", func(t *testing.T) {
+		header, body := signed(t, stranger, 1, `{}`)
+		if _, err := (&Verifier{Keys: lookup}).Verify(header, body); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("err = %v, want ErrUnknownKey", err)
+		}
+		if _, err := (&Verifier{}).Verify(header, body); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("no lookup: err = %v, want ErrUnknownKey", err)
+		}
+	})
+
+	t.Run("unknown key accepted on first use", func(t *testing.T) {
+		header, body := signed(t, stranger, 1, `{}`)
+		sig, err := (&Verifier{Keys: lookup, TrustOnFirstUse: true}).Verify(header, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if Fingerprint(sig.PublicKey) != Fingerprint(stranger.Public().(ed25519.PublicKey)) {
+			t.Fatal("verified signature does not carry the signer's public key")
+		}
+	})
+
+	t.Run("pinned fingerprint cannot be claimed by another key", func(t *testing.T) {
+		header, body := signed(t, stranger, 1, `{}`)
+		header.Set(HeaderFingerprint, Fingerprint(pinnedPublic))
+		if _, err := (&Verifier{Keys: lookup, TrustOnFirstUse: true}).Verify(header, body); !errors.Is(err, ErrBadSignature) {
+			t.Fatalf("err = %v, want ErrBadSignature", err)
+		}
+	})
+
+	t.Run("TOFU public key must match fingerprint", func(t *testing.T) {
+		header, body := signed(t, stranger, 1, `{}`)
+		header.Set(HeaderFingerprint, Fingerprint(newKey(t).Public().(ed25519.PublicKey)))
+		if _, err := (&Verifier{TrustOnFirstUse: true}).Verify(header, body); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("err = %v, want ErrUnknownKey", err)
+		}
+	})
+}
+
+func TestParseHeadersMalformed(t *testing.T) {
+	key := newKey(t)
+	base, _ := signed(t, key, 1, `{}`)
+
+	if _, err := ParseHeaders(make(http.Header)); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("no headers: err = %v, want ErrMissingSignature", err)
+	}
+
+	for name, mutate := range map[string]func(http.Header){
+		"version":   func(h http.Header) { h.Set(HeaderVersion, "v0") },
+		"signature": func(h http.Header) { h.Set(HeaderSignature, "not base64!") },
+		"sequence":  func(h http.Header) { h.Set(HeaderSequence, "-1") },
+		"signed at": func(h http.Header) { h.Set(HeaderSignedAt, "yesterday") },
+		"nonce":     func(h http.Header) { h.Set(HeaderNonce, "short") },
+		"key":       func(h http.Header) { h.Set(HeaderPublicKey, "AAAA") },
+	} {
+		header := base.Clone()
+		mutate(header)
+		if _, err := ParseHeaders(header); !errors.Is(err, ErrMalformed) {
+			t.Errorf("bad %s: err = %v, want ErrMalformed", name, err)
+		}
+	}
+}
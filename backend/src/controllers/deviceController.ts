@@ -2,6 +2,8 @@ import { DeviceModel, DeviceSummaryModel, IndividualDataModel, AgentPayload } fr
 import { UsersModel } from '../models/Users';
 import { Request, Response } from 'express';
 import { parseToIST, getCurrentISTString } from '../utils/timezone';
+import { env } from '../env/env';
+import path from 'path';
 
 // Function to check if data indicates compliance based on data type
 function checkDataCompliance(dataType: string, data: any[]): boolean {
@@ -93,6 +95,18 @@ export const receiveAgentData = async (req: Request, res: Response) => {
       agent_version: agentData.version
     });
 
+    // Reports imported with `scanx send --file` carry the signature of the device that
+    // collected them; it covers the canonical JSON body and is logged for provenance
+    const reportSignature = req.header('x-scanx-report-signature');
+    if (reportSignature) {
+      try {
+        const sig = JSON.parse(Buffer.from(reportSignature, 'base64').toString('utf8'));
+        console.log(`📝 Imported report for device ${deviceId} signed by key ${sig.key_id} at ${sig.signed_at}`);
+      } catch (error: any) {
+        console.warn(`⚠️  Ignoring malformed report signature header for device ${deviceId}:`, error.message);
+      }
+    }
+
     // Store data in individual tables
     // Convert agent timestamp to MySQL format if provided, otherwise use server timestamp
     let timestamp: string;
@@ -155,6 +169,50 @@ export const receiveAgentData = async (req: Request, res: Response) => {
       }
     }
 
+    // Vulnerability findings matched by the agent against its local database
+    if (agentData.vulnerabilities && agentData.vulnerabilities.length > 0) {
+      try {
+        await connection.execute(
+          `INSERT INTO vulnerabilities (device_id, timestamp, data) 
+           VALUES (?, ?, ?)`,
+          [deviceId, timestamp, JSON.stringify(agentData.vulnerabilities)]
+        );
+        console.log(`✅ Stored ${agentData.vulnerabilities.length} vulnerability findings for device ${deviceId}`);
+      } catch (error: any) {
+        console.error(`❌ Failed to store vulnerabilities:`, error.message);
+      }
+    }
+
+    // Installed software normalized by the agent across platforms. Current agents
+    // send it instead of raw apps_info rows, so it is the device's application list.
+    if (agentData.software && agentData.software.length > 0) {
+      try {
+        await connection.execute(
+          `INSERT INTO apps_info (device_id, timestamp, data) 
+           VALUES (?, ?, ?)`,
+          [deviceId, timestamp, JSON.stringify(agentData.software)]
+        );
+        receivedDataTypes.apps_info = true;
+        console.log(`✅ Stored ${agentData.software.length} software items for device ${deviceId}`);
+      } catch (error: any) {
+        console.error(`❌ Failed to store software:`, error.message);
+      }
+    }
+
+    // Browser extensions violating the agent's extension_denylist/extension_allowlist
+    if (agentData.extension_findings && agentData.extension_findings.length > 0) {
+      try {
+        await connection.execute(
+          `INSERT INTO extension_findings (device_id, timestamp, data) 
+           VALUES (?, ?, ?)`,
+          [deviceId, timestamp, JSON.stringify(agentData.extension_findings)]
+        );
+        console.log(`✅ Stored ${agentData.extension_findings.length} extension findings for device ${deviceId}`);
+      } catch (error: any) {
+        console.error(`❌ Failed to store extension findings:`, error.message);
+      }
+    }
+
     // Special validation for screen_lock_info: check grace_period
     if (agentData.data.screen_lock_info && agentData.data.screen_lock_info.length > 0) {
       try {
@@ -193,6 +251,74 @@ export const receiveAgentData = async (req: Request, res: Response) => {
   }
 };
 
+// Agent enrollment pre-check: applies the same ownership rules as receiveAgentData
+// without registering anything, so `scanx enroll` can reject a wrong email up front
+export const checkEnrollment = async (req: Request, res: Response) => {
+  try {
+    const { user_email, serial_no } = req.body || {};
+    if (!user_email || !serial_no) {
+      return res.status(400).json({ message: 'Missing required fields: user_email, serial_no' });
+    }
+
+    const existingDevice = await DeviceModel.findBySerial(serial_no);
+    const userRec = await UsersModel.findByEmail(user_email);
+    if (!existingDevice) {
+      const existingByEmail = await DeviceModel.findByUser(user_email);
+      if (existingByEmail.length > 0) {
+        return res.status(409).json({ message: 'email already associated with another device' });
+      }
+      if (!userRec) {
+        return res.status(404).json({ message: 'user_email not found' });
+      }
+      if (userRec.account_type !== 'user') {
+        return res.status(401).json({ message: 'service account cannot send data' });
+      }
+    }
+
+    // The endpoint is unauthenticated: only echo the display name back when the
+    // serial is already enrolled to this email, never for an arbitrary address
+    const ownDevice = !!existingDevice && existingDevice.user_email === user_email;
+    res.json({
+      message: 'ok',
+      ...(ownDevice && userRec?.name ? { name: userRec.name } : {}),
+      registered: !!existingDevice
+    });
+  } catch (err: any) {
+    console.error('Error checking enrollment:', err);
+    res.status(500).json({ message: 'Internal server error', error: err.message });
+  }
+};
+
+// Serve the OSV vulnerability database agents match installed software against.
+// sendFile answers If-None-Match with 304, so agents only download changes.
+export const getVulnerabilityDatabase = async (req: Request, res: Response) => {
+  if (!env.VULNDB_PATH) {
+    return res.status(404).json({ message: 'vulnerability database not configured' });
+  }
+
+  res.sendFile(path.resolve(env.VULNDB_PATH), (err: any) => {
+    if (err && !res.headersSent) {
+      console.error('Error sending vulnerability database:', err.message);
+      res.status(err.status || 500).json({ message: 'vulnerability database unavailable' });
+    }
+  });
+};
+
+// Serve the signature of the vulnerability database, written next to it by
+// `npm run sign-vulndb`; agents refuse downloads without a valid one
+export const getVulnerabilityDatabaseSignature = async (req: Request, res: Response) => {
+  if (!env.VULNDB_PATH) {
+    return res.status(404).json({ message: 'vulnerability database not configured' });
+  }
+
+  res.sendFile(path.resolve(`${env.VULNDB_PATH}.sig`), (err: any) => {
+    if (err && !res.headersSent) {
+      console.error('Error sending vulnerability database signature:', err.message);
+      res.status(err.status || 500).json({ message: 'vulnerability database signature unavailable' });
+    }
+  });
+};
+
 // Get all devices (admin dashboard)
 export const getDevices = async (req: Request, res: Response) => {
   try {
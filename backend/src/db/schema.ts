@@ -11,6 +11,10 @@ export const TABLES = {
     ANTIVIRUS_INFO: 'antivirus_info',
     SCREEN_LOCK_INFO: 'screen_lock_info',
     APPS_INFO: 'apps_info',
+    VULNERABILITIES: 'vulnerabilities',
+    CHROME_EXTENSIONS_INFO: 'chrome_extensions_info',
+    FIREFOX_ADDONS_INFO: 'firefox_addons_info',
+    EXTENSION_FINDINGS: 'extension_findings',
     DEVICE_SUMMARY: 'device_summary'
 } as const;
 
@@ -206,6 +210,90 @@ export const createAppsInfoTable = async () => {
     console.log(`✅ Table ${TABLES.APPS_INFO} created/verified (this table may become heavy)`);
 };
 
+// Create vulnerabilities table for the agent's offline vulnerability findings
+export const createVulnerabilitiesTable = async () => {
+    const connection = getConnection();
+    
+    await connection.execute(`
+        CREATE TABLE IF NOT EXISTS ${TABLES.VULNERABILITIES} (
+            id INT AUTO_INCREMENT PRIMARY KEY,
+            device_id INT NOT NULL,
+            timestamp TIMESTAMP NOT NULL,
+            data JSON,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+            FOREIGN KEY (device_id) REFERENCES ${TABLES.DEVICES}(id) ON DELETE CASCADE,
+            UNIQUE KEY idx_device_timestamp (device_id, timestamp),
+            INDEX idx_timestamp (timestamp)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+    `);
+    
+    console.log(`✅ Table ${TABLES.VULNERABILITIES} created/verified`);
+};
+
+// Create chrome_extensions_info table (Chromium browser extensions of all local users)
+export const createChromeExtensionsInfoTable = async () => {
+    const connection = getConnection();
+    
+    await connection.execute(`
+        CREATE TABLE IF NOT EXISTS ${TABLES.CHROME_EXTENSIONS_INFO} (
+            id INT AUTO_INCREMENT PRIMARY KEY,
+            device_id INT NOT NULL,
+            timestamp TIMESTAMP NOT NULL,
+            data JSON,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+            FOREIGN KEY (device_id) REFERENCES ${TABLES.DEVICES}(id) ON DELETE CASCADE,
+            UNIQUE KEY idx_device_timestamp (device_id, timestamp),
+            INDEX idx_timestamp (timestamp)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+    `);
+    
+    console.log(`✅ Table ${TABLES.CHROME_EXTENSIONS_INFO} created/verified`);
+};
+
+// Create firefox_addons_info table (Firefox add-ons of all local users)
+export const createFirefoxAddonsInfoTable = async () => {
+    const connection = getConnection();
+    
+    await connection.execute(`
+        CREATE TABLE IF NOT EXISTS ${TABLES.FIREFOX_ADDONS_INFO} (
+            id INT AUTO_INCREMENT PRIMARY KEY,
+            device_id INT NOT NULL,
+            timestamp TIMESTAMP NOT NULL,
+            data JSON,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+            FOREIGN KEY (device_id) REFERENCES ${TABLES.DEVICES}(id) ON DELETE CASCADE,
+            UNIQUE KEY idx_device_timestamp (device_id, timestamp),
+            INDEX idx_timestamp (timestamp)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+    `);
+    
+    console.log(`✅ Table ${TABLES.FIREFOX_ADDONS_INFO} created/verified`);
+};
+
+// Create extension_findings table for extensions violating the agent's deny/allow lists
+export const createExtensionFindingsTable = async () => {
+    const connection = getConnection();
+    
+    await connection.execute(`
+        CREATE TABLE IF NOT EXISTS ${TABLES.EXTENSION_FINDINGS} (
+            id INT AUTO_INCREMENT PRIMARY KEY,
+            device_id INT NOT NULL,
+            timestamp TIMESTAMP NOT NULL,
+            data JSON,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+            FOREIGN KEY (device_id) REFERENCES ${TABLES.DEVICES}(id) ON DELETE CASCADE,
+            UNIQUE KEY idx_device_timestamp (device_id, timestamp),
+            INDEX idx_timestamp (timestamp)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+    `);
+    
+    console.log(`✅ Table ${TABLES.EXTENSION_FINDINGS} created/verified`);
+};
+
 // Create device_summary table for overview (which data types received)
 export const createDeviceSummaryTable = async () => {
     const connection = getConnection();
@@ -243,6 +331,10 @@ export const initializeSchema = async () => {
         await createAntivirusInfoTable();
         await createScreenLockInfoTable();
         await createAppsInfoTable();
+        await createVulnerabilitiesTable();
+        await createChromeExtensionsInfoTable();
+        await createFirefoxAddonsInfoTable();
+        await createExtensionFindingsTable();
         await createDeviceSummaryTable();
         
         console.log("🎯 Database schema initialized successfully!");
@@ -262,6 +354,10 @@ export const dropAllTables = async () => {
         
         // Drop in reverse order due to foreign key constraints
         await connection.execute(`DROP TABLE IF EXISTS ${TABLES.DEVICE_SUMMARY}`);
+        await connection.execute(`DROP TABLE IF EXISTS ${TABLES.VULNERABILITIES}`);
+        await connection.execute(`DROP TABLE IF EXISTS ${TABLES.CHROME_EXTENSIONS_INFO}`);
+        await connection.execute(`DROP TABLE IF EXISTS ${TABLES.FIREFOX_ADDONS_INFO}`);
+        await connection.execute(`DROP TABLE IF EXISTS ${TABLES.EXTENSION_FINDINGS}`);
         await connection.execute(`DROP TABLE IF EXISTS ${TABLES.APPS_INFO}`);
         await connection.execute(`DROP TABLE IF EXISTS ${TABLES.SCREEN_LOCK_INFO}`);
         await connection.execute(`DROP TABLE IF EXISTS ${TABLES.ANTIVIRUS_INFO}`);
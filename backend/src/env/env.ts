@@ -14,4 +14,5 @@ export const env = {
     GOOGLE_SERVICE_ACCOUNT_KEY_FILE: process.env.GOOGLE_SERVICE_ACCOUNT_KEY_FILE,
     GOOGLE_WORKSPACE_ADMIN_EMAIL: process.env.GOOGLE_WORKSPACE_ADMIN_EMAIL,
     GOOGLE_WORKSPACE_CUSTOMER: process.env.GOOGLE_WORKSPACE_CUSTOMER,
+    VULNDB_PATH: process.env.VULNDB_PATH,
 }
\ No newline at end of file
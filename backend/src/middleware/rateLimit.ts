@@ -0,0 +1,41 @@
+import { Request, Response, NextFunction } from 'express';
+
+interface Window {
+  count: number;
+  resetAt: number;
+}
+
+// Fixed-window, per-client-IP request limiter for unauthenticated endpoints.
+// Counters live in memory, so each backend instance enforces its own limit.
+export const rateLimit = (options: { windowMs: number; max: number }) => {
+  const windows = new Map<string, Window>();
+
+  // Drop expired windows so clients that went away do not accumulate
+  const sweep = setInterval(() => {
+    const now = Date.now();
+    for (const [key, window] of windows) {
+      if (window.resetAt <= now) {
+        windows.delete(key);
+      }
+    }
+  }, options.windowMs);
+  sweep.unref();
+
+  return (req: Request, res: Response, next: NextFunction) => {
+    const key = req.ip || req.socket.remoteAddress || 'unknown';
+    const now = Date.now();
+
+    let window = windows.get(key);
+    if (!window || window.resetAt <= now) {
+      window = { count: 0, resetAt: now + options.windowMs };
+      windows.set(key, window);
+    }
+    window.count++;
+
+    if (window.count > options.max) {
+      res.setHeader('Retry-After', Math.ceil((window.resetAt - now) / 1000).toString());
+      return res.status(429).json({ message: 'Too many requests, try again later' });
+    }
+    next();
+  };
+};
@@ -40,6 +40,9 @@ export interface AgentPayload {
     data: {
         [key: string]: any[];
     };
+    vulnerabilities?: any[];
+    software?: any[];
+    extension_findings?: any[];
 }
 
 export class DeviceModel {
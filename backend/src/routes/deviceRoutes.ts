@@ -1,7 +1,11 @@
 import express from 'express';
 import { auth } from '../middleware/authMiddleware';
+import { rateLimit } from '../middleware/rateLimit';
 import {
   receiveAgentData,
+  checkEnrollment,
+  getVulnerabilityDatabase,
+  getVulnerabilityDatabaseSignature,
   getDevices,
   getDevicesTable,
   getDeviceById,
@@ -14,6 +18,10 @@ const router: express.Router = express.Router();
 
 // Public route for agent data submission (no auth required)
 router.post('/agent/report', receiveAgentData);
+// The pre-check reveals whether an email is enrollable, so keep it from being used to enumerate users
+router.post('/agent/enroll/check', rateLimit({ windowMs: 15 * 60 * 1000, max: 20 }), checkEnrollment);
+router.get('/agent/vulndb', getVulnerabilityDatabase);
+router.get('/agent/vulndb.sig', getVulnerabilityDatabaseSignature);
 
 // Protected admin routes for device management
 router.get('/dashboard/stats', auth, getDashboardStats);
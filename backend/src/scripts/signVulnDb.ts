@@ -0,0 +1,60 @@
+#!/usr/bin/env ts-node
+
+// Vulnerability database signing script - run after every update of VULNDB_PATH.
+// Agents verify the Ed25519 signature of the file's SHA-256 digest with the public
+// key set as vulnerability_db_key in agent.conf.
+import 'dotenv/config';
+import crypto from 'crypto';
+import fs from 'fs';
+import { env } from '../env/env';
+
+// sha256File hashes the database without reading it into memory
+const sha256File = (filePath: string): Promise<Buffer> =>
+    new Promise((resolve, reject) => {
+        const hash = crypto.createHash('sha256');
+        fs.createReadStream(filePath)
+            .on('data', (chunk) => hash.update(chunk))
+            .on('end', () => resolve(hash.digest()))
+            .on('error', reject);
+    });
+
+// loadOrCreateKey reads the PEM private key, generating one on first use
+const loadOrCreateKey = (keyPath: string): crypto.KeyObject => {
+    if (fs.existsSync(keyPath)) {
+        return crypto.createPrivateKey(fs.readFileSync(keyPath));
+    }
+
+    const { privateKey } = crypto.generateKeyPairSync('ed25519');
+    fs.writeFileSync(keyPath, privateKey.export({ format: 'pem', type: 'pkcs8' }), { mode: 0o600 });
+    console.log(`🔑 Generated new signing key ${keyPath}; keep it off the backend host`);
+    return privateKey;
+};
+
+async function signVulnDb() {
+    const keyPath = process.argv[2];
+    if (!keyPath || !env.VULNDB_PATH) {
+        console.error("Usage: VULNDB_PATH=<database> npm run sign-vulndb -- <private-key.pem>");
+        process.exit(2);
+    }
+
+    try {
+        const privateKey = loadOrCreateKey(keyPath);
+        const digest = await sha256File(env.VULNDB_PATH);
+        const signature = crypto.sign(null, digest, privateKey);
+        fs.writeFileSync(`${env.VULNDB_PATH}.sig`, signature.toString('base64') + '\n');
+
+        // The raw 32-byte public key, as agents expect it in vulnerability_db_key
+        const jwk = crypto.createPublicKey(privateKey).export({ format: 'jwk' });
+        const publicKey = Buffer.from(jwk.x as string, 'base64url').toString('base64');
+        console.log(`✅ Signed ${env.VULNDB_PATH}`);
+        console.log(`   vulnerability_db_key: ${publicKey}`);
+        process.exit(0);
+
+    } catch (error: any) {
+        console.error("❌ Signing failed:", error.message);
+        process.exit(1);
+    }
+}
+
+// Sign the database
+signVulnDb();